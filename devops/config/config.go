@@ -0,0 +1,338 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 环境变量前缀，如 DEVOPS_SERVER_ADDR 覆盖 server.addr
+const EnvPrefix = "DEVOPS"
+
+// DefaultConfigFile 默认配置文件路径
+const DefaultConfigFile = "config.yaml"
+
+// 支持的数据库驱动
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// DefaultSQLiteFile 嵌入式 SQLite 模式下的默认数据库文件
+const DefaultSQLiteFile = "data/devops.db"
+
+// Conf 当前生效的配置，由 Load 初始化
+var Conf *Config
+
+// Config 应用配置
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Database   DatabaseConfig   `yaml:"database"`
+	JWT        JWTConfig        `yaml:"jwt"`
+	Repository RepositoryConfig `yaml:"repository"`
+	Secrets    SecretsConfig    `yaml:"secrets"`
+	SSH        SSHConfig        `yaml:"ssh"`
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Addr string `yaml:"addr"` // 监听地址
+	Mode string `yaml:"mode"` // gin 运行模式: debug/release/test
+}
+
+// DatabaseConfig 数据库配置
+type DatabaseConfig struct {
+	Driver          string        `yaml:"driver"` // mysql/postgres/sqlite
+	DSN             string        `yaml:"dsn"`    // sqlite 时为数据库文件路径，留空使用 DefaultSQLiteFile
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	LogLevel        string        `yaml:"log_level"`    // silent/error/warn/info
+	AutoMigrate     bool          `yaml:"auto_migrate"` // 启动时自动执行未执行的迁移
+}
+
+// JWTConfig 认证配置
+type JWTConfig struct {
+	Secret string        `yaml:"secret"`
+	Expire time.Duration `yaml:"expire"`
+}
+
+// RepositoryConfig 代码仓库服务配置
+type RepositoryConfig struct {
+	Proxy    string `yaml:"proxy"`     // 访问代码仓库使用的 HTTP 代理，为空表示不使用
+	BasePath string `yaml:"base_path"` // 本地仓库缓存目录
+}
+
+// SecretsConfig 敏感字段加密配置
+type SecretsConfig struct {
+	MasterKeys []string `yaml:"master_keys"` // 主密钥列表，格式为 "ID:base64(32字节)"
+	ActiveKey  string   `yaml:"active_key"`  // 加密新数据使用的主密钥ID，只有一个主密钥时可省略
+}
+
+// 主机密钥校验策略
+const (
+	HostKeyPolicyTOFU   = "tofu"   // 首次连接时记录主机密钥，之后严格校验
+	HostKeyPolicyStrict = "strict" // 只允许连接已登记主机密钥的主机
+)
+
+// SSHConfig 主机 SSH 连接配置
+type SSHConfig struct {
+	HostKeyPolicy     string        `yaml:"host_key_policy"`    // tofu/strict
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`       // 连接池中空闲连接的保留时间
+	KeepaliveInterval time.Duration `yaml:"keepalive_interval"` // keepalive 间隔，0 表示不发送
+	MaxSessions       int           `yaml:"max_sessions"`       // 每台主机同时进行的操作数上限
+	MaxShells         int           `yaml:"max_shells"`         // 每台主机同时打开的 WebShell 数上限
+	RecordingDir      string        `yaml:"recording_dir"`      // WebShell 会话录像存放目录
+	PromptPattern     string        `yaml:"prompt_pattern"`     // 识别命令行提示符的正则，用于命令审计
+	ApprovalTimeout   time.Duration `yaml:"approval_timeout"`   // 需要审批的命令等待审批的最长时间
+	ReconnectGrace    time.Duration `yaml:"reconnect_grace"`    // WebShell 连接断开后保留会话等待重连的时间，0 表示立即结束
+	ScrollbackSize    int           `yaml:"scrollback_size"`    // 重连时回放的最近终端输出字节数
+	FactsInterval     time.Duration `yaml:"facts_interval"`     // 定时采集主机信息的间隔，0 表示不定时采集
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr: ":8100",
+			Mode: "debug",
+		},
+		Database: DatabaseConfig{
+			Driver:          DriverMySQL,
+			MaxIdleConns:    10,
+			MaxOpenConns:    100,
+			ConnMaxLifetime: time.Hour,
+			LogLevel:        "info",
+			AutoMigrate:     true,
+		},
+		JWT: JWTConfig{
+			Expire: 24 * time.Hour,
+		},
+		Repository: RepositoryConfig{
+			BasePath: filepath.Join(os.TempDir(), "devops", "repositories"),
+		},
+		SSH: SSHConfig{
+			HostKeyPolicy:     HostKeyPolicyTOFU,
+			IdleTimeout:       5 * time.Minute,
+			KeepaliveInterval: 30 * time.Second,
+			MaxSessions:       6,
+			MaxShells:         3,
+			RecordingDir:      filepath.Join("data", "recordings"),
+			PromptPattern:     `^.*?[$#%>] `,
+			ApprovalTimeout:   5 * time.Minute,
+			ReconnectGrace:    time.Minute,
+			ScrollbackSize:    64 * 1024,
+		},
+	}
+}
+
+// Load 依次加载默认配置、配置文件和环境变量覆盖，校验后设置为当前配置。
+// path 为空时使用 DEVOPS_CONFIG 环境变量或默认路径，默认路径不存在时忽略。
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv(EnvPrefix + "_CONFIG")
+		explicit = path != ""
+	}
+	if path == "" {
+		path = DefaultConfigFile
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+		// 未指定配置文件且默认文件不存在，仅使用默认值与环境变量
+	default:
+		return nil, fmt.Errorf("读取配置文件 %s 失败: %v", path, err)
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem(), EnvPrefix); err != nil {
+		return nil, err
+	}
+
+	if cfg.Database.Driver == DriverSQLite && cfg.Database.DSN == "" {
+		cfg.Database.DSN = DefaultSQLiteFile
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	Conf = cfg
+	return cfg, nil
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	var errs []string
+
+	if c.Server.Addr == "" {
+		errs = append(errs, "server.addr 不能为空")
+	}
+	switch c.Server.Mode {
+	case "debug", "release", "test":
+	default:
+		errs = append(errs, "server.mode 必须为 debug/release/test")
+	}
+
+	switch c.Database.Driver {
+	case DriverMySQL, DriverPostgres, DriverSQLite:
+	default:
+		errs = append(errs, "database.driver 必须为 mysql/postgres/sqlite")
+	}
+	if c.Database.DSN == "" {
+		errs = append(errs, "database.dsn 不能为空")
+	}
+	switch c.Database.LogLevel {
+	case "silent", "error", "warn", "info":
+	default:
+		errs = append(errs, "database.log_level 必须为 silent/error/warn/info")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, "database 连接池参数不能为负数")
+	}
+
+	if len(c.JWT.Secret) < 16 {
+		errs = append(errs, "jwt.secret 至少16个字符")
+	}
+	if c.JWT.Expire <= 0 {
+		errs = append(errs, "jwt.expire 必须大于0")
+	}
+
+	if c.Repository.BasePath == "" {
+		errs = append(errs, "repository.base_path 不能为空")
+	}
+
+	if len(c.Secrets.MasterKeys) == 0 {
+		errs = append(errs, "secrets.master_keys 至少需要一个主密钥")
+	}
+
+	switch c.SSH.HostKeyPolicy {
+	case HostKeyPolicyTOFU, HostKeyPolicyStrict:
+	default:
+		errs = append(errs, "ssh.host_key_policy 必须为 tofu/strict")
+	}
+	if c.SSH.IdleTimeout <= 0 {
+		errs = append(errs, "ssh.idle_timeout 必须大于0")
+	}
+	if c.SSH.KeepaliveInterval < 0 {
+		errs = append(errs, "ssh.keepalive_interval 不能为负数")
+	}
+	if c.SSH.MaxSessions < 1 {
+		errs = append(errs, "ssh.max_sessions 至少为1")
+	}
+	if c.SSH.MaxShells < 1 {
+		errs = append(errs, "ssh.max_shells 至少为1")
+	}
+	if c.SSH.RecordingDir == "" {
+		errs = append(errs, "ssh.recording_dir 不能为空")
+	}
+	if _, err := regexp.Compile(c.SSH.PromptPattern); err != nil || c.SSH.PromptPattern == "" {
+		errs = append(errs, "ssh.prompt_pattern 必须是有效的正则表达式")
+	}
+	if c.SSH.ApprovalTimeout <= 0 {
+		errs = append(errs, "ssh.approval_timeout 必须大于0")
+	}
+	if c.SSH.ReconnectGrace < 0 {
+		errs = append(errs, "ssh.reconnect_grace 不能为负数")
+	}
+	if c.SSH.ScrollbackSize < 0 {
+		errs = append(errs, "ssh.scrollback_size 不能为负数")
+	}
+	if c.SSH.FactsInterval < 0 {
+		errs = append(errs, "ssh.facts_interval 不能为负数")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("配置校验失败: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// applyEnv 按 yaml 标签将环境变量 PREFIX_SECTION_KEY 覆盖到配置结构体
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnv(fv, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, value); err != nil {
+			return fmt.Errorf("环境变量 %s 无效: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// setValue 将字符串解析为字段对应类型
+func setValue(fv reflect.Value, value string) error {
+	if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持的类型 %s", fv.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		fv.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("不支持的类型 %s", fv.Type())
+	}
+	return nil
+}
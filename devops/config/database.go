@@ -3,28 +3,36 @@ package config
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"devops/global"
-	"devops/models"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 func InitDB() {
-	dsn := "devops:devops@tcp(127.0.0.1:3306)/devops?charset=utf8mb4&parseTime=True&loc=Local"
+	dbConf := Conf.Database
 
 	// 配置GORM
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: logger.Default.LogMode(gormLogLevel(dbConf.LogLevel)),
 		NowFunc: func() time.Time {
 			return time.Now().Local()
 		},
 	}
 
-	var err error
-	global.DB, err = gorm.Open(mysql.Open(dsn), gormConfig)
+	dialector, err := openDialector(dbConf.Driver, dbConf.DSN)
+	if err != nil {
+		log.Fatalf("初始化数据库驱动失败: %v", err)
+	}
+
+	global.DB, err = gorm.Open(dialector, gormConfig)
 	if err != nil {
 		log.Fatalf("连接数据库失败: %v", err)
 	}
@@ -36,22 +44,72 @@ func InitDB() {
 	}
 
 	// 设置连接池参数
-	sqlDB.SetMaxIdleConns(10)           // 设置空闲连接池中连接的最大数量
-	sqlDB.SetMaxOpenConns(100)          // 设置打开数据库连接的最大数量
-	sqlDB.SetConnMaxLifetime(time.Hour) // 设置了连接可复用的最大时间
+	sqlDB.SetMaxIdleConns(dbConf.MaxIdleConns)       // 设置空闲连接池中连接的最大数量
+	sqlDB.SetMaxOpenConns(dbConf.MaxOpenConns)       // 设置打开数据库连接的最大数量
+	sqlDB.SetConnMaxLifetime(dbConf.ConnMaxLifetime) // 设置了连接可复用的最大时间
+	if dbConf.Driver == DriverSQLite {
+		// SQLite 同一时间只允许一个写入者，串行化连接避免 database is locked
+		sqlDB.SetMaxOpenConns(1)
+	}
 
 	// 测试数据库连接
 	if err := sqlDB.Ping(); err != nil {
 		log.Fatalf("数据库连接测试失败: %v", err)
 	}
 
-	// 自动迁移数据库表
-	log.Println("开始数据库迁移...")
-	err = global.DB.AutoMigrate(&models.Host{}, models.Repository{}, models.DockerRegistry{}, models.Project{})
-	if err != nil {
-		log.Fatalf("数据库迁移失败: %v", err)
+	fmt.Printf("数据库连接成功 (%s)\n", dbConf.Driver)
+}
+
+// openDialector 根据驱动类型创建 GORM 方言
+func openDialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case DriverMySQL:
+		return mysql.Open(dsn), nil
+	case DriverPostgres:
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		if dir := filepath.Dir(sqliteFile(dsn)); dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, err
+			}
+		}
+		return sqlite.Open(sqliteDSN(dsn)), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", driver)
+	}
+}
+
+// sqliteFile 从 SQLite DSN 中提取数据库文件路径
+func sqliteFile(dsn string) string {
+	file := strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(file, '?'); i >= 0 {
+		file = file[:i]
+	}
+	return file
+}
+
+// sqliteDSN 为 SQLite DSN 补充默认参数：开启外键、WAL 日志与忙等待
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_pragma=") {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
 	}
-	log.Println("数据库迁移完成")
+	return dsn + sep + "_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+}
 
-	fmt.Println("数据库连接成功")
+// gormLogLevel 将配置中的日志级别转换为 GORM 日志级别
+func gormLogLevel(level string) logger.LogLevel {
+	switch level {
+	case "silent":
+		return logger.Silent
+	case "error":
+		return logger.Error
+	case "warn":
+		return logger.Warn
+	default:
+		return logger.Info
+	}
 }
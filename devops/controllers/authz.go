@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"devops/global"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+)
+
+// checkPermission 校验当前用户对资源的操作权限，无权限时写入响应并返回 false
+func checkPermission(ctx *gin.Context, resource, action string, resourceID uint) bool {
+	allowed, err := services.NewAuthzService(global.DB).Authorize(ctx.GetUint("userID"), resource, action, resourceID)
+	if err != nil {
+		log.Printf("权限校验失败: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "权限校验失败"})
+		return false
+	}
+	if !allowed {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限执行该操作"})
+		return false
+	}
+	return true
+}
+
+// checkAnyPermission 校验当前用户对资源拥有 actions 中的任一操作权限，均无权限时写入响应并返回 false
+func checkAnyPermission(ctx *gin.Context, resource string, resourceID uint, actions ...string) bool {
+	authz := services.NewAuthzService(global.DB)
+	for _, action := range actions {
+		allowed, err := authz.Authorize(ctx.GetUint("userID"), resource, action, resourceID)
+		if err != nil {
+			log.Printf("权限校验失败: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "权限校验失败"})
+			return false
+		}
+		if allowed {
+			return true
+		}
+	}
+	ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限执行该操作"})
+	return false
+}
+
+// paramID 解析路径中的 id 参数，解析失败时返回 0
+func paramID(ctx *gin.Context) uint {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"devops/global"
+	"devops/models"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CommandRuleController WebShell 命令策略与审批控制器
+type CommandRuleController struct {
+	DB *gorm.DB
+}
+
+// NewCommandRuleController 创建命令策略控制器
+func NewCommandRuleController() *CommandRuleController {
+	return &CommandRuleController{
+		DB: global.DB,
+	}
+}
+
+// GetCommandRules 获取命令规则列表
+func (c *CommandRuleController) GetCommandRules(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceCommandRule, services.ActionRead, 0) {
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+
+	rules, total, err := models.GetCommandRuleList(c.DB, page, pageSize, ctx.Query("name"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  rules,
+		"total": total,
+	})
+}
+
+// CreateCommandRule 创建命令规则，未指定 enabled 时默认启用
+func (c *CommandRuleController) CreateCommandRule(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceCommandRule, services.ActionCreate, 0) {
+		return
+	}
+
+	rule := models.CommandRule{Enabled: true}
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateCommandRule(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule.ID = 0
+	if err := models.CreateCommandRule(c.DB, &rule); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rule)
+}
+
+// UpdateCommandRule 更新命令规则
+func (c *CommandRuleController) UpdateCommandRule(ctx *gin.Context) {
+	id := paramID(ctx)
+	if id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceCommandRule, services.ActionUpdate, id) {
+		return
+	}
+
+	existing, err := models.GetCommandRule(c.DB, id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "命令规则不存在"})
+		return
+	}
+
+	// 未提交的字段保持原值
+	rule := *existing
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateCommandRule(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.UpdateCommandRule(c.DB, id, &rule); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := models.GetCommandRule(c.DB, id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, updated)
+}
+
+// DeleteCommandRule 删除命令规则
+func (c *CommandRuleController) DeleteCommandRule(ctx *gin.Context) {
+	id := paramID(ctx)
+	if id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceCommandRule, services.ActionDelete, id) {
+		return
+	}
+
+	if err := models.DeleteCommandRule(c.DB, id); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Command rule deleted successfully"})
+}
+
+// GetCommandApprovals 获取等待审批的命令
+func (c *CommandRuleController) GetCommandApprovals(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceSession, services.ActionApprove, 0) {
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+
+	audits, total, err := models.GetCommandAuditList(c.DB, page, pageSize, models.CommandAuditQuery{
+		Status: models.CommandPending,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  audits,
+		"total": total,
+	})
+}
+
+// ApproveCommand 审批通过，命令随即在用户终端中执行
+func (c *CommandRuleController) ApproveCommand(ctx *gin.Context) {
+	c.review(ctx, true)
+}
+
+// RejectCommand 拒绝执行命令
+func (c *CommandRuleController) RejectCommand(ctx *gin.Context) {
+	c.review(ctx, false)
+}
+
+// review 审批命令，不能审批自己提交的命令
+func (c *CommandRuleController) review(ctx *gin.Context, approved bool) {
+	id := paramID(ctx)
+	if id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceSession, services.ActionApprove, 0) {
+		return
+	}
+
+	var req struct {
+		Remark string `json:"remark"`
+	}
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	audit, err := models.GetCommandAudit(c.DB, id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "审批单不存在"})
+		return
+	}
+	if audit.UserID == ctx.GetUint("userID") {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "不能审批自己提交的命令"})
+		return
+	}
+
+	err = services.NewCommandPolicyService(c.DB).Review(id, approved, ctx.GetString("username"), req.Remark)
+	if errors.Is(err, services.ErrCommandReviewed) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := models.GetCommandAudit(c.DB, id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, updated)
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"devops/global"
+	"devops/models"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CredentialController 主机凭据控制器
+type CredentialController struct {
+	DB *gorm.DB
+}
+
+// NewCredentialController 创建主机凭据控制器
+func NewCredentialController() *CredentialController {
+	return &CredentialController{
+		DB: global.DB,
+	}
+}
+
+// GetCredentials 获取凭据列表，敏感字段不返回
+func (c *CredentialController) GetCredentials(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceCredential, services.ActionRead, 0) {
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+	name := ctx.Query("name")
+
+	credentials, total, err := models.GetCredentialList(c.DB, page, pageSize, name)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  credentials,
+		"total": total,
+	})
+}
+
+// CreateCredential 创建凭据
+func (c *CredentialController) CreateCredential(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceCredential, services.ActionCreate, 0) {
+		return
+	}
+
+	var credential models.Credential
+	if err := ctx.ShouldBindJSON(&credential); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateCredential(&credential); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential.ID = 0
+	if err := models.CreateCredential(c.DB, &credential); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, credential)
+}
+
+// UpdateCredential 更新凭据，密码、私钥、密码短语为空时保持原值
+func (c *CredentialController) UpdateCredential(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceCredential, services.ActionUpdate, uint(id)) {
+		return
+	}
+
+	var credential models.Credential
+	if err := ctx.ShouldBindJSON(&credential); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := models.GetCredential(c.DB, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "凭据不存在"})
+		return
+	}
+
+	// 使用合并后的凭据校验，保证未修改的敏感字段与新内容仍然匹配
+	merged := credential
+	if !merged.Password.IsSet() {
+		merged.Password = existing.Password
+	}
+	if !merged.PrivateKey.IsSet() {
+		merged.PrivateKey = existing.PrivateKey
+		if !merged.Passphrase.IsSet() {
+			merged.Passphrase = existing.Passphrase
+		}
+	}
+	if err := services.ValidateCredential(&merged); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.UpdateCredential(c.DB, uint(id), &credential); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if hostIDs, err := models.GetHostIDsByCredential(c.DB, uint(id)); err == nil {
+		for _, hostID := range hostIDs {
+			services.InvalidateSSH(hostID)
+		}
+	}
+
+	updated, err := models.GetCredential(c.DB, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, updated)
+}
+
+// DeleteCredential 删除凭据，仍被主机使用时不允许删除
+func (c *CredentialController) DeleteCredential(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceCredential, services.ActionDelete, uint(id)) {
+		return
+	}
+
+	count, err := models.CountHostsByCredential(c.DB, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count > 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "凭据正在被主机使用，不能删除"})
+		return
+	}
+
+	if err := models.DeleteCredential(c.DB, uint(id)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Credential deleted successfully"})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"devops/global"
 	"devops/models"
+	"devops/services"
 	"net/http"
 	"strconv"
 	"strings"
@@ -28,6 +29,10 @@ func NewDockerRegistryController() *DockerRegistryController {
 
 // CreateDockerRegistry 创建 Docker 镜像仓库
 func (c *DockerRegistryController) CreateDockerRegistry(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceRegistry, services.ActionCreate, 0) {
+		return
+	}
+
 	var registry models.DockerRegistry
 	if err := ctx.ShouldBindJSON(&registry); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -44,6 +49,10 @@ func (c *DockerRegistryController) CreateDockerRegistry(ctx *gin.Context) {
 
 // GetDockerRegistries 获取 Docker 镜像仓库列表
 func (c *DockerRegistryController) GetDockerRegistries(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceRegistry, services.ActionRead, 0) {
+		return
+	}
+
 	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
 	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
 	name := ctx.Query("name")
@@ -67,6 +76,9 @@ func (c *DockerRegistryController) UpdateDockerRegistry(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !checkPermission(ctx, services.ResourceRegistry, services.ActionUpdate, uint(id)) {
+		return
+	}
 
 	var registry models.DockerRegistry
 	if err := ctx.ShouldBindJSON(&registry); err != nil {
@@ -89,6 +101,9 @@ func (c *DockerRegistryController) DeleteDockerRegistry(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !checkPermission(ctx, services.ResourceRegistry, services.ActionDelete, uint(id)) {
+		return
+	}
 
 	if err := models.DeleteDockerRegistry(c.DB, uint(id)); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -100,12 +115,26 @@ func (c *DockerRegistryController) DeleteDockerRegistry(ctx *gin.Context) {
 
 // TestDockerRegistryConnection 测试 Docker 镜像仓库连接
 func (c *DockerRegistryController) TestDockerRegistryConnection(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceRegistry, services.ActionRead, 0) {
+		return
+	}
+
 	var registry models.DockerRegistry
 	if err := ctx.ShouldBindJSON(&registry); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 接口不返回已保存的密码，测试已有仓库且未重新输入密码时使用数据库中的密码
+	if registry.ID != 0 && !registry.Password.IsSet() {
+		stored, err := models.GetDockerRegistry(c.DB, registry.ID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "镜像仓库不存在"})
+			return
+		}
+		registry.Password = stored.Password
+	}
+
 	// 确保 URL 不包含协议前缀
 	url := registry.URL
 	url = strings.TrimPrefix(url, "http://")
@@ -128,7 +157,7 @@ func (c *DockerRegistryController) TestDockerRegistryConnection(ctx *gin.Context
 	// 设置认证信息
 	authConfig := dockerregistry.AuthConfig{
 		Username:      registry.Username,
-		Password:      registry.Password,
+		Password:      registry.Password.String(),
 		ServerAddress: url,
 	}
 
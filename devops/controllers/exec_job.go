@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"devops/global"
+	"devops/models"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// ExecJobController 批量执行控制器
+type ExecJobController struct {
+	DB *gorm.DB
+}
+
+// NewExecJobController 创建批量执行控制器
+func NewExecJobController() *ExecJobController {
+	return &ExecJobController{
+		DB: global.DB,
+	}
+}
+
+// CreateExecJobRequest 批量执行请求，主机按 HostFilter 中的条件选择
+type CreateExecJobRequest struct {
+	models.HostFilter
+	Command     string `json:"command" binding:"required"`
+	Parallelism int    `json:"parallelism"` // 同时执行的主机数，默认 10
+	Timeout     int    `json:"timeout"`     // 每台主机的超时秒数，默认 60
+}
+
+// CreateExecJob 在选中的主机上批量执行命令，需要每台主机的终端权限，命令同样受命令策略约束。
+// 任务在后台执行，返回的任务可通过 stream 接口实时查看输出
+func (c *ExecJobController) CreateExecJob(ctx *gin.Context) {
+	var req CreateExecJobRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Command) == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "命令不能为空"})
+		return
+	}
+	if req.HostFilter.Empty() {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "至少指定一个主机筛选条件"})
+		return
+	}
+	if _, err := models.ParseLabelSelector(req.Selector); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Parallelism == 0 {
+		req.Parallelism = services.DefaultExecParallelism
+	}
+	if req.Parallelism < 1 || req.Parallelism > services.MaxExecParallelism {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("parallelism 必须在 1-%d 之间", services.MaxExecParallelism)})
+		return
+	}
+	timeout := services.DefaultExecTimeout
+	if req.Timeout != 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+	if timeout <= 0 || timeout > services.MaxExecTimeout {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("timeout 必须在 1-%d 秒之间", int(services.MaxExecTimeout.Seconds()))})
+		return
+	}
+
+	hosts, err := models.FindHosts(c.DB, req.HostFilter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(hosts) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "没有符合条件的主机"})
+		return
+	}
+	for _, host := range hosts {
+		if !checkPermission(ctx, services.ResourceHost, services.ActionShell, host.ID) {
+			return
+		}
+	}
+
+	service := services.NewExecService(c.DB)
+	if err := service.CheckPolicy(hosts, req.Command); err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	job := &models.ExecJob{
+		UserID:      ctx.GetUint("userID"),
+		Username:    ctx.GetString("username"),
+		Command:     req.Command,
+		Parallelism: req.Parallelism,
+		Timeout:     int(timeout.Seconds()),
+	}
+	if err := service.Start(job, hosts); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, job)
+}
+
+// GetExecJobs 获取批量执行任务列表，没有 session:read 权限的用户只能看到自己的任务
+func (c *ExecJobController) GetExecJobs(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+
+	query := models.ExecJobQuery{
+		Username: ctx.Query("username"),
+		Status:   ctx.Query("status"),
+	}
+	var err error
+	if query.From, err = parseTimeQuery(ctx, "from"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.To, err = parseTimeQuery(ctx, "to"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	all, err := services.NewAuthzService(c.DB).Authorize(ctx.GetUint("userID"), services.ResourceSession, services.ActionRead, 0)
+	if err != nil {
+		log.Printf("权限校验失败: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "权限校验失败"})
+		return
+	}
+	if !all {
+		query.UserID = ctx.GetUint("userID")
+	}
+
+	jobs, total, err := models.GetExecJobList(c.DB, page, pageSize, query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  jobs,
+		"total": total,
+	})
+}
+
+// GetExecJob 获取任务详情及各主机的执行结果
+func (c *ExecJobController) GetExecJob(ctx *gin.Context) {
+	job, ok := c.loadJob(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// CancelExecJob 取消执行中的任务，只有任务创建者可以取消
+func (c *ExecJobController) CancelExecJob(ctx *gin.Context) {
+	job, ok := c.loadJob(ctx)
+	if !ok {
+		return
+	}
+	if job.UserID != ctx.GetUint("userID") {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有任务创建者可以取消任务"})
+		return
+	}
+	if !services.CancelExecJob(job.ID) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "任务已结束"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Exec job canceled successfully"})
+}
+
+// StreamExecJob 通过 WebSocket 实时推送任务事件（JSON 文本消息）。
+// 先为每台主机推送一条含已有输出的 status 事件，之后推送 output/status 事件，任务结束时推送 done 事件；
+// 已结束的任务直接推送最终结果
+func (c *ExecJobController) StreamExecJob(ctx *gin.Context) {
+	job, ok := c.loadJob(ctx)
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket升级失败: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// 读取客户端消息以便及时发现连接关闭
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	results, events, unsubscribe, live := services.SubscribeExecJob(job.ID)
+	if !live {
+		// 任务已结束，重新读取以取得最终结果
+		if job, err = models.GetExecJob(c.DB, job.ID); err != nil {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "读取任务失败"))
+			return
+		}
+		results = job.Results
+	} else {
+		defer unsubscribe()
+	}
+
+	for i := range results {
+		if err := conn.WriteJSON(services.ExecEvent{Type: "status", Result: &results[i]}); err != nil {
+			return
+		}
+	}
+
+	if !live {
+		job.Results = nil
+		conn.WriteJSON(services.ExecEvent{Type: "done", Job: job})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// 未收到 done 事件说明接收过慢被断开
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "接收过慢，请重新连接"))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+			if event.Type == "done" {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// loadJob 读取路径中的任务并校验查看权限：本人的任务或拥有 session:read 权限
+func (c *ExecJobController) loadJob(ctx *gin.Context) (*models.ExecJob, bool) {
+	id := paramID(ctx)
+	if id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	job, err := models.GetExecJob(c.DB, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+
+	if job.UserID != ctx.GetUint("userID") &&
+		!checkPermission(ctx, services.ResourceSession, services.ActionRead, 0) {
+		return nil, false
+	}
+	return job, true
+}
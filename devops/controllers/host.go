@@ -1,45 +1,82 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
 
+	"devops/config"
 	"devops/global"
 	"devops/models"
+	"devops/services"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// maxKnownHostsSize 导入 known_hosts 的大小上限
+const maxKnownHostsSize = 4 << 20
+
 // CreateHost 添加主机
 func CreateHost(c *gin.Context) {
+	if !checkPermission(c, services.ResourceHost, services.ActionCreate, 0) {
+		return
+	}
+
 	var host models.Host
 	if err := c.ShouldBindJSON(&host); err != nil {
 		log.Printf("绑定JSON失败: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !checkHostAddress(c, &host) || !checkHostCredential(c, &host) || !checkJumpHosts(c, &host, 0) || !checkHostGroupsAndLabels(c, &host) {
+		return
+	}
 
 	if err := models.CreateHost(global.DB, &host); err != nil {
 		log.Printf("创建主机失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	services.NewFactsService(global.DB).RefreshInBackground(host.ID)
 
-	c.JSON(http.StatusOK, host)
+	respondHost(c, host.ID)
 }
 
 // GetHosts 获取主机列表
 func GetHosts(c *gin.Context) {
+	if !checkPermission(c, services.ResourceHost, services.ActionRead, 0) {
+		return
+	}
+
 	log.Printf("开始获取主机列表")
 
 	page, _ := strconv.Atoi(c.DefaultQuery("current", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
-	name := c.Query("name")
-	ip := c.Query("ip")
+	filter := models.HostFilter{
+		Name:        c.Query("name"),
+		IP:          c.Query("ip"),
+		Environment: c.Query("environment"),
+		Selector:    c.Query("selector"),
+	}
+	if groupID := c.Query("groupId"); groupID != "" {
+		id, err := strconv.ParseUint(groupID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分组ID"})
+			return
+		}
+		filter.GroupIDs = []uint{uint(id)}
+	}
+	if _, err := models.ParseLabelSelector(filter.Selector); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	log.Printf("查询参数: page=%d, pageSize=%d, name=%s, ip=%s", page, pageSize, name, ip)
+	log.Printf("查询参数: page=%d, pageSize=%d, name=%s, ip=%s, selector=%s", page, pageSize, filter.Name, filter.IP, filter.Selector)
 
-	hosts, total, err := models.GetHostList(global.DB, page, pageSize, name, ip)
+	hosts, total, err := models.GetHostList(global.DB, page, pageSize, filter)
 	if err != nil {
 		log.Printf("获取主机列表失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -61,6 +98,9 @@ func UpdateHost(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !checkPermission(c, services.ResourceHost, services.ActionUpdate, uint(id)) {
+		return
+	}
 
 	var host models.Host
 	if err := c.ShouldBindJSON(&host); err != nil {
@@ -68,14 +108,18 @@ func UpdateHost(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !checkHostAddress(c, &host) || !checkHostCredential(c, &host) || !checkJumpHosts(c, &host, uint(id)) || !checkHostGroupsAndLabels(c, &host) {
+		return
+	}
 
 	if err := models.UpdateHost(global.DB, uint(id), &host); err != nil {
 		log.Printf("更新主机失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	services.InvalidateSSH(uint(id))
 
-	c.JSON(http.StatusOK, host)
+	respondHost(c, uint(id))
 }
 
 // DeleteHost 删除主机
@@ -86,12 +130,311 @@ func DeleteHost(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !checkPermission(c, services.ResourceHost, services.ActionDelete, uint(id)) {
+		return
+	}
+
+	users, err := models.GetHostsUsingJumpHost(global.DB, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(users) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("主机正被 %s 等 %d 台主机用作跳板机，不能删除", users[0].Name, len(users))})
+		return
+	}
 
 	if err := models.DeleteHost(global.DB, uint(id)); err != nil {
 		log.Printf("删除主机失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	services.InvalidateSSH(uint(id))
 
 	c.JSON(http.StatusOK, gin.H{"message": "Host deleted successfully"})
 }
+
+// respondHost 返回数据库中保存的主机信息
+func respondHost(c *gin.Context, id uint) {
+	host, err := models.GetHostByID(global.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, host)
+}
+
+// checkHostAddress 校验主机地址与端口并将地址转为规范形式，校验失败时写入响应并返回 false
+func checkHostAddress(c *gin.Context, host *models.Host) bool {
+	address, err := services.NormalizeHostAddress(host.IP)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if host.Port < 1 || host.Port > 65535 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "端口必须在1-65535之间"})
+		return false
+	}
+	host.IP = address
+	return true
+}
+
+// checkHostCredential 校验主机关联的凭据存在，且当前用户对该凭据有读取权限，不能借用无权访问的凭据登录主机。
+// 校验失败时写入响应并返回 false
+func checkHostCredential(c *gin.Context, host *models.Host) bool {
+	host.Credential = nil
+	if host.CredentialID == nil {
+		return true
+	}
+	if !checkPermission(c, services.ResourceCredential, services.ActionRead, *host.CredentialID) {
+		return false
+	}
+	if _, err := models.GetCredential(global.DB, *host.CredentialID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "凭据不存在"})
+		return false
+	}
+	return true
+}
+
+// checkHostGroupsAndLabels 校验提交的分组存在且标签合法，校验失败时写入响应并返回 false
+func checkHostGroupsAndLabels(c *gin.Context, host *models.Host) bool {
+	for _, groupID := range host.GroupIDs {
+		if _, err := models.GetHostGroup(global.DB, groupID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("分组 %d 不存在", groupID)})
+			return false
+		}
+	}
+	if err := models.ValidateLabels(host.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// GetHostFacts 获取主机最新的信息快照，?history=true 时返回保留的全部快照
+func GetHostFacts(c *gin.Context) {
+	id := paramID(c)
+	if !checkPermission(c, services.ResourceHost, services.ActionRead, id) {
+		return
+	}
+	if _, err := models.GetHostByID(global.DB, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return
+	}
+
+	if c.Query("history") == "true" {
+		history, err := models.GetHostFactsHistory(global.DB, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"list":  history,
+			"total": len(history),
+		})
+		return
+	}
+
+	facts, err := models.GetLatestHostFacts(global.DB, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "尚未采集主机信息"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, facts)
+}
+
+// RefreshHostFacts 立即通过 SSH 采集主机信息，返回新的快照
+func RefreshHostFacts(c *gin.Context) {
+	id := paramID(c)
+	if !checkPermission(c, services.ResourceHost, services.ActionUpdate, id) {
+		return
+	}
+
+	host, err := models.GetHostByID(global.DB, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return
+	}
+
+	facts, err := services.NewFactsService(global.DB).Refresh(c.Request.Context(), host)
+	if errors.Is(err, services.ErrFactsRefreshing) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("采集主机信息失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "采集主机信息失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, facts)
+}
+
+// GetHostKey 获取主机密钥及待确认密钥
+func GetHostKey(c *gin.Context) {
+	id := paramID(c)
+	if !checkPermission(c, services.ResourceHost, services.ActionRead, id) {
+		return
+	}
+
+	host, err := models.GetHostByID(global.DB, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hostKey":                   host.HostKey,
+		"hostKeyFingerprint":        host.HostKeyFingerprint,
+		"hostKeyVerifiedAt":         host.HostKeyVerifiedAt,
+		"pendingHostKey":            host.PendingHostKey,
+		"pendingHostKeyFingerprint": host.PendingHostKeyFingerprint,
+		"policy":                    config.Conf.SSH.HostKeyPolicy,
+	})
+}
+
+// AcceptHostKey 接受待确认的主机密钥，需提交指纹以确认接受的是核实过的密钥
+func AcceptHostKey(c *gin.Context) {
+	id := paramID(c)
+	if !checkPermission(c, services.ResourceHost, services.ActionUpdate, id) {
+		return
+	}
+
+	var req struct {
+		Fingerprint string `json:"fingerprint" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	host, err := models.GetHostByID(global.DB, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return
+	}
+	if host.PendingHostKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "没有待确认的主机密钥"})
+		return
+	}
+	if req.Fingerprint != host.PendingHostKeyFingerprint {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "指纹与待确认的主机密钥不一致"})
+		return
+	}
+
+	if err := models.SaveHostKey(global.DB, id, host.PendingHostKey, host.PendingHostKeyFingerprint); err != nil {
+		log.Printf("接受主机密钥失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	services.InvalidateSSH(id)
+
+	log.Printf("用户 %s 接受了主机 %s 的新密钥 %s", c.GetString("username"), host.Name, req.Fingerprint)
+	c.JSON(http.StatusOK, gin.H{"message": "Host key accepted"})
+}
+
+// ResetHostKey 清除主机密钥，下次连接时按策略重新记录
+func ResetHostKey(c *gin.Context) {
+	id := paramID(c)
+	if !checkPermission(c, services.ResourceHost, services.ActionUpdate, id) {
+		return
+	}
+
+	if _, err := models.GetHostByID(global.DB, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return
+	}
+	if err := models.ResetHostKey(global.DB, id); err != nil {
+		log.Printf("重置主机密钥失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	services.InvalidateSSH(id)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Host key reset"})
+}
+
+// ExportKnownHosts 以 known_hosts 格式导出全部主机密钥
+func ExportKnownHosts(c *gin.Context) {
+	if !checkPermission(c, services.ResourceHost, services.ActionRead, 0) {
+		return
+	}
+
+	content, err := services.NewSSHService(global.DB).ExportKnownHosts()
+	if err != nil {
+		log.Printf("导出 known_hosts 失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=known_hosts")
+	c.String(http.StatusOK, content)
+}
+
+// ImportKnownHosts 导入 known_hosts，请求体为文件内容或 multipart 的 file 字段；overwrite=true 时覆盖不一致的密钥
+func ImportKnownHosts(c *gin.Context) {
+	if !checkPermission(c, services.ResourceHost, services.ActionUpdate, 0) {
+		return
+	}
+
+	var content []byte
+	if c.ContentType() == "multipart/form-data" {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "未找到上传的文件"})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		content, err = io.ReadAll(io.LimitReader(f, maxKnownHostsSize))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		var err error
+		content, err = io.ReadAll(io.LimitReader(c.Request.Body, maxKnownHostsSize))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if len(content) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "known_hosts 内容不能为空"})
+		return
+	}
+
+	overwrite := c.Query("overwrite") == "true"
+	result, err := services.NewSSHService(global.DB).ImportKnownHosts(content, overwrite)
+	if err != nil {
+		log.Printf("导入 known_hosts 失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// checkJumpHosts 校验跳板机链存在且无循环，且当前用户对每台跳板机拥有终端权限，校验失败时写入响应并返回 false。
+// 经跳板机连接会使用跳板机保存的登录凭据，不能允许用户借用无权使用的跳板机
+func checkJumpHosts(c *gin.Context, host *models.Host, id uint) bool {
+	for _, hopID := range host.JumpHostIDs {
+		if !checkPermission(c, services.ResourceHost, services.ActionShell, hopID) {
+			return false
+		}
+	}
+	candidate := *host
+	candidate.ID = id
+	if _, err := services.NewSSHService(global.DB).Route(&candidate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"devops/global"
+	"devops/models"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// HostGroupController 主机分组与标签控制器
+type HostGroupController struct {
+	DB *gorm.DB
+}
+
+// NewHostGroupController 创建主机分组控制器
+func NewHostGroupController() *HostGroupController {
+	return &HostGroupController{
+		DB: global.DB,
+	}
+}
+
+// GetHostGroups 获取全部主机分组，按路径排序
+func (c *HostGroupController) GetHostGroups(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceHost, services.ActionRead, 0) {
+		return
+	}
+
+	groups, err := models.GetHostGroupList(c.DB)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  groups,
+		"total": len(groups),
+	})
+}
+
+// CreateHostGroup 创建主机分组
+func (c *HostGroupController) CreateHostGroup(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceHost, services.ActionCreate, 0) {
+		return
+	}
+
+	var group models.HostGroup
+	if err := ctx.ShouldBindJSON(&group); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	group.ID = 0
+	if !c.checkGroup(ctx, &group) {
+		return
+	}
+
+	if err := models.CreateHostGroup(c.DB, &group); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, group)
+}
+
+// UpdateHostGroup 更新主机分组，可通过 parentId 移动到其他分组下
+func (c *HostGroupController) UpdateHostGroup(ctx *gin.Context) {
+	id := paramID(ctx)
+	if id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceHost, services.ActionUpdate, 0) {
+		return
+	}
+
+	existing, err := models.GetHostGroup(c.DB, id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "分组不存在"})
+		return
+	}
+
+	// 未提交的字段保持原值
+	group := *existing
+	if err := ctx.ShouldBindJSON(&group); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	group.ID = id
+	if !c.checkGroup(ctx, &group) {
+		return
+	}
+
+	if err := models.UpdateHostGroup(c.DB, id, &group); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := models.GetHostGroup(c.DB, id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, updated)
+}
+
+// DeleteHostGroup 删除主机分组，分组下的主机不会被删除；存在下级分组时不能删除
+func (c *HostGroupController) DeleteHostGroup(ctx *gin.Context) {
+	id := paramID(ctx)
+	if id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceHost, services.ActionDelete, 0) {
+		return
+	}
+
+	if _, err := models.GetHostGroup(c.DB, id); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "分组不存在"})
+		return
+	}
+	children, err := models.CountHostGroupChildren(c.DB, id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if children > 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "分组下还有子分组，不能删除"})
+		return
+	}
+
+	if err := models.DeleteHostGroup(c.DB, id); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Host group deleted successfully"})
+}
+
+// GetHostLabels 获取主机正在使用的标签键及其取值，供编写标签选择器时参考
+func (c *HostGroupController) GetHostLabels(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceHost, services.ActionRead, 0) {
+		return
+	}
+
+	keys, err := models.GetLabelKeys(c.DB)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  keys,
+		"total": len(keys),
+	})
+}
+
+// checkGroup 校验分组名称与上级分组：名称不能包含 /，同级不能重名，上级分组必须存在且不能是自身或下级分组。
+// 校验失败时写入响应并返回 false
+func (c *HostGroupController) checkGroup(ctx *gin.Context, group *models.HostGroup) bool {
+	group.Name = strings.TrimSpace(group.Name)
+	if group.Name == "" || strings.Contains(group.Name, "/") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "分组名称不能为空且不能包含 /"})
+		return false
+	}
+
+	if group.ParentID != nil {
+		if _, err := models.GetHostGroup(c.DB, *group.ParentID); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "上级分组不存在"})
+			return false
+		}
+		if group.ID != 0 {
+			descendants, err := models.GetHostGroupDescendants(c.DB, []uint{group.ID})
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return false
+			}
+			for _, id := range descendants {
+				if id == *group.ParentID {
+					ctx.JSON(http.StatusBadRequest, gin.H{"error": "不能移动到自身或下级分组下"})
+					return false
+				}
+			}
+		}
+	}
+
+	exists, err := models.HostGroupNameExists(c.DB, group.ParentID, group.Name, group.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if exists {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "同一上级分组下已有同名分组"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"devops/global"
+	"devops/models"
+)
+
+// useTestDB 把 global.DB 替换为临时的 SQLite 数据库并建表，测试结束后恢复
+func useTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	previous := global.DB
+	global.DB = db
+	t.Cleanup(func() { global.DB = previous })
+	return db
+}
+
+func TestCheckHostCredential(t *testing.T) {
+	db := useTestDB(t, &models.Role{}, &models.RoleBinding{}, &models.Credential{})
+
+	hostAdmin := &models.Role{Name: "host-admin", Permissions: []string{"host:*"}}
+	credentialReader := &models.Role{Name: "credential-reader", Permissions: []string{"credential:read"}}
+	for _, role := range []*models.Role{hostAdmin, credentialReader} {
+		if err := models.CreateRole(db, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var credentials [2]models.Credential
+	for i := range credentials {
+		credentials[i] = models.Credential{Name: "deploy", Type: "password", Username: "deploy"}
+		if err := models.CreateCredential(db, &credentials[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	allowed, denied := credentials[0].ID, credentials[1].ID
+
+	// 用户 1 只能管理主机，用户 2 还能读取凭据 allowed，用户 3 能读取全部凭据
+	bindings := []models.RoleBinding{
+		{UserID: 1, RoleID: hostAdmin.ID},
+		{UserID: 2, RoleID: hostAdmin.ID},
+		{UserID: 2, RoleID: credentialReader.ID, ResourceType: "credential", ResourceID: allowed},
+		{UserID: 3, RoleID: hostAdmin.ID},
+		{UserID: 3, RoleID: credentialReader.ID},
+	}
+	for i := range bindings {
+		if err := models.CreateRoleBinding(db, &bindings[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	missing := uint(100)
+	tests := []struct {
+		name         string
+		userID       uint
+		credentialID *uint
+		ok           bool
+		status       int
+	}{
+		{name: "不关联凭据", userID: 1, credentialID: nil, ok: true},
+		{name: "没有凭据权限", userID: 1, credentialID: &allowed, status: http.StatusForbidden},
+		{name: "有该凭据的权限", userID: 2, credentialID: &allowed, ok: true},
+		{name: "只有其他凭据的权限", userID: 2, credentialID: &denied, status: http.StatusForbidden},
+		{name: "有全部凭据的权限", userID: 3, credentialID: &denied, ok: true},
+		{name: "无权限时不透露凭据是否存在", userID: 1, credentialID: &missing, status: http.StatusForbidden},
+		{name: "凭据不存在", userID: 3, credentialID: &missing, status: http.StatusBadRequest},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userID", tt.userID)
+
+			host := &models.Host{Name: "web-1", CredentialID: tt.credentialID}
+			if ok := checkHostCredential(c, host); ok != tt.ok {
+				t.Fatalf("checkHostCredential() = %v，期望 %v，响应 %d %s", ok, tt.ok, w.Code, w.Body.String())
+			}
+			if !tt.ok && w.Code != tt.status {
+				t.Errorf("响应状态码为 %d，期望 %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
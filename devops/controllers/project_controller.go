@@ -3,6 +3,7 @@ package controllers
 import (
 	"devops/global"
 	"devops/models"
+	"devops/services"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"net/http"
@@ -23,6 +24,10 @@ func NewProjectController() *ProjectController {
 
 // CreateProject 创建项目
 func (c *ProjectController) CreateProject(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceProject, services.ActionCreate, 0) {
+		return
+	}
+
 	var project models.Project
 	if err := ctx.ShouldBindJSON(&project); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -30,8 +35,8 @@ func (c *ProjectController) CreateProject(ctx *gin.Context) {
 	}
 
 	// 设置创建人
-	project.CreatedBy = ctx.GetUint("user_id")
-	project.UpdatedBy = ctx.GetUint("user_id")
+	project.CreatedBy = ctx.GetUint("userID")
+	project.UpdatedBy = ctx.GetUint("userID")
 
 	if err := c.DB.Create(&project).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -46,6 +51,10 @@ func (c *ProjectController) CreateProject(ctx *gin.Context) {
 
 // GetProjects 获取项目列表
 func (c *ProjectController) GetProjects(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceProject, services.ActionRead, 0) {
+		return
+	}
+
 	var projects []models.Project
 	query := c.DB.Model(&models.Project{})
 
@@ -84,6 +93,9 @@ func (c *ProjectController) GetProjects(ctx *gin.Context) {
 // GetProject 获取项目详情
 func (c *ProjectController) GetProject(ctx *gin.Context) {
 	id := ctx.Param("id")
+	if !checkPermission(ctx, services.ResourceProject, services.ActionRead, paramID(ctx)) {
+		return
+	}
 	var project models.Project
 
 	if err := c.DB.Preload("Repository").Preload("Registry").
@@ -98,6 +110,9 @@ func (c *ProjectController) GetProject(ctx *gin.Context) {
 // UpdateProject 更新项目
 func (c *ProjectController) UpdateProject(ctx *gin.Context) {
 	id := ctx.Param("id")
+	if !checkPermission(ctx, services.ResourceProject, services.ActionUpdate, paramID(ctx)) {
+		return
+	}
 	var project models.Project
 
 	if err := c.DB.First(&project, id).Error; err != nil {
@@ -111,7 +126,7 @@ func (c *ProjectController) UpdateProject(ctx *gin.Context) {
 	}
 
 	// 设置更新人
-	project.UpdatedBy = ctx.GetUint("user_id")
+	project.UpdatedBy = ctx.GetUint("userID")
 
 	if err := c.DB.Save(&project).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -127,6 +142,9 @@ func (c *ProjectController) UpdateProject(ctx *gin.Context) {
 // DeleteProject 删除项目
 func (c *ProjectController) DeleteProject(ctx *gin.Context) {
 	id := ctx.Param("id")
+	if !checkPermission(ctx, services.ResourceProject, services.ActionDelete, paramID(ctx)) {
+		return
+	}
 	if err := c.DB.Delete(&models.Project{}, id).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
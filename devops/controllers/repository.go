@@ -23,6 +23,10 @@ func NewRepositoryController() *RepositoryController {
 
 // CreateRepository 创建仓库
 func (c *RepositoryController) CreateRepository(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceRepository, services.ActionCreate, 0) {
+		return
+	}
+
 	var repo models.Repository
 	if err := ctx.ShouldBindJSON(&repo); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -39,6 +43,10 @@ func (c *RepositoryController) CreateRepository(ctx *gin.Context) {
 
 // GetRepositories 获取仓库列表
 func (c *RepositoryController) GetRepositories(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceRepository, services.ActionRead, 0) {
+		return
+	}
+
 	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
 	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
 	name := ctx.Query("name")
@@ -63,6 +71,9 @@ func (c *RepositoryController) UpdateRepository(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !checkPermission(ctx, services.ResourceRepository, services.ActionUpdate, uint(id)) {
+		return
+	}
 
 	var repo models.Repository
 	if err := ctx.ShouldBindJSON(&repo); err != nil {
@@ -85,6 +96,9 @@ func (c *RepositoryController) DeleteRepository(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !checkPermission(ctx, services.ResourceRepository, services.ActionDelete, uint(id)) {
+		return
+	}
 
 	if err := models.DeleteRepository(c.service.DB, uint(id)); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -101,6 +115,9 @@ func (c *RepositoryController) GetBranches(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !checkPermission(ctx, services.ResourceRepository, services.ActionRead, uint(id)) {
+		return
+	}
 
 	repo, err := models.GetRepository(c.service.DB, uint(id))
 	if err != nil {
@@ -124,6 +141,9 @@ func (c *RepositoryController) GetCommits(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !checkPermission(ctx, services.ResourceRepository, services.ActionRead, uint(id)) {
+		return
+	}
 
 	branch := ctx.Query("branch")
 
@@ -149,6 +169,9 @@ func (c *RepositoryController) GetFiles(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !checkPermission(ctx, services.ResourceRepository, services.ActionRead, uint(id)) {
+		return
+	}
 
 	path := ctx.Query("path")
 
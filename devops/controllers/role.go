@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"devops/global"
+	"devops/models"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RoleController 角色控制器
+type RoleController struct {
+	DB *gorm.DB
+}
+
+// NewRoleController 创建角色控制器
+func NewRoleController() *RoleController {
+	return &RoleController{
+		DB: global.DB,
+	}
+}
+
+// GetRoles 获取角色列表
+func (c *RoleController) GetRoles(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceRole, services.ActionRead, 0) {
+		return
+	}
+
+	roles, err := models.GetRoleList(c.DB)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  roles,
+		"total": len(roles),
+	})
+}
+
+// CreateRole 创建角色
+func (c *RoleController) CreateRole(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceRole, services.ActionCreate, 0) {
+		return
+	}
+
+	var role models.Role
+	if err := ctx.ShouldBindJSON(&role); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if role.Name == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "角色名称不能为空"})
+		return
+	}
+	if !validPermissions(ctx, role.Permissions) {
+		return
+	}
+
+	role.ID = 0
+	role.BuiltIn = false
+	if err := models.CreateRole(c.DB, &role); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, role)
+}
+
+// UpdateRole 更新角色描述与权限
+func (c *RoleController) UpdateRole(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceRole, services.ActionUpdate, uint(id)) {
+		return
+	}
+
+	var role models.Role
+	if err := ctx.ShouldBindJSON(&role); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validPermissions(ctx, role.Permissions) {
+		return
+	}
+
+	existing, err := models.GetRoleByID(c.DB, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+	if existing.Name == services.RoleAdmin {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "不能修改管理员角色的权限"})
+		return
+	}
+
+	if err := models.UpdateRole(c.DB, uint(id), &role); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing.Description = role.Description
+	existing.Permissions = role.Permissions
+	ctx.JSON(http.StatusOK, existing)
+}
+
+// DeleteRole 删除角色
+func (c *RoleController) DeleteRole(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceRole, services.ActionDelete, uint(id)) {
+		return
+	}
+
+	role, err := models.GetRoleByID(c.DB, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+	if role.BuiltIn {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "内置角色不能删除"})
+		return
+	}
+
+	if err := models.DeleteRole(c.DB, uint(id)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// GetRoleBindings 获取角色绑定列表
+func (c *RoleController) GetRoleBindings(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceRole, services.ActionRead, 0) {
+		return
+	}
+
+	userID, _ := strconv.ParseUint(ctx.Query("userId"), 10, 32)
+	bindings, err := models.GetRoleBindingList(c.DB, uint(userID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  bindings,
+		"total": len(bindings),
+	})
+}
+
+// CreateRoleBinding 为用户授予角色
+func (c *RoleController) CreateRoleBinding(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceRole, services.ActionBind, 0) {
+		return
+	}
+
+	var binding models.RoleBinding
+	if err := ctx.ShouldBindJSON(&binding); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if binding.ResourceID != 0 && binding.ResourceType == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "指定资源ID时必须指定资源类型"})
+		return
+	}
+	if _, err := models.GetUserByID(c.DB, binding.UserID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "用户不存在"})
+		return
+	}
+	if _, err := models.GetRoleByID(c.DB, binding.RoleID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "角色不存在"})
+		return
+	}
+
+	binding.ID = 0
+	binding.User = nil
+	binding.Role = nil
+	if err := models.CreateRoleBinding(c.DB, &binding); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, binding)
+}
+
+// DeleteRoleBinding 撤销角色绑定
+func (c *RoleController) DeleteRoleBinding(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceRole, services.ActionBind, 0) {
+		return
+	}
+
+	if err := models.DeleteRoleBinding(c.DB, uint(id)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Role binding deleted successfully"})
+}
+
+// validPermissions 校验权限列表格式
+func validPermissions(ctx *gin.Context, permissions []string) bool {
+	for _, perm := range permissions {
+		if !services.ValidPermission(perm) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的权限: " + perm})
+			return false
+		}
+	}
+	return true
+}
@@ -1,23 +1,22 @@
 package controllers
 
 import (
-	"archive/zip"
+	"bufio"
 	"devops/global"
 	"devops/models"
+	"devops/services"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
 )
 
 type SftpFileInfo struct {
@@ -37,46 +36,45 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// 获取SFTP文件列表
-func GetSftpFiles(c *gin.Context) {
-	hostID := c.Param("id")
-	path := c.Query("path")
-	if path == "" {
-		path = "/"
-	}
-
-	// 获取主机信息
+// acquireSftp 加载主机并从连接池借用 SFTP 客户端，失败时写入响应；成功时调用方需要释放返回的租约
+func acquireSftp(c *gin.Context, hostID string) (*sftp.Client, *services.SSHLease, bool) {
 	var host models.Host
-	if err := global.DB.First(&host, hostID).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "主机不存在"})
-		return
-	}
-
-	// 创建SSH客户端
-	sshConfig := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
+	if err := global.DB.Preload("Credential").First(&host, hostID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return nil, nil, false
 	}
 
-	// 连接SSH
-	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.IP, host.Port), sshConfig)
+	lease, err := services.AcquireSSH(c.Request.Context(), &host)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SSH连接失败: %v", err)})
-		return
+		return nil, nil, false
 	}
-	defer sshClient.Close()
 
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(sshClient)
+	sftpClient, err := lease.SFTP()
 	if err != nil {
+		lease.Release()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SFTP连接失败: %v", err)})
+		return nil, nil, false
+	}
+	return sftpClient, lease, true
+}
+
+// 获取SFTP文件列表
+func GetSftpFiles(c *gin.Context) {
+	hostID := c.Param("id")
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
+		return
+	}
+	path := c.Query("path")
+	if path == "" {
+		path = "/"
+	}
+
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
 		return
 	}
-	defer sftpClient.Close()
+	defer lease.Release()
 
 	// 获取文件列表
 	files, err := sftpClient.ReadDir(path)
@@ -113,6 +111,9 @@ func GetSftpFiles(c *gin.Context) {
 // 上传文件到SFTP
 func UploadSftpFile(c *gin.Context) {
 	hostID := c.Param("id")
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
+		return
+	}
 	path := c.PostForm("path")
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -125,35 +126,11 @@ func UploadSftpFile(c *gin.Context) {
 		return
 	}
 
-	var host models.Host
-	if err := global.DB.First(&host, hostID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
-		return
-	}
-
-	// 创建SSH连接
-	sshConfig := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.IP, host.Port), sshConfig)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SSH连接失败: %v", err)})
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
 		return
 	}
-	defer client.Close()
-
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(client)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SFTP客户端创建失败: %v", err)})
-		return
-	}
-	defer sftpClient.Close()
+	defer lease.Release()
 
 	// 打开上传的文件
 	src, err := file.Open()
@@ -181,47 +158,24 @@ func UploadSftpFile(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "上传成功"})
 }
 
-// 从SFTP下载文件
+// DownloadSftpFile 从SFTP下载文件，支持 Range 与 If-Range 请求（206 Partial Content），ETag 由文件大小与修改时间生成，
+// 浏览器与 curl -C - 可以断点续传，也可以只读取大文件的末尾
 func DownloadSftpFile(c *gin.Context) {
 	hostID := c.Param("id")
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
+		return
+	}
 	filePath := c.Query("path")
 	if filePath == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "文件路径不能为空"})
 		return
 	}
 
-	// 获取主机信息
-	var host models.Host
-	if err := global.DB.First(&host, hostID).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "主机不存在"})
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
 		return
 	}
-
-	// 创建SSH客户端
-	sshConfig := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
-	}
-
-	// 连接SSH
-	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.IP, host.Port), sshConfig)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SSH连接失败: %v", err)})
-		return
-	}
-	defer sshClient.Close()
-
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(sshClient)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SFTP连接失败: %v", err)})
-		return
-	}
-	defer sftpClient.Close()
+	defer lease.Release()
 
 	// 打开远程文件
 	srcFile, err := sftpClient.Open(filePath)
@@ -237,63 +191,65 @@ func DownloadSftpFile(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取文件信息失败: %v", err)})
 		return
 	}
+	if !fileInfo.Mode().IsRegular() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "路径不是文件"})
+		return
+	}
 
-	// 设置响应头
+	// 设置响应头，Content-Length、Content-Range 与 Last-Modified 由 http.ServeContent 设置
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Transfer-Encoding", "binary")
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(filePath)))
 	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("ETag", fmt.Sprintf(`"%x-%x"`, fileInfo.ModTime().Unix(), fileInfo.Size()))
 
-	// 发送文件内容
-	_, err = io.Copy(c.Writer, srcFile)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("下载文件失败: %v", err)})
-		return
+	http.ServeContent(c.Writer, c.Request, fileInfo.Name(), fileInfo.ModTime(), newBufferedFile(srcFile))
+}
+
+// sftpReadAhead 下载时每次从 SFTP 读取的字节数，较大的读取会拆分为并发请求，减少高延迟链路上的往返次数
+const sftpReadAhead = 1 << 20
+
+// bufferedFile 为 sftp.File 增加预读缓冲，Seek 时丢弃缓冲的内容
+type bufferedFile struct {
+	file   *sftp.File
+	reader *bufio.Reader
+}
+
+func newBufferedFile(file *sftp.File) *bufferedFile {
+	return &bufferedFile{file: file, reader: bufio.NewReaderSize(file, sftpReadAhead)}
+}
+
+func (f *bufferedFile) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+func (f *bufferedFile) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekCurrent {
+		offset -= int64(f.reader.Buffered())
 	}
+	pos, err := f.file.Seek(offset, whence)
+	f.reader.Reset(f.file)
+	return pos, err
 }
 
 // 删除SFTP文件
 func DeleteSftpFile(c *gin.Context) {
 	hostID := c.Param("id")
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
+		return
+	}
 	filePath := c.Query("path")
 	if filePath == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "文件路径不能为空"})
 		return
 	}
 
-	// 获取主机信息
-	var host models.Host
-	if err := global.DB.First(&host, hostID).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "主机不存在"})
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
 		return
 	}
-
-	// 创建SSH客户端
-	sshConfig := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
-	}
-
-	// 连接SSH
-	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.IP, host.Port), sshConfig)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SSH连接失败: %v", err)})
-		return
-	}
-	defer sshClient.Close()
-
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(sshClient)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SFTP连接失败: %v", err)})
-		return
-	}
-	defer sftpClient.Close()
+	defer lease.Release()
 
 	// 获取文件信息
 	fileInfo, err := sftpClient.Stat(filePath)
@@ -322,6 +278,9 @@ func DeleteSftpFile(c *gin.Context) {
 // 重命名SFTP文件
 func RenameSftpFile(c *gin.Context) {
 	hostID := c.Param("id")
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
+		return
+	}
 	oldPath := c.Query("oldPath")
 	newPath := c.Query("newPath")
 	if oldPath == "" || newPath == "" {
@@ -329,41 +288,14 @@ func RenameSftpFile(c *gin.Context) {
 		return
 	}
 
-	// 获取主机信息
-	var host models.Host
-	if err := global.DB.First(&host, hostID).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "主机不存在"})
-		return
-	}
-
-	// 创建SSH客户端
-	sshConfig := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
-	}
-
-	// 连接SSH
-	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.IP, host.Port), sshConfig)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SSH连接失败: %v", err)})
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
 		return
 	}
-	defer sshClient.Close()
-
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(sshClient)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SFTP连接失败: %v", err)})
-		return
-	}
-	defer sftpClient.Close()
+	defer lease.Release()
 
 	// 重命名文件
-	err = sftpClient.Rename(oldPath, newPath)
+	err := sftpClient.Rename(oldPath, newPath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("重命名失败: %v", err)})
 		return
@@ -374,459 +306,166 @@ func RenameSftpFile(c *gin.Context) {
 	})
 }
 
-// 下载SFTP目录（压缩）
+// DownloadSftpDir 将SFTP目录打包下载，format 可选 zip（默认）或 tar.gz。
+// 边遍历远程目录边写入响应，不在服务器上暂存；打包中途出错时通过 X-Archive-Error 响应尾部返回错误
 func DownloadSftpDir(c *gin.Context) {
 	hostID := c.Param("id")
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
+		return
+	}
 	dirPath := c.Query("path")
 	if dirPath == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "目录路径不能为空"})
 		return
 	}
-
-	// 获取主机信息
-	var host models.Host
-	if err := global.DB.First(&host, hostID).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "主机不存在"})
-		return
-	}
-
-	// 创建SSH客户端
-	sshConfig := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
-	}
-
-	// 连接SSH
-	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.IP, host.Port), sshConfig)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SSH连接失败: %v", err)})
-		return
-	}
-	defer sshClient.Close()
-
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(sshClient)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SFTP连接失败: %v", err)})
-		return
-	}
-	defer sftpClient.Close()
-
-	// 创建临时目录
-	tempDir, err := os.MkdirTemp("", "sftp-download-*")
+	format, err := services.ParseArchiveFormat(c.Query("format"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建临时目录失败: %v", err)})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer os.RemoveAll(tempDir)
 
-	// 下载目录内容到临时目录
-	err = downloadDir(sftpClient, dirPath, tempDir)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("下载目录失败: %v", err)})
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
 		return
 	}
+	defer lease.Release()
 
-	// 创建ZIP文件
-	zipPath := filepath.Join(tempDir, "download.zip")
-	zipFile, err := os.Create(zipPath)
+	info, err := sftpClient.Stat(dirPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建ZIP文件失败: %v", err)})
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取目录信息失败: %v", err)})
 		return
 	}
-	defer zipFile.Close()
-
-	// 创建ZIP写入器
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	// 将目录内容添加到ZIP文件
-	err = addDirToZip(zipWriter, tempDir, "")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建ZIP文件失败: %v", err)})
+	if !info.IsDir() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "路径不是目录"})
 		return
 	}
 
-	// 关闭ZIP写入器
-	err = zipWriter.Close()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("关闭ZIP文件失败: %v", err)})
-		return
+	name := path.Base(dirPath)
+	if name == "/" || name == "." {
+		name = "root"
 	}
-
-	// 读取ZIP文件内容
-	zipData, err := os.ReadFile(zipPath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("读取ZIP文件失败: %v", err)})
-		return
-	}
-
-	// 设置响应头
 	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.zip", filepath.Base(dirPath)))
-	c.Header("Content-Type", "application/zip")
-	c.Header("Content-Length", fmt.Sprintf("%d", len(zipData)))
-
-	// 发送ZIP文件内容
-	c.Data(http.StatusOK, "application/zip", zipData)
-}
-
-// 下载目录内容到本地临时目录
-func downloadDir(sftpClient *sftp.Client, remotePath, localPath string) error {
-	// 获取远程目录内容
-	files, err := sftpClient.ReadDir(remotePath)
-	if err != nil {
-		return err
-	}
-
-	// 遍历目录内容
-	for _, file := range files {
-		remoteFilePath := filepath.Join(remotePath, file.Name())
-		localFilePath := filepath.Join(localPath, file.Name())
-
-		if file.IsDir() {
-			// 创建本地目录
-			err = os.MkdirAll(localFilePath, 0755)
-			if err != nil {
-				return err
-			}
-			// 递归下载子目录
-			err = downloadDir(sftpClient, remoteFilePath, localFilePath)
-			if err != nil {
-				return err
-			}
-		} else {
-			// 下载文件
-			remoteFile, err := sftpClient.Open(remoteFilePath)
-			if err != nil {
-				return err
-			}
-			defer remoteFile.Close()
-
-			localFile, err := os.Create(localFilePath)
-			if err != nil {
-				return err
-			}
-			defer localFile.Close()
-
-			_, err = io.Copy(localFile, remoteFile)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + "." + format}))
+	c.Header("Content-Type", services.ArchiveContentType(format))
+	c.Header("Trailer", "X-Archive-Error")
+	c.Status(http.StatusOK)
 
-// addDirToZip 将目录内容添加到zip文件
-func addDirToZip(zipWriter *zip.Writer, basePath string, prefix string) error {
-	files, err := os.ReadDir(basePath)
-	if err != nil {
-		return err
+	if err := services.WriteSftpArchive(c.Request.Context(), sftpClient, dirPath, format, c.Writer, nil); err != nil {
+		log.Printf("打包下载目录 %s 失败: %v", dirPath, err)
+		c.Writer.Header().Set("X-Archive-Error", err.Error())
 	}
-
-	for _, file := range files {
-		filePath := filepath.Join(basePath, file.Name())
-		zipPath := filepath.Join(prefix, file.Name())
-
-		if file.IsDir() {
-			// 递归处理子目录
-			if err := addDirToZip(zipWriter, filePath, zipPath); err != nil {
-				return err
-			}
-		} else {
-			// 添加文件到zip
-			fileContent, err := os.ReadFile(filePath)
-			if err != nil {
-				return err
-			}
-
-			writer, err := zipWriter.Create(zipPath)
-			if err != nil {
-				return err
-			}
-
-			if _, err := writer.Write(fileContent); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
 }
 
-// CompressSftpDir 压缩SFTP目录
+// CompressSftpDir 在后台将目录压缩为同级的 zip 或 tar.gz 文件，已存在时覆盖，返回的任务可通过 /api/tasks 查询进度或取消
 func CompressSftpDir(c *gin.Context) {
 	hostID := c.Param("id")
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
+		return
+	}
 	dirPath := c.Query("path")
-
 	if dirPath == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "目录路径不能为空"})
 		return
 	}
-
-	var host models.Host
-	if err := global.DB.First(&host, hostID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
-		return
-	}
-
-	// 创建SSH连接
-	sshConfig := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.IP, host.Port), sshConfig)
+	format, err := services.ParseArchiveFormat(c.Query("format"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SSH连接失败: %v", err)})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer client.Close()
 
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(client)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("SFTP客户端创建失败: %v", err)})
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
 		return
 	}
-	defer sftpClient.Close()
-
-	// 创建临时目录
-	tempDir, err := os.MkdirTemp("", "sftp_compress_*")
+	// 压缩脚本先进入目录再写入压缩文件，相对路径需先解析为绝对路径
+	dirPath, err = sftpClient.RealPath(dirPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建临时目录失败: %v", err)})
-		return
-	}
-	defer os.RemoveAll(tempDir)
-
-	// 下载目录内容到临时目录
-	if err := downloadDir(sftpClient, dirPath, tempDir); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("下载目录失败: %v", err)})
+		lease.Release()
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取目录信息失败: %v", err)})
 		return
 	}
-
-	// 创建zip文件
-	zipPath := filepath.Join(tempDir, filepath.Base(dirPath)+".zip")
-	zipFile, err := os.Create(zipPath)
+	info, err := sftpClient.Stat(dirPath)
+	lease.Release()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建zip文件失败: %v", err)})
-		return
-	}
-	defer zipFile.Close()
-
-	// 创建zip写入器
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	// 将目录内容添加到zip文件
-	if err := addDirToZip(zipWriter, tempDir, ""); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建zip文件失败: %v", err)})
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取目录信息失败: %v", err)})
 		return
 	}
-
-	// 关闭zip写入器
-	if err := zipWriter.Close(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("关闭zip文件失败: %v", err)})
+	if dirPath == "/" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能压缩根目录"})
 		return
 	}
-
-	// 将zip文件上传到SFTP服务器
-	zipFileName := filepath.Base(dirPath) + ".zip"
-	remoteZipPath := filepath.Join(filepath.Dir(dirPath), zipFileName)
-	remoteFile, err := sftpClient.Create(remoteZipPath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建远程zip文件失败: %v", err)})
+	if !info.IsDir() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "路径不是目录"})
 		return
 	}
-	defer remoteFile.Close()
 
-	// 读取本地zip文件并上传
-	localZipFile, err := os.Open(zipPath)
+	host, err := models.GetHostByID(global.DB, paramID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("打开本地zip文件失败: %v", err)})
-		return
-	}
-	defer localZipFile.Close()
-
-	if _, err := io.Copy(remoteFile, localZipFile); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("上传zip文件失败: %v", err)})
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "压缩成功"})
+	task := services.StartCompressTask(host, c.GetUint("userID"), c.GetString("username"), dirPath, format)
+	c.JSON(http.StatusOK, task)
 }
 
-// WebShell 处理WebShell连接
-func WebShell(c *gin.Context) {
+// ExtractSftpFile 在后台把 zip、tar、tar.gz 文件解压到所在目录，policy 为 skip（默认）时保留已存在的文件，为 overwrite 时覆盖。
+// 返回的任务可通过 /api/tasks 查询进度或取消
+func ExtractSftpFile(c *gin.Context) {
 	hostID := c.Param("id")
-
-	var host models.Host
-	if err := global.DB.First(&host, hostID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
 		return
 	}
-
-	// 升级HTTP连接为WebSocket
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		log.Printf("WebSocket升级失败: %v", err)
+	filePath := c.Query("path")
+	if filePath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件路径不能为空"})
 		return
 	}
-	defer conn.Close()
-
-	// 创建SSH连接
-	sshConfig := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.IP, host.Port), sshConfig)
+	format, err := services.ExtractFormat(filePath)
 	if err != nil {
-		log.Printf("SSH连接失败: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer client.Close()
-
-	// 创建新的会话
-	session, err := client.NewSession()
+	policy, err := services.ParseExtractPolicy(c.Query("policy"))
 	if err != nil {
-		log.Printf("创建SSH会话失败: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer session.Close()
 
-	// 设置伪终端
-	modes := ssh.TerminalModes{
-		ssh.ECHO:          1,
-		ssh.TTY_OP_ISPEED: 14400,
-		ssh.TTY_OP_OSPEED: 14400,
-	}
-
-	// 获取终端尺寸
-	termWidth := 200
-	termHeight := 40
-
-	if err := session.RequestPty("xterm", termHeight, termWidth, modes); err != nil {
-		log.Printf("请求伪终端失败: %v", err)
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
 		return
 	}
-
-	// 获取标准输入输出
-	stdin, err := session.StdinPipe()
+	// 解压脚本先进入目标目录再读取压缩文件，相对路径需先解析为绝对路径
+	filePath, err = sftpClient.RealPath(filePath)
 	if err != nil {
-		log.Printf("获取标准输入失败: %v", err)
+		lease.Release()
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取文件信息失败: %v", err)})
 		return
 	}
-
-	stdout, err := session.StdoutPipe()
+	info, err := sftpClient.Stat(filePath)
+	lease.Release()
 	if err != nil {
-		log.Printf("获取标准输出失败: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取文件信息失败: %v", err)})
+		return
+	}
+	if !info.Mode().IsRegular() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "路径不是文件"})
 		return
 	}
 
-	stderr, err := session.StderrPipe()
+	host, err := models.GetHostByID(global.DB, paramID(c))
 	if err != nil {
-		log.Printf("获取标准错误失败: %v", err)
-		return
-	}
-
-	// 启动shell
-	if err := session.Shell(); err != nil {
-		log.Printf("启动shell失败: %v", err)
-		return
-	}
-
-	// 处理WebSocket消息
-	go func() {
-		for {
-			_, message, err := conn.ReadMessage()
-			if err != nil {
-				log.Printf("读取WebSocket消息失败: %v", err)
-				return
-			}
-
-			// 检查是否是调整终端大小的消息
-			if len(message) > 0 && message[0] == '\x1b' {
-				// 解析终端大小调整消息
-				parts := strings.Split(string(message), ";")
-				if len(parts) == 3 && strings.HasPrefix(parts[0], "\x1b[8") {
-					height, _ := strconv.Atoi(parts[1])
-					width, _ := strconv.Atoi(parts[2])
-					if height > 0 && width > 0 {
-						termHeight = height
-						termWidth = width
-						log.Printf("调整终端大小: %dx%d", width, height)
-						// 调整终端大小
-						if err := session.WindowChange(termHeight, termWidth); err != nil {
-							log.Printf("调整终端大小失败: %v", err)
-						}
-						continue
-					}
-				}
-			}
-
-			if _, err := stdin.Write(message); err != nil {
-				log.Printf("写入标准输入失败: %v", err)
-				return
-			}
-		}
-	}()
-
-	// 处理标准输出
-	go func() {
-		buffer := make([]byte, 1024)
-		for {
-			n, err := stdout.Read(buffer)
-			if err != nil {
-				log.Printf("读取标准输出失败: %v", err)
-				return
-			}
-			if err := conn.WriteMessage(websocket.TextMessage, buffer[:n]); err != nil {
-				log.Printf("发送WebSocket消息失败: %v", err)
-				return
-			}
-		}
-	}()
-
-	// 处理标准错误
-	go func() {
-		buffer := make([]byte, 1024)
-		for {
-			n, err := stderr.Read(buffer)
-			if err != nil {
-				log.Printf("读取标准错误失败: %v", err)
-				return
-			}
-			if err := conn.WriteMessage(websocket.TextMessage, buffer[:n]); err != nil {
-				log.Printf("发送WebSocket消息失败: %v", err)
-				return
-			}
-		}
-	}()
-
-	// 等待会话结束
-	session.Wait()
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return
+	}
+	task := services.StartExtractTask(host, c.GetUint("userID"), c.GetString("username"), filePath, format, policy)
+	c.JSON(http.StatusOK, task)
 }
 
 // UploadFile 处理文件上传
 func UploadFile(c *gin.Context) {
 	hostID := c.Param("id")
-
-	var host models.Host
-	if err := global.DB.First(&host, hostID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
 		return
 	}
 
@@ -837,29 +476,11 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
-	// 创建SSH连接
-	sshConfig := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.IP, host.Port), sshConfig)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "SSH连接失败"})
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
 		return
 	}
-	defer client.Close()
-
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(client)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建SFTP客户端失败"})
-		return
-	}
-	defer sftpClient.Close()
+	defer lease.Release()
 
 	// 打开上传的文件
 	src, err := file.Open()
@@ -889,37 +510,16 @@ func UploadFile(c *gin.Context) {
 // DownloadFile 处理文件下载
 func DownloadFile(c *gin.Context) {
 	hostID := c.Param("id")
-	filename := c.Query("file")
-
-	var host models.Host
-	if err := global.DB.First(&host, hostID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
-		return
-	}
-
-	// 创建SSH连接
-	sshConfig := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.IP, host.Port), sshConfig)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "SSH连接失败"})
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
 		return
 	}
-	defer client.Close()
+	filename := c.Query("file")
 
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(client)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建SFTP客户端失败"})
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
 		return
 	}
-	defer sftpClient.Close()
+	defer lease.Release()
 
 	// 打开源文件
 	src, err := sftpClient.Open(filename)
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"devops/global"
+	"devops/models"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// sftpUploadMaxChunk 单个分片的最大字节数
+const sftpUploadMaxChunk = 64 << 20
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// InitSftpUploadRequest 开始分片上传的请求
+type InitSftpUploadRequest struct {
+	Path     string `json:"path" binding:"required"` // 目标目录
+	Filename string `json:"filename" binding:"required"`
+	Size     *int64 `json:"size" binding:"required"`
+	SHA256   string `json:"sha256"` // 文件的 SHA-256，可在完成上传时再提供
+}
+
+// CompleteSftpUploadRequest 完成分片上传的请求
+type CompleteSftpUploadRequest struct {
+	SHA256 string `json:"sha256"`
+}
+
+// sftpUploadStatus 上传进度，missing 为尚未上传的区间
+type sftpUploadStatus struct {
+	*models.SftpUpload
+	Missing []models.ByteRange `json:"missing"`
+}
+
+func newSftpUploadStatus(upload *models.SftpUpload) sftpUploadStatus {
+	return sftpUploadStatus{SftpUpload: upload, Missing: upload.Missing()}
+}
+
+// InitSftpUpload 开始分片上传。已有上传到同一路径、大小与校验值相同的未完成上传时返回其进度，客户端只需补传缺少的区间
+func InitSftpUpload(c *gin.Context) {
+	hostID := c.Param("id")
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
+		return
+	}
+	var req InitSftpUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Filename == "." || req.Filename == ".." || strings.Contains(req.Filename, "/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件名不合法"})
+		return
+	}
+	if *req.Size < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件大小不能为负数"})
+		return
+	}
+	req.SHA256 = strings.ToLower(req.SHA256)
+	if req.SHA256 != "" && !sha256Pattern.MatchString(req.SHA256) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SHA-256 校验值格式不正确"})
+		return
+	}
+
+	sftpClient, lease, ok := acquireSftp(c, hostID)
+	if !ok {
+		return
+	}
+	defer lease.Release()
+
+	info, err := sftpClient.Stat(req.Path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("获取目录信息失败: %v", err)})
+		return
+	}
+	if !info.IsDir() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "路径不是目录"})
+		return
+	}
+
+	upload, err := services.NewSftpUploadService(global.DB).Init(lease, paramID(c), c.GetUint("userID"), path.Clean(req.Path), req.Filename, *req.Size, req.SHA256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("开始上传失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, newSftpUploadStatus(upload))
+}
+
+// GetSftpUpload 获取分片上传的进度
+func GetSftpUpload(c *gin.Context) {
+	upload, ok := loadSftpUpload(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, newSftpUploadStatus(upload))
+}
+
+// UploadSftpChunk 上传一个分片，请求体为分片内容，offset 为分片在文件中的偏移。
+// 分片可以乱序或并发上传，重复上传的区间会覆盖写入
+func UploadSftpChunk(c *gin.Context) {
+	upload, ok := loadSftpUpload(c)
+	if !ok {
+		return
+	}
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset 参数不正确"})
+		return
+	}
+	length := c.Request.ContentLength
+	if length < 0 {
+		c.JSON(http.StatusLengthRequired, gin.H{"error": "缺少 Content-Length"})
+		return
+	}
+	if length > sftpUploadMaxChunk {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("分片不能超过 %d 字节", sftpUploadMaxChunk)})
+		return
+	}
+	if offset+length > upload.Size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分片超出文件大小"})
+		return
+	}
+
+	_, lease, ok := acquireSftp(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	defer lease.Release()
+
+	upload, err = services.NewSftpUploadService(global.DB).WriteChunk(lease, upload, offset, length, c.Request.Body)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadGone) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if upload == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// 已写入的部分已记录，返回进度以便客户端续传
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "upload": newSftpUploadStatus(upload)})
+		return
+	}
+	c.JSON(http.StatusOK, newSftpUploadStatus(upload))
+}
+
+// CompleteSftpUpload 完成分片上传：检查全部区间已上传并校验 SHA-256 后，把临时文件重命名为目标文件，已存在时覆盖
+func CompleteSftpUpload(c *gin.Context) {
+	upload, ok := loadSftpUpload(c)
+	if !ok {
+		return
+	}
+	var req CompleteSftpUploadRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.SHA256 = strings.ToLower(req.SHA256)
+	if req.SHA256 != "" && !sha256Pattern.MatchString(req.SHA256) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SHA-256 校验值格式不正确"})
+		return
+	}
+
+	_, lease, ok := acquireSftp(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	defer lease.Release()
+
+	upload, err := services.NewSftpUploadService(global.DB).Complete(c.Request.Context(), lease, upload, req.SHA256)
+	switch {
+	case errors.Is(err, services.ErrUploadGone):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadChecksumRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadIncomplete), errors.Is(err, services.ErrUploadChecksumMismatch):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "upload": newSftpUploadStatus(upload)})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "上传成功", "path": upload.Path})
+	}
+}
+
+// AbortSftpUpload 放弃分片上传，删除临时文件
+func AbortSftpUpload(c *gin.Context) {
+	upload, ok := loadSftpUpload(c)
+	if !ok {
+		return
+	}
+	_, lease, ok := acquireSftp(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	defer lease.Release()
+
+	if err := services.NewSftpUploadService(global.DB).Abort(lease, upload); err != nil {
+		if errors.Is(err, services.ErrUploadGone) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已取消上传"})
+}
+
+// loadSftpUpload 校验权限并读取路径中的上传记录，只有上传者可以操作
+func loadSftpUpload(c *gin.Context) (*models.SftpUpload, bool) {
+	if !checkPermission(c, services.ResourceHost, services.ActionSftp, paramID(c)) {
+		return nil, false
+	}
+	uploadID, err := strconv.ParseUint(c.Param("uploadId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload ID"})
+		return nil, false
+	}
+
+	upload, err := models.GetSftpUpload(global.DB, paramID(c), uint(uploadID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "上传不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	if upload.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有上传者可以操作该上传"})
+		return nil, false
+	}
+	return upload, true
+}
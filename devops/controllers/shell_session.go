@@ -0,0 +1,463 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"devops/global"
+	"devops/models"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// ShellSessionController WebShell 会话记录控制器
+type ShellSessionController struct {
+	DB *gorm.DB
+}
+
+// NewShellSessionController 创建会话记录控制器
+func NewShellSessionController() *ShellSessionController {
+	return &ShellSessionController{
+		DB: global.DB,
+	}
+}
+
+// GetSessions 获取会话列表。没有 session:read 权限的用户只能看到自己的会话
+func (c *ShellSessionController) GetSessions(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+
+	query := models.ShellSessionQuery{
+		Username: ctx.Query("username"),
+		Status:   ctx.Query("status"),
+	}
+	var err error
+	if query.HostID, err = parseIDQuery(ctx, "hostId"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.From, err = parseTimeQuery(ctx, "from"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.To, err = parseTimeQuery(ctx, "to"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	all, ok := c.canReadAll(ctx)
+	if !ok {
+		return
+	}
+	if !all {
+		query.UserID = ctx.GetUint("userID")
+	}
+
+	sessions, total, err := models.GetShellSessionList(c.DB, page, pageSize, query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  sessions,
+		"total": total,
+	})
+}
+
+// SearchCommands 搜索命令审计记录，支持按命令内容、用户、主机、会话、状态与时间范围过滤。
+// 没有 session:read 权限的用户只能搜索自己执行的命令
+func (c *ShellSessionController) SearchCommands(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+
+	query := models.CommandAuditQuery{
+		Keyword:  ctx.Query("keyword"),
+		Username: ctx.Query("username"),
+		HostName: ctx.Query("hostName"),
+		Status:   ctx.Query("status"),
+	}
+	var err error
+	if query.HostID, err = parseIDQuery(ctx, "hostId"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.SessionID, err = parseIDQuery(ctx, "sessionId"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.From, err = parseTimeQuery(ctx, "from"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.To, err = parseTimeQuery(ctx, "to"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	all, ok := c.canReadAll(ctx)
+	if !ok {
+		return
+	}
+	if !all {
+		query.UserID = ctx.GetUint("userID")
+	}
+
+	c.respondCommands(ctx, page, pageSize, query)
+}
+
+// GetSessionCommands 获取会话中执行的命令
+func (c *ShellSessionController) GetSessionCommands(ctx *gin.Context) {
+	session, ok := c.loadSession(ctx)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "100"))
+	c.respondCommands(ctx, page, pageSize, models.CommandAuditQuery{SessionID: session.ID})
+}
+
+// respondCommands 查询并返回命令审计列表
+func (c *ShellSessionController) respondCommands(ctx *gin.Context, page, pageSize int, query models.CommandAuditQuery) {
+	audits, total, err := models.GetCommandAuditList(c.DB, page, pageSize, query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  audits,
+		"total": total,
+	})
+}
+
+// GetSession 获取会话详情
+func (c *ShellSessionController) GetSession(ctx *gin.Context) {
+	session, ok := c.loadSession(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, session)
+}
+
+// DownloadRecording 下载会话录像（asciicast v2），进行中的会话返回已录制的部分
+func (c *ShellSessionController) DownloadRecording(ctx *gin.Context) {
+	session, ok := c.loadSession(ctx)
+	if !ok {
+		return
+	}
+
+	path, err := services.RecordingFile(session)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "录像文件不存在"})
+		return
+	}
+
+	ctx.Header("Content-Type", "application/x-asciicast")
+	ctx.FileAttachment(path, fmt.Sprintf("session-%d.cast", session.ID))
+}
+
+// ReplaySession 通过 WebSocket 按原始时间间隔回放会话录像，每条消息为 asciicast 的一行。
+// 支持 speed（倍速）与 idle（最长等待秒数）参数，进行中的会话会持续推送新的输出。
+func (c *ShellSessionController) ReplaySession(ctx *gin.Context) {
+	session, ok := c.loadSession(ctx)
+	if !ok {
+		return
+	}
+
+	opts := services.ReplayOptions{Speed: 1}
+	if v := ctx.Query("speed"); v != "" {
+		speed, err := strconv.ParseFloat(v, 64)
+		if err != nil || speed <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "speed 必须为正数"})
+			return
+		}
+		opts.Speed = speed
+	}
+	if v := ctx.Query("idle"); v != "" {
+		idle, err := strconv.ParseFloat(v, 64)
+		if err != nil || idle < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "idle 不能为负数"})
+			return
+		}
+		opts.IdleLimit = time.Duration(idle * float64(time.Second))
+	}
+
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket升级失败: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// 读取客户端消息以便及时发现连接关闭
+	replayCtx, cancel := context.WithCancel(ctx.Request.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	err = services.NewShellSessionService(c.DB).Replay(replayCtx, session, opts, func(line []byte) error {
+		return conn.WriteMessage(websocket.TextMessage, line)
+	})
+	if err != nil && replayCtx.Err() == nil {
+		log.Printf("回放会话 %d 失败: %v", session.ID, err)
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "回放失败"))
+		return
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// CreateShare 为进行中的会话创建共享令牌，只有会话创建者可以共享。
+// writable 为 true 时持有者可以输入，否则只能查看
+func (c *ShellSessionController) CreateShare(ctx *gin.Context) {
+	shell, ok := c.ownLiveShell(ctx)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Writable bool `json:"writable"`
+	}
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	share, err := shell.Share(req.Writable)
+	if errors.Is(err, services.ErrShellEnded) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, share)
+}
+
+// GetShares 获取会话的共享及当前连接的用户
+func (c *ShellSessionController) GetShares(ctx *gin.Context) {
+	shell, ok := c.ownLiveShell(ctx)
+	if !ok {
+		return
+	}
+
+	shares := shell.Shares()
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  shares,
+		"total": len(shares),
+	})
+}
+
+// RevokeShare 撤销共享，经该共享连接的用户会被断开
+func (c *ShellSessionController) RevokeShare(ctx *gin.Context) {
+	shareID, err := strconv.ParseUint(ctx.Param("shareId"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share ID"})
+		return
+	}
+	shell, ok := c.ownLiveShell(ctx)
+	if !ok {
+		return
+	}
+
+	if !shell.RevokeShare(uint(shareID)) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "共享不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Share revoked successfully"})
+}
+
+// AttachSession 凭共享令牌（share 参数）连接到进行中的会话，帧格式与 WebShell 相同。
+// 用户需要能查看该主机（host:read 或 host:shell），可输入的共享还要求拥有该主机的终端权限
+func (c *ShellSessionController) AttachSession(ctx *gin.Context) {
+	id := paramID(ctx)
+	if id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	shell := services.GetLiveShell(id)
+	if shell == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "会话不存在或已结束"})
+		return
+	}
+
+	token := ctx.Query("share")
+	share, err := shell.FindShare(token)
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkAnyPermission(ctx, services.ResourceHost, shell.Host.ID, services.ActionRead, services.ActionShell) {
+		return
+	}
+	if share.Writable && !checkPermission(ctx, services.ResourceHost, services.ActionShell, shell.Host.ID) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket升级失败: %v", err)
+		return
+	}
+	socket := &shellSocket{conn: conn}
+	defer conn.Close()
+
+	client, err := shell.AttachShared(token, ctx.GetUint("userID"), ctx.GetString("username"))
+	if err != nil {
+		socket.fail("连接会话失败: %v", err)
+		return
+	}
+	log.Printf("用户 %s 经共享 #%d 连接到会话 %d", client.Username, share.ID, id)
+
+	serveShell(socket, shell, client)
+}
+
+// ResumeSession 创建者重新连接到断开后仍在保留期内的会话，帧格式与 WebShell 相同。
+// 连接后先回放最近的输出，cols、rows 参数用于恢复终端尺寸
+func (c *ShellSessionController) ResumeSession(ctx *gin.Context) {
+	shell, ok := c.ownLiveShell(ctx)
+	if !ok {
+		return
+	}
+	if !checkPermission(ctx, services.ResourceHost, services.ActionShell, shell.Host.ID) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket升级失败: %v", err)
+		return
+	}
+	socket := &shellSocket{conn: conn}
+	defer conn.Close()
+
+	client, err := shell.Resume()
+	if err != nil {
+		socket.fail("恢复会话失败: %v", err)
+		return
+	}
+	if cols, rows, ok := termSize(ctx); ok {
+		if err := shell.Resize(client, cols, rows); err != nil {
+			log.Printf("调整终端大小失败: %v", err)
+		}
+	}
+
+	serveOwner(socket, shell, client)
+}
+
+// ownLiveShell 读取路径中本人创建且仍在进行的会话
+func (c *ShellSessionController) ownLiveShell(ctx *gin.Context) (*services.LiveShell, bool) {
+	id := paramID(ctx)
+	if id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	session, err := models.GetShellSession(c.DB, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	if session.UserID != ctx.GetUint("userID") {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有会话创建者可以执行该操作"})
+		return nil, false
+	}
+
+	shell := services.GetLiveShell(id)
+	if shell == nil {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "会话已结束"})
+		return nil, false
+	}
+	return shell, true
+}
+
+// canReadAll 判断当前用户能否查看所有人的会话，校验出错时写入响应并返回 ok 为 false
+func (c *ShellSessionController) canReadAll(ctx *gin.Context) (all bool, ok bool) {
+	all, err := services.NewAuthzService(c.DB).Authorize(ctx.GetUint("userID"), services.ResourceSession, services.ActionRead, 0)
+	if err != nil {
+		log.Printf("权限校验失败: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "权限校验失败"})
+		return false, false
+	}
+	return all, true
+}
+
+// loadSession 读取路径中的会话并校验查看权限：本人的会话或拥有 session:read 权限
+func (c *ShellSessionController) loadSession(ctx *gin.Context) (*models.ShellSession, bool) {
+	id := paramID(ctx)
+	if id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	session, err := models.GetShellSession(c.DB, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+
+	if session.UserID != ctx.GetUint("userID") &&
+		!checkPermission(ctx, services.ResourceSession, services.ActionRead, session.ID) {
+		return nil, false
+	}
+	return session, true
+}
+
+// parseIDQuery 解析 ID 查询参数，为空时返回 0
+func parseIDQuery(ctx *gin.Context, key string) (uint, error) {
+	v := ctx.Query(key)
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s 无效", key)
+	}
+	return uint(id), nil
+}
+
+// parseTimeQuery 解析 RFC3339 或 2006-01-02 格式的时间参数，为空时返回 nil
+func parseTimeQuery(ctx *gin.Context, key string) (*time.Time, error) {
+	v := ctx.Query(key)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("%s 时间格式无效，应为 RFC3339 或 YYYY-MM-DD", key)
+	}
+	return &t, nil
+}
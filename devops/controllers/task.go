@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"devops/global"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TaskController 后台任务控制器
+type TaskController struct {
+	DB *gorm.DB
+}
+
+// NewTaskController 创建后台任务控制器
+func NewTaskController() *TaskController {
+	return &TaskController{
+		DB: global.DB,
+	}
+}
+
+// GetTasks 获取进行中及最近一小时内结束的后台任务，没有 session:read 权限的用户只能看到自己的任务
+func (c *TaskController) GetTasks(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	all, err := services.NewAuthzService(c.DB).Authorize(ctx.GetUint("userID"), services.ResourceSession, services.ActionRead, 0)
+	if err != nil {
+		log.Printf("权限校验失败: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "权限校验失败"})
+		return
+	}
+	var userID uint
+	if !all {
+		userID = ctx.GetUint("userID")
+	}
+
+	tasks := services.ListTasks(userID)
+	total := len(tasks)
+	start := min((page-1)*pageSize, total)
+	end := min(start+pageSize, total)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  tasks[start:end],
+		"total": total,
+	})
+}
+
+// GetTask 获取任务状态与进度
+func (c *TaskController) GetTask(ctx *gin.Context) {
+	task, ok := c.loadTask(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, task)
+}
+
+// CancelTask 取消进行中的任务，只有任务创建者可以取消
+func (c *TaskController) CancelTask(ctx *gin.Context) {
+	task, ok := c.loadTask(ctx)
+	if !ok {
+		return
+	}
+	if task.UserID != ctx.GetUint("userID") {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有任务创建者可以取消任务"})
+		return
+	}
+	if !services.CancelTask(task.ID) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "任务已结束"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Task canceled successfully"})
+}
+
+// loadTask 读取路径中的任务，任务创建者或具有 session:read 权限的用户可以查看
+func (c *TaskController) loadTask(ctx *gin.Context) (services.Task, bool) {
+	id := paramID(ctx)
+	if id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return services.Task{}, false
+	}
+
+	task, ok := services.GetTask(id)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return services.Task{}, false
+	}
+
+	if task.UserID != ctx.GetUint("userID") &&
+		!checkPermission(ctx, services.ResourceSession, services.ActionRead, 0) {
+		return services.Task{}, false
+	}
+	return task, true
+}
@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"devops/global"
+	"devops/middleware"
+	"devops/models"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UserController 用户控制器
+type UserController struct {
+	DB *gorm.DB
+}
+
+// NewUserController 创建用户控制器
+func NewUserController() *UserController {
+	return &UserController{
+		DB: global.DB,
+	}
+}
+
+// LoginRequest 登录请求
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,min=8"`
+}
+
+// Login 用户登录
+func (c *UserController) Login(ctx *gin.Context) {
+	var req LoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := models.GetUserByUsername(c.DB, req.Username)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("查询用户失败: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+		return
+	}
+
+	if !user.CheckPassword(req.Password) {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+		return
+	}
+
+	if !user.IsActive() {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "用户已被禁用"})
+		return
+	}
+
+	token, expiresAt, err := middleware.GenerateToken(user.ID, user.Username)
+	if err != nil {
+		log.Printf("生成token失败: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "生成token失败"})
+		return
+	}
+
+	if err := models.UpdateUserLastLogin(c.DB, user.ID, time.Now()); err != nil {
+		log.Printf("更新最后登录时间失败: %v", err)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"token":     token,
+		"expiresAt": expiresAt,
+		"user":      user,
+	})
+}
+
+// Logout 用户登出
+func (c *UserController) Logout(ctx *gin.Context) {
+	middleware.RevokeToken(middleware.GetClaims(ctx))
+	ctx.JSON(http.StatusOK, gin.H{"message": "登出成功"})
+}
+
+// RefreshToken 刷新 token，旧 token 随即失效
+func (c *UserController) RefreshToken(ctx *gin.Context) {
+	user, err := models.GetUserByID(c.DB, ctx.GetUint("userID"))
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if !user.IsActive() {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "用户已被禁用"})
+		return
+	}
+
+	token, expiresAt, err := middleware.GenerateToken(user.ID, user.Username)
+	if err != nil {
+		log.Printf("生成token失败: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "生成token失败"})
+		return
+	}
+	middleware.RevokeToken(middleware.GetClaims(ctx))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"token":     token,
+		"expiresAt": expiresAt,
+	})
+}
+
+// GetUserInfo 获取当前用户信息
+func (c *UserController) GetUserInfo(ctx *gin.Context) {
+	user, err := models.GetUserByID(c.DB, ctx.GetUint("userID"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// ChangePassword 修改当前用户密码，此前签发的 token 全部失效并返回新的 token
+func (c *UserController) ChangePassword(ctx *gin.Context) {
+	var req ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := models.GetUserByID(c.DB, ctx.GetUint("userID"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if !user.CheckPassword(req.OldPassword) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "原密码错误"})
+		return
+	}
+
+	if err := user.SetPassword(req.NewPassword); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.UpdateUserPassword(c.DB, user.ID, user.Password); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	services.CloseUserShells(user.ID)
+
+	// 此前签发的 token 均已失效，为当前用户签发新的 token
+	token, expiresAt, err := middleware.GenerateToken(user.ID, user.Username)
+	if err != nil {
+		log.Printf("生成token失败: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "生成token失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":   "密码修改成功",
+		"token":     token,
+		"expiresAt": expiresAt,
+	})
+}
+
+// UserRequest 创建/更新用户请求
+type UserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Nickname string `json:"nickname"`
+	Email    string `json:"email"`
+	Status   string `json:"status"`
+}
+
+// GetPermissions 获取当前用户的角色绑定
+func (c *UserController) GetPermissions(ctx *gin.Context) {
+	bindings, err := models.GetUserRoleBindings(c.DB, ctx.GetUint("userID"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"list": bindings})
+}
+
+// GetUsers 获取用户列表
+func (c *UserController) GetUsers(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceUser, services.ActionRead, 0) {
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+	username := ctx.Query("username")
+
+	users, total, err := models.GetUserList(c.DB, page, pageSize, username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  users,
+		"total": total,
+	})
+}
+
+// CreateUser 创建用户
+func (c *UserController) CreateUser(ctx *gin.Context) {
+	if !checkPermission(ctx, services.ResourceUser, services.ActionCreate, 0) {
+		return
+	}
+
+	var req UserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Username == "" || len(req.Password) < 8 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "用户名不能为空且密码至少8位"})
+		return
+	}
+
+	user := models.User{
+		Username: req.Username,
+		Nickname: req.Nickname,
+		Email:    req.Email,
+		Status:   req.Status,
+	}
+	if user.Status == "" {
+		user.Status = "active"
+	}
+	if err := user.SetPassword(req.Password); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.CreateUser(c.DB, &user); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// UpdateUser 更新用户信息，密码非空时重置密码
+func (c *UserController) UpdateUser(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceUser, services.ActionUpdate, uint(id)) {
+		return
+	}
+
+	var req UserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Password != "" && len(req.Password) < 8 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "密码至少8位"})
+		return
+	}
+
+	user, err := models.GetUserByID(c.DB, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	wasActive := user.IsActive()
+	user.Nickname = req.Nickname
+	user.Email = req.Email
+	if req.Status != "" {
+		user.Status = req.Status
+	}
+	if err := models.UpdateUser(c.DB, user.ID, user); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 禁用或重置密码后，用户此前签发的 token 全部失效，进行中的终端会话随即断开
+	revoked := false
+	if wasActive && !user.IsActive() {
+		if err := models.RevokeUserTokens(c.DB, user.ID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		revoked = true
+	}
+	if req.Password != "" {
+		if err := user.SetPassword(req.Password); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := models.UpdateUserPassword(c.DB, user.ID, user.Password); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		revoked = true
+	}
+	if revoked {
+		services.CloseUserShells(user.ID)
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// DeleteUser 删除用户
+func (c *UserController) DeleteUser(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if !checkPermission(ctx, services.ResourceUser, services.ActionDelete, uint(id)) {
+		return
+	}
+	if uint(id) == ctx.GetUint("userID") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "不能删除当前登录用户"})
+		return
+	}
+
+	if err := models.DeleteUser(c.DB, uint(id)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	services.CloseUserShells(uint(id))
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
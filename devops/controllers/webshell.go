@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"devops/global"
+	"devops/models"
+	"devops/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// WebShell 帧类型。客户端与服务端之间只使用 WebSocket 二进制消息，
+// 每条消息的首字节为帧类型，其余为负载。
+const (
+	frameInput      byte = 0x00 // 客户端 → 服务端：终端输入，负载为原始字节
+	frameOutput     byte = 0x01 // 服务端 → 客户端：终端输出，负载为原始字节
+	frameResize     byte = 0x02 // 终端尺寸，负载为列数、行数（各 2 字节大端序）；共享连接由服务端通知创建者的终端尺寸
+	framePing       byte = 0x03 // 客户端 → 服务端：心跳，服务端以相同负载回复 pong
+	framePong       byte = 0x04 // 服务端 → 客户端：心跳回复
+	frameClose      byte = 0x05 // 客户端 → 服务端：结束会话
+	frameExitStatus byte = 0x06 // 服务端 → 客户端：会话结束，负载为退出码（4 字节大端序），未取得退出码时为空
+	frameSession    byte = 0x07 // 服务端 → 客户端：会话ID（4 字节大端序），创建者连接后首先发送，用于断线重连
+)
+
+// closeCodeExitBase 远端 shell 退出时以 4000+退出码 作为 WebSocket 关闭码
+const closeCodeExitBase = 4000
+
+// 默认终端尺寸，客户端可通过 cols、rows 参数指定初始尺寸
+const (
+	defaultTermCols = 200
+	defaultTermRows = 40
+)
+
+// termSize 解析 cols、rows 参数，未指定或无效时 ok 为 false
+func termSize(c *gin.Context) (cols, rows int, ok bool) {
+	cols, err := strconv.Atoi(c.Query("cols"))
+	if err != nil || cols <= 0 || cols > 0xffff {
+		return 0, 0, false
+	}
+	rows, err = strconv.Atoi(c.Query("rows"))
+	if err != nil || rows <= 0 || rows > 0xffff {
+		return 0, 0, false
+	}
+	return cols, rows, true
+}
+
+// shellSocket WebShell 的 WebSocket 连接，发送方法可并发调用
+type shellSocket struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+// send 发送一帧
+func (s *shellSocket) send(kind byte, payload []byte) error {
+	frame := make([]byte, 1+len(payload))
+	frame[0] = kind
+	copy(frame[1:], payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// write 向终端输出内容
+func (s *shellSocket) write(data []byte) error {
+	return s.send(frameOutput, data)
+}
+
+// close 发送关闭帧并关闭连接
+func (s *shellSocket) close(code int, reason string) {
+	deadline := time.Now().Add(time.Second)
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	s.conn.Close()
+}
+
+// fail 向终端输出错误信息并以 1011 关闭连接
+func (s *shellSocket) fail(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Print(message)
+	s.write([]byte(message + "\r\n"))
+	s.close(websocket.CloseInternalServerErr, "")
+}
+
+// WebShell 处理WebShell连接，帧格式见 frameInput 等常量
+func WebShell(c *gin.Context) {
+	if !checkPermission(c, services.ResourceHost, services.ActionShell, paramID(c)) {
+		return
+	}
+
+	// 需要主机的分组来匹配按分组生效的命令规则
+	host, err := models.GetHostByID(global.DB, paramID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return
+	}
+
+	termCols, termRows, ok := termSize(c)
+	if !ok {
+		termCols, termRows = defaultTermCols, defaultTermRows
+	}
+
+	// 升级HTTP连接为WebSocket
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket升级失败: %v", err)
+		return
+	}
+	socket := &shellSocket{conn: conn}
+	defer conn.Close()
+
+	shell, client, err := services.StartLiveShell(c.Request.Context(), global.DB, host, &models.ShellSession{
+		UserID:   c.GetUint("userID"),
+		Username: c.GetString("username"),
+		ClientIP: c.ClientIP(),
+	}, termCols, termRows)
+	if err != nil {
+		socket.fail("%v", err)
+		return
+	}
+
+	serveOwner(socket, shell, client)
+}
+
+// serveOwner 向会话创建者发送会话ID后开始收发帧
+func serveOwner(socket *shellSocket, shell *services.LiveShell, client *services.ShellClient) {
+	id := make([]byte, 4)
+	binary.BigEndian.PutUint32(id, uint32(shell.Session.ID))
+	if err := socket.send(frameSession, id); err != nil {
+		shell.Detach(client)
+		return
+	}
+	serveShell(socket, shell, client)
+}
+
+// serveShell 在 WebSocket 连接与终端之间收发帧，直到连接断开或会话结束。
+// 创建者发送 close 帧时结束会话，连接中断时会话保留等待重连；共享连接断开只影响自身。
+func serveShell(socket *shellSocket, shell *services.LiveShell, client *services.ShellClient) {
+	// 处理客户端消息，创建者结束会话后仍等待退出码发送给客户端
+	go func() {
+		closing := false
+		defer func() {
+			if closing && client.Owner {
+				shell.Close()
+			} else {
+				shell.Detach(client)
+			}
+		}()
+		for {
+			messageType, message, err := socket.conn.ReadMessage()
+			if err != nil {
+				log.Printf("读取WebSocket消息失败: %v", err)
+				return
+			}
+			if messageType != websocket.BinaryMessage || len(message) == 0 {
+				continue
+			}
+
+			payload := message[1:]
+			switch message[0] {
+			case frameInput:
+				if err := shell.Input(client, payload); err != nil {
+					log.Printf("写入标准输入失败: %v", err)
+					return
+				}
+			case frameResize:
+				if len(payload) != 4 {
+					continue
+				}
+				cols := int(binary.BigEndian.Uint16(payload[0:2]))
+				rows := int(binary.BigEndian.Uint16(payload[2:4]))
+				if cols == 0 || rows == 0 {
+					continue
+				}
+				if err := shell.Resize(client, cols, rows); err != nil {
+					log.Printf("调整终端大小失败: %v", err)
+				}
+			case framePing:
+				socket.send(framePong, payload)
+			case frameClose:
+				closing = true
+				return
+			}
+		}
+	}()
+
+	// 发送终端输出
+	for {
+		event, ok := client.Next()
+		if !ok {
+			break
+		}
+		var err error
+		switch event.Kind {
+		case services.ShellOutput:
+			err = socket.write(event.Data)
+		case services.ShellResize:
+			size := make([]byte, 4)
+			binary.BigEndian.PutUint16(size[0:2], uint16(event.Cols))
+			binary.BigEndian.PutUint16(size[2:4], uint16(event.Rows))
+			err = socket.send(frameResize, size)
+		}
+		if err != nil {
+			log.Printf("发送WebSocket消息失败: %v", err)
+			shell.Detach(client)
+			return
+		}
+	}
+
+	if err := client.Err(); err != nil {
+		socket.write([]byte("\r\n" + err.Error() + "\r\n"))
+		socket.close(websocket.ClosePolicyViolation, "")
+		return
+	}
+	exitStatus, exited := shell.ExitStatus()
+	if !exited {
+		socket.close(websocket.CloseNormalClosure, "")
+		return
+	}
+	if exitStatus == nil {
+		socket.send(frameExitStatus, nil)
+		socket.close(websocket.CloseNormalClosure, "session closed")
+		return
+	}
+	status := make([]byte, 4)
+	binary.BigEndian.PutUint32(status, uint32(int32(*exitStatus)))
+	socket.send(frameExitStatus, status)
+	socket.close(closeCodeExitBase+(*exitStatus&0xff), fmt.Sprintf("exit status %d", *exitStatus))
+}
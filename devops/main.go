@@ -1,17 +1,120 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
 	"devops/config"
+	"devops/global"
+	"devops/migrations"
+	"devops/models"
 	"devops/router"
+	"devops/secrets"
+	"devops/services"
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	configFile := flag.String("config", "", "配置文件路径 (默认读取 DEVOPS_CONFIG 或 ./config.yaml)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法: devops [-config 配置文件] [migrate|secrets <命令>]\n\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s\n%s", migrations.CommandUsage, secrets.CommandUsage)
+	}
+	flag.Parse()
+
+	// 加载配置
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	gin.SetMode(cfg.Server.Mode)
+
+	// 初始化敏感字段加密主密钥
+	if err := secrets.Init(cfg.Secrets); err != nil {
+		log.Fatalf("初始化主密钥失败: %v", err)
+	}
+
 	// 初始化数据库
 	config.InitDB()
 
+	// 子命令
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			if err := migrations.RunCommand(global.DB, args[1:], os.Stdout); err != nil {
+				log.Fatalf("migrate 执行失败: %v", err)
+			}
+		case "secrets":
+			if err := secrets.RunCommand(global.DB, args[1:], models.SecretModels(), os.Stdout); err != nil {
+				log.Fatalf("secrets 执行失败: %v", err)
+			}
+		default:
+			flag.Usage()
+			os.Exit(2)
+		}
+		return
+	}
+
+	// 数据库迁移
+	if err := migrateDB(cfg.Database.AutoMigrate); err != nil {
+		log.Fatalf("数据库迁移失败: %v", err)
+	}
+
+	// 初始化管理员账号
+	if err := services.EnsureAdminUser(global.DB); err != nil {
+		log.Fatalf("初始化管理员账号失败: %v", err)
+	}
+
+	// 初始化内置角色
+	if err := services.NewAuthzService(global.DB).EnsureBuiltInRoles(); err != nil {
+		log.Fatalf("初始化内置角色失败: %v", err)
+	}
+
+	// 主机 SSH 连接池
+	services.InitSSHPool(global.DB, cfg.SSH)
+
+	// 上次运行中未正常结束的终端会话
+	if err := services.NewShellSessionService(global.DB).CloseStale(); err != nil {
+		log.Printf("更新未结束的会话记录失败: %v", err)
+	}
+
+	// 上次运行中未执行完的批量执行任务
+	if err := services.NewExecService(global.DB).CloseStale(); err != nil {
+		log.Printf("更新未结束的批量执行任务失败: %v", err)
+	}
+
+	// 定时采集主机信息
+	services.StartFactsScheduler(global.DB, cfg.SSH.FactsInterval)
+
 	// 配置路由
 	r := router.SetupRouter()
 
 	// 启动服务器
-	r.Run(":8100")
+	if err := r.Run(cfg.Server.Addr); err != nil {
+		log.Fatalf("启动服务失败: %v", err)
+	}
+}
+
+// migrateDB 启动时执行或检查数据库迁移
+func migrateDB(auto bool) error {
+	if auto {
+		log.Println("开始数据库迁移...")
+		if err := migrations.Up(global.DB); err != nil {
+			return err
+		}
+		log.Println("数据库迁移完成")
+		return nil
+	}
+
+	pending, err := migrations.Pending(global.DB)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return migrations.ErrPending
+	}
+	return nil
 }
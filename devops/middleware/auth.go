@@ -1,18 +1,41 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
+	"devops/config"
+	"devops/global"
+	"devops/models"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
+// ErrTokenRevoked token 已注销
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+func init() {
+	// 签发时间精确到毫秒，修改密码后立即签发的新 token 不会早于用户的 TokensValidAfter
+	jwt.TimePrecision = time.Millisecond
+}
+
 // AuthMiddleware 认证中间件
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取 Authorization 头
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && websocketRequest(c) {
+			// 浏览器无法为 WebSocket 设置请求头，允许通过 query 传递 token
+			if token := c.Query("token"); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
 			c.Abort()
@@ -36,9 +59,32 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 用户被删除、禁用或修改密码后，此前签发的 token 不再可用
+		user, err := models.GetUserByID(global.DB, claims.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			c.Abort()
+			return
+		}
+		if !user.IsActive() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User is disabled"})
+			c.Abort()
+			return
+		}
+		if claims.IssuedAt == nil || !user.TokenValid(claims.IssuedAt.Time) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储到上下文中
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("claims", claims)
 		c.Next()
 	}
 }
@@ -50,12 +96,61 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// GenerateToken 为用户签发 token
+func GenerateToken(userID uint, username string) (string, time.Time, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(config.Conf.JWT.Expire)
+	claims := &Claims{
+		UserID:   userID,
+		Username: username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// RevokeToken 注销 token，直到其过期前都无法再使用
+func RevokeToken(claims *Claims) {
+	if claims == nil || claims.ID == "" {
+		return
+	}
+	expiresAt := time.Now().Add(config.Conf.JWT.Expire)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	revokedTokens.add(claims.ID, expiresAt)
+}
+
+// GetClaims 从上下文中获取当前请求的 token 声明
+func GetClaims(c *gin.Context) *Claims {
+	if v, ok := c.Get("claims"); ok {
+		if claims, ok := v.(*Claims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
 // validateToken 验证 token
 func validateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte("your-secret-key"), nil // 使用环境变量或配置文件中的密钥
-	})
+		return jwtSecret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -65,5 +160,51 @@ func validateToken(tokenString string) (*Claims, error) {
 		return nil, jwt.ErrSignatureInvalid
 	}
 
+	if revokedTokens.contains(claims.ID) {
+		return nil, ErrTokenRevoked
+	}
+
 	return claims, nil
-} 
\ No newline at end of file
+}
+
+// jwtSecret 签名密钥
+func jwtSecret() []byte {
+	return []byte(config.Conf.JWT.Secret)
+}
+
+// websocketRequest 是否为 WebSocket 升级请求
+func websocketRequest(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
+
+// tokenBlacklist 已注销 token 列表
+type tokenBlacklist struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+var revokedTokens = &tokenBlacklist{tokens: make(map[string]time.Time)}
+
+func (b *tokenBlacklist) add(id string, expiresAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 顺便清理已过期的记录
+	now := time.Now()
+	for k, exp := range b.tokens {
+		if now.After(exp) {
+			delete(b.tokens, k)
+		}
+	}
+	b.tokens[id] = expiresAt
+}
+
+func (b *tokenBlacklist) contains(id string) bool {
+	if id == "" {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.tokens[id]
+	return ok
+}
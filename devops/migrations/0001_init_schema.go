@@ -0,0 +1,130 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 初始表结构快照。迁移中的结构体必须保持定义时的样子，不能引用 models 中会继续演进的模型。
+
+type host0001 struct {
+	ID          uint      `gorm:"primarykey"`
+	Name        string    `gorm:"size:100;not null"`
+	IP          string    `gorm:"size:15;not null"`
+	Port        int       `gorm:"not null"`
+	Username    string    `gorm:"size:50;not null"`
+	Password    string    `gorm:"size:100;not null"`
+	Description string    `gorm:"size:500"`
+	CreatedTime time.Time `gorm:"autoCreateTime"`
+	UpdatedTime time.Time `gorm:"autoUpdateTime"`
+}
+
+func (host0001) TableName() string { return "hosts" }
+
+type repository0001 struct {
+	ID            uint   `gorm:"primarykey"`
+	Name          string `gorm:"size:255;not null"`
+	Platform      string `gorm:"size:50;not null"`
+	URL           string `gorm:"size:255;not null"`
+	Token         string `gorm:"size:255;not null"`
+	DefaultBranch string `gorm:"size:100"`
+	Status        string `gorm:"size:50;not null;default:'active'"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (repository0001) TableName() string { return "repositories" }
+
+type dockerRegistry0001 struct {
+	ID        uint   `gorm:"primarykey"`
+	Name      string `gorm:"size:100;not null;comment:仓库名称"`
+	Type      string `gorm:"size:50;not null;comment:仓库类型(public/private)"`
+	URL       string `gorm:"size:255;not null;comment:仓库地址"`
+	Username  string `gorm:"size:100;comment:用户名"`
+	Password  string `gorm:"size:255;comment:密码"`
+	Status    string `gorm:"size:20;default:'active';comment:状态"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (dockerRegistry0001) TableName() string { return "docker_registries" }
+
+type project0001 struct {
+	gorm.Model
+	Name            string    `gorm:"type:varchar(100);not null;comment:项目名称"`
+	Description     string    `gorm:"type:text;comment:项目描述"`
+	RepositoryID    uint      `gorm:"not null;comment:关联的代码仓库ID"`
+	Branch          string    `gorm:"type:varchar(100);not null;comment:构建分支"`
+	RegistryID      uint      `gorm:"not null;comment:关联的镜像仓库ID"`
+	ImageName       string    `gorm:"type:varchar(200);not null;comment:镜像名称"`
+	ImageTag        string    `gorm:"type:varchar(100);not null;comment:镜像标签"`
+	BuildScript     string    `gorm:"type:text;comment:构建脚本"`
+	Environment     string    `gorm:"type:varchar(50);comment:环境(dev/test/prod)"`
+	Version         string    `gorm:"type:varchar(50);comment:版本号"`
+	BuildTimeout    int       `gorm:"default:3600;comment:构建超时时间(秒)"`
+	AutoBuild       bool      `gorm:"default:false;comment:是否自动构建"`
+	BuildTriggers   string    `gorm:"type:text;comment:构建触发器配置(JSON)"`
+	LastBuildTime   time.Time `gorm:"comment:最后构建时间"`
+	LastBuildStatus string    `gorm:"type:varchar(20);comment:最后构建状态"`
+	CreatedBy       uint      `gorm:"comment:创建人ID"`
+	UpdatedBy       uint      `gorm:"comment:更新人ID"`
+}
+
+func (project0001) TableName() string { return "projects" }
+
+type user0001 struct {
+	ID          uint   `gorm:"primarykey"`
+	Username    string `gorm:"size:50;not null;uniqueIndex"`
+	Password    string `gorm:"size:100;not null"`
+	Nickname    string `gorm:"size:100"`
+	Email       string `gorm:"size:100"`
+	Status      string `gorm:"size:20;not null;default:'active'"`
+	LastLoginAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (user0001) TableName() string { return "users" }
+
+type role0001 struct {
+	ID          uint     `gorm:"primarykey"`
+	Name        string   `gorm:"size:50;not null;uniqueIndex"`
+	Description string   `gorm:"size:255"`
+	Permissions []string `gorm:"type:text;serializer:json"`
+	BuiltIn     bool     `gorm:"not null;default:false"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (role0001) TableName() string { return "roles" }
+
+type roleBinding0001 struct {
+	ID           uint   `gorm:"primarykey"`
+	UserID       uint   `gorm:"not null;index"`
+	RoleID       uint   `gorm:"not null;index"`
+	ResourceType string `gorm:"size:50"`
+	ResourceID   uint   `gorm:"not null;default:0"`
+	CreatedAt    time.Time
+}
+
+func (roleBinding0001) TableName() string { return "role_bindings" }
+
+func init() {
+	tables := []interface{}{
+		&host0001{}, &repository0001{}, &dockerRegistry0001{}, &project0001{},
+		&user0001{}, &role0001{}, &roleBinding0001{},
+	}
+
+	register(Migration{
+		Version: 1,
+		Name:    "init_schema",
+		// 使用 AutoMigrate 创建基线表，已由旧版本 AutoMigrate 建好的库执行时不会改动数据
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(tables...)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(tables...)
+		},
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type repository0002 struct {
+	LastSyncAt *time.Time
+}
+
+func (repository0002) TableName() string { return "repositories" }
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "repository_last_sync_at",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&repository0002{}, "LastSyncAt") {
+				if err := tx.Migrator().AddColumn(&repository0002{}, "LastSyncAt"); err != nil {
+					return err
+				}
+			}
+			// 已有仓库以最后更新时间作为同步时间
+			return tx.Exec("UPDATE repositories SET last_sync_at = updated_at WHERE last_sync_at IS NULL").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&repository0002{}, "LastSyncAt")
+		},
+	})
+}
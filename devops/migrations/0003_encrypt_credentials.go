@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+
+	"devops/secrets"
+)
+
+type host0003 struct {
+	ID       uint   `gorm:"primarykey"`
+	Password string `gorm:"size:1024;not null"`
+}
+
+func (host0003) TableName() string { return "hosts" }
+
+type repository0003 struct {
+	ID    uint   `gorm:"primarykey"`
+	Token string `gorm:"size:1024;not null"`
+}
+
+func (repository0003) TableName() string { return "repositories" }
+
+type dockerRegistry0003 struct {
+	ID       uint   `gorm:"primarykey"`
+	Password string `gorm:"size:1024;comment:密码"`
+}
+
+func (dockerRegistry0003) TableName() string { return "docker_registries" }
+
+// credentialColumns 需要加密的列
+var credentialColumns = []struct {
+	model  interface{}
+	field  string
+	column string
+}{
+	{&host0003{}, "Password", "password"},
+	{&repository0003{}, "Token", "token"},
+	{&dockerRegistry0003{}, "Password", "password"},
+}
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "encrypt_credentials",
+		Up: func(tx *gorm.DB) error {
+			for _, c := range credentialColumns {
+				if err := tx.Migrator().AlterColumn(c.model, c.field); err != nil {
+					return err
+				}
+				if err := convertColumn(tx, c.model, c.column, true); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// 回滚时解密为明文，列宽保持不变以免截断数据
+		Down: func(tx *gorm.DB) error {
+			for _, c := range credentialColumns {
+				if err := convertColumn(tx, c.model, c.column, false); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// convertColumn 逐行加密或解密列值，已处于目标状态的值跳过
+func convertColumn(tx *gorm.DB, model interface{}, column string, encrypt bool) error {
+	var rows []map[string]interface{}
+	if err := tx.Model(model).Select("id", column).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		var value string
+		switch v := row[column].(type) {
+		case string:
+			value = v
+		case []byte:
+			value = string(v)
+		}
+		if value == "" {
+			continue
+		}
+		if secrets.IsEncrypted(value) == encrypt {
+			continue
+		}
+
+		convert := secrets.Decrypt
+		if encrypt {
+			convert = secrets.Encrypt
+		}
+		converted, err := convert(value)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(model).Where("id = ?", row["id"]).UpdateColumn(column, converted).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type credential0004 struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"size:100;not null"`
+	Type        string `gorm:"size:20;not null"`
+	Username    string `gorm:"size:50"`
+	Password    string `gorm:"size:1024"`
+	PrivateKey  string `gorm:"type:text"`
+	Passphrase  string `gorm:"size:1024"`
+	Certificate string `gorm:"type:text"`
+	Description string `gorm:"size:500"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (credential0004) TableName() string { return "credentials" }
+
+type host0004 struct {
+	CredentialID *uint `gorm:"index"`
+}
+
+func (host0004) TableName() string { return "hosts" }
+
+type role0004 struct {
+	ID          uint     `gorm:"primarykey"`
+	Name        string   `gorm:"size:50;not null;uniqueIndex"`
+	Permissions []string `gorm:"type:text;serializer:json"`
+	BuiltIn     bool
+}
+
+func (role0004) TableName() string { return "roles" }
+
+// credentialPermission 内置运维角色新增的凭据管理权限
+const credentialPermission = "credential:*"
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "credentials",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&credential0004{}); err != nil {
+				return err
+			}
+			if !tx.Migrator().HasColumn(&host0004{}, "CredentialID") {
+				if err := tx.Migrator().AddColumn(&host0004{}, "CredentialID"); err != nil {
+					return err
+				}
+			}
+			if !tx.Migrator().HasIndex(&host0004{}, "CredentialID") {
+				if err := tx.Migrator().CreateIndex(&host0004{}, "CredentialID"); err != nil {
+					return err
+				}
+			}
+			return updateOperatorPermissions(tx, true)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := updateOperatorPermissions(tx, false); err != nil {
+				return err
+			}
+			if tx.Migrator().HasIndex(&host0004{}, "CredentialID") {
+				if err := tx.Migrator().DropIndex(&host0004{}, "CredentialID"); err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().DropColumn(&host0004{}, "CredentialID"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&credential0004{})
+		},
+	})
+}
+
+// updateOperatorPermissions 为已存在的内置 operator 角色添加或移除凭据管理权限
+func updateOperatorPermissions(tx *gorm.DB, grant bool) error {
+	var role role0004
+	err := tx.Where("name = ? AND built_in = ?", "operator", true).Limit(1).Find(&role).Error
+	if err != nil || role.ID == 0 {
+		return err
+	}
+
+	var permissions []string
+	for _, perm := range role.Permissions {
+		if perm != credentialPermission {
+			permissions = append(permissions, perm)
+		}
+	}
+	if grant {
+		permissions = append(permissions, credentialPermission)
+	}
+	return tx.Model(&role).Select("Permissions").Updates(&role0004{Permissions: permissions}).Error
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type host0005 struct {
+	HostKey                   string `gorm:"type:text"`
+	HostKeyFingerprint        string `gorm:"size:100"`
+	HostKeyVerifiedAt         *time.Time
+	PendingHostKey            string `gorm:"type:text"`
+	PendingHostKeyFingerprint string `gorm:"size:100"`
+}
+
+func (host0005) TableName() string { return "hosts" }
+
+var hostKeyFields = []string{
+	"HostKey", "HostKeyFingerprint", "HostKeyVerifiedAt", "PendingHostKey", "PendingHostKeyFingerprint",
+}
+
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "host_keys",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range hostKeyFields {
+				if tx.Migrator().HasColumn(&host0005{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&host0005{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, field := range hostKeyFields {
+				if err := tx.Migrator().DropColumn(&host0005{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
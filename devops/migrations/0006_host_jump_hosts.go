@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+type host0006 struct {
+	JumpHostIDs []uint `gorm:"type:text;serializer:json"`
+}
+
+func (host0006) TableName() string { return "hosts" }
+
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "host_jump_hosts",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&host0006{}, "JumpHostIDs") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&host0006{}, "JumpHostIDs")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&host0006{}, "JumpHostIDs")
+		},
+	})
+}
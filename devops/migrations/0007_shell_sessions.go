@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type shellSession0007 struct {
+	ID            uint      `gorm:"primarykey"`
+	UserID        uint      `gorm:"not null;index"`
+	Username      string    `gorm:"size:50;not null"`
+	HostID        uint      `gorm:"not null;index"`
+	HostName      string    `gorm:"size:100;not null"`
+	HostAddr      string    `gorm:"size:255;not null"`
+	ClientIP      string    `gorm:"size:64"`
+	Status        string    `gorm:"size:20;not null;index"`
+	StartedAt     time.Time `gorm:"not null;index"`
+	EndedAt       *time.Time
+	ExitStatus    *int
+	RecordingPath string `gorm:"size:500"`
+	RecordingSize int64
+}
+
+func (shellSession0007) TableName() string { return "shell_sessions" }
+
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "shell_sessions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&shellSession0007{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&shellSession0007{})
+		},
+	})
+}
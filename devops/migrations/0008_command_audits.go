@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type commandAudit0008 struct {
+	ID         uint   `gorm:"primarykey"`
+	SessionID  uint   `gorm:"not null;index"`
+	UserID     uint   `gorm:"not null;index"`
+	Username   string `gorm:"size:50;not null"`
+	HostID     uint   `gorm:"not null;index"`
+	HostName   string `gorm:"size:100;not null"`
+	Command    string `gorm:"type:text;not null"`
+	Elapsed    float64
+	ExecutedAt time.Time `gorm:"not null;index"`
+}
+
+func (commandAudit0008) TableName() string { return "command_audits" }
+
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "command_audits",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&commandAudit0008{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&commandAudit0008{})
+		},
+	})
+}
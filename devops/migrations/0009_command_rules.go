@@ -0,0 +1,96 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type commandRule0009 struct {
+	ID           uint     `gorm:"primarykey"`
+	Name         string   `gorm:"size:100;not null"`
+	Pattern      string   `gorm:"size:500;not null"`
+	Action       string   `gorm:"size:20;not null"`
+	Environments []string `gorm:"type:text;serializer:json"`
+	HostIDs      []uint   `gorm:"type:text;serializer:json"`
+	Enabled      bool     `gorm:"not null"`
+	Description  string   `gorm:"size:500"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (commandRule0009) TableName() string { return "command_rules" }
+
+type host0009 struct {
+	Environment string `gorm:"size:50;index"`
+}
+
+func (host0009) TableName() string { return "hosts" }
+
+type commandAudit0009 struct {
+	Status     string `gorm:"size:20;not null;default:executed;index"`
+	RuleID     *uint
+	RuleName   string `gorm:"size:100"`
+	Reviewer   string `gorm:"size:50"`
+	ReviewedAt *time.Time
+	Remark     string `gorm:"size:500"`
+}
+
+func (commandAudit0009) TableName() string { return "command_audits" }
+
+var commandAuditPolicyFields = []string{"Status", "RuleID", "RuleName", "Reviewer", "ReviewedAt", "Remark"}
+
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "command_rules",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&commandRule0009{}); err != nil {
+				return err
+			}
+			if !tx.Migrator().HasColumn(&host0009{}, "Environment") {
+				if err := tx.Migrator().AddColumn(&host0009{}, "Environment"); err != nil {
+					return err
+				}
+			}
+			if !tx.Migrator().HasIndex(&host0009{}, "Environment") {
+				if err := tx.Migrator().CreateIndex(&host0009{}, "Environment"); err != nil {
+					return err
+				}
+			}
+			for _, field := range commandAuditPolicyFields {
+				if tx.Migrator().HasColumn(&commandAudit0009{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&commandAudit0009{}, field); err != nil {
+					return err
+				}
+			}
+			if !tx.Migrator().HasIndex(&commandAudit0009{}, "Status") {
+				return tx.Migrator().CreateIndex(&commandAudit0009{}, "Status")
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex(&commandAudit0009{}, "Status") {
+				if err := tx.Migrator().DropIndex(&commandAudit0009{}, "Status"); err != nil {
+					return err
+				}
+			}
+			for _, field := range commandAuditPolicyFields {
+				if err := tx.Migrator().DropColumn(&commandAudit0009{}, field); err != nil {
+					return err
+				}
+			}
+			if tx.Migrator().HasIndex(&host0009{}, "Environment") {
+				if err := tx.Migrator().DropIndex(&host0009{}, "Environment"); err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().DropColumn(&host0009{}, "Environment"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&commandRule0009{})
+		},
+	})
+}
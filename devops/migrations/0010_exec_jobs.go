@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type execJob0010 struct {
+	ID          uint      `gorm:"primarykey"`
+	UserID      uint      `gorm:"not null;index"`
+	Username    string    `gorm:"size:50;not null"`
+	Command     string    `gorm:"type:text;not null"`
+	Parallelism int       `gorm:"not null"`
+	Timeout     int       `gorm:"not null"`
+	Status      string    `gorm:"size:20;not null;index"`
+	Total       int       `gorm:"not null"`
+	Succeeded   int       `gorm:"not null"`
+	Failed      int       `gorm:"not null"`
+	StartedAt   time.Time `gorm:"not null;index"`
+	FinishedAt  *time.Time
+}
+
+func (execJob0010) TableName() string { return "exec_jobs" }
+
+type execResult0010 struct {
+	ID         uint   `gorm:"primarykey"`
+	JobID      uint   `gorm:"not null;index"`
+	HostID     uint   `gorm:"not null;index"`
+	HostName   string `gorm:"size:100;not null"`
+	HostAddr   string `gorm:"size:255;not null"`
+	Status     string `gorm:"size:20;not null"`
+	ExitCode   *int
+	Stdout     string `gorm:"type:text"`
+	Stderr     string `gorm:"type:text"`
+	Error      string `gorm:"size:500"`
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+func (execResult0010) TableName() string { return "exec_results" }
+
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "exec_jobs",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&execJob0010{}, &execResult0010{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&execResult0010{}, &execJob0010{})
+		},
+	})
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type hostGroup0011 struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"size:100;not null"`
+	ParentID    *uint  `gorm:"index"`
+	Description string `gorm:"size:500"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (hostGroup0011) TableName() string { return "host_groups" }
+
+type hostGroupHost0011 struct {
+	HostID      uint `gorm:"primaryKey;autoIncrement:false"`
+	HostGroupID uint `gorm:"primaryKey;autoIncrement:false;index"`
+}
+
+func (hostGroupHost0011) TableName() string { return "host_group_hosts" }
+
+type label0011 struct {
+	ID    uint   `gorm:"primarykey"`
+	Name  string `gorm:"size:63;not null;uniqueIndex:idx_labels_name_value"`
+	Value string `gorm:"size:63;not null;uniqueIndex:idx_labels_name_value"`
+}
+
+func (label0011) TableName() string { return "labels" }
+
+type hostLabel0011 struct {
+	HostID  uint `gorm:"primaryKey;autoIncrement:false"`
+	LabelID uint `gorm:"primaryKey;autoIncrement:false;index"`
+}
+
+func (hostLabel0011) TableName() string { return "host_labels" }
+
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "host_groups_labels",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&hostGroup0011{}, &hostGroupHost0011{}, &label0011{}, &hostLabel0011{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&hostLabel0011{}, &label0011{}, &hostGroupHost0011{}, &hostGroup0011{})
+		},
+	})
+}
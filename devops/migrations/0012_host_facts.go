@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type hostFacts0012 struct {
+	ID            uint   `gorm:"primarykey"`
+	HostID        uint   `gorm:"not null;uniqueIndex:idx_host_facts_host_version"`
+	Version       int    `gorm:"not null;uniqueIndex:idx_host_facts_host_version"`
+	Hostname      string `gorm:"size:255"`
+	OS            string `gorm:"size:50"`
+	OSName        string `gorm:"size:255"`
+	OSVersion     string `gorm:"size:50"`
+	Kernel        string `gorm:"size:100"`
+	Arch          string `gorm:"size:50"`
+	CPUCount      int
+	MemoryTotal   int64
+	Disks         string `gorm:"type:text"`
+	Interfaces    string `gorm:"type:text"`
+	Uptime        int64
+	DockerVersion string    `gorm:"size:50"`
+	CollectedAt   time.Time `gorm:"not null"`
+}
+
+func (hostFacts0012) TableName() string { return "host_facts" }
+
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "host_facts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&hostFacts0012{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&hostFacts0012{})
+		},
+	})
+}
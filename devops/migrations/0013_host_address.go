@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+type host0013 struct {
+	IP string `gorm:"size:255;not null"`
+}
+
+func (host0013) TableName() string { return "hosts" }
+
+type host0013Down struct {
+	IP string `gorm:"size:15;not null"`
+}
+
+func (host0013Down) TableName() string { return "hosts" }
+
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "host_address",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AlterColumn(&host0013{}, "IP")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().AlterColumn(&host0013Down{}, "IP")
+		},
+	})
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type sftpUpload0014 struct {
+	ID        uint   `gorm:"primarykey"`
+	HostID    uint   `gorm:"not null;index"`
+	UserID    uint   `gorm:"not null;index"`
+	Path      string `gorm:"size:1024;not null"`
+	TempPath  string `gorm:"size:1024;not null"`
+	Size      int64  `gorm:"not null"`
+	SHA256    string `gorm:"size:64"`
+	Received  string `gorm:"type:text"`
+	Uploaded  int64  `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (sftpUpload0014) TableName() string { return "sftp_uploads" }
+
+func init() {
+	register(Migration{
+		Version: 14,
+		Name:    "sftp_uploads",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&sftpUpload0014{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&sftpUpload0014{})
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+type commandRule0015 struct {
+	GroupIDs []uint `gorm:"type:text;serializer:json"`
+}
+
+func (commandRule0015) TableName() string { return "command_rules" }
+
+func init() {
+	register(Migration{
+		Version: 15,
+		Name:    "command_rule_groups",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&commandRule0015{}, "GroupIDs") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&commandRule0015{}, "GroupIDs")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&commandRule0015{}, "GroupIDs")
+		},
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type user0016 struct {
+	TokensValidAfter *time.Time
+}
+
+func (user0016) TableName() string { return "users" }
+
+func init() {
+	register(Migration{
+		Version: 16,
+		Name:    "user_tokens_valid_after",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&user0016{}, "TokensValidAfter") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&user0016{}, "TokensValidAfter")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&user0016{}, "TokensValidAfter")
+		},
+	})
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"gorm.io/gorm"
+)
+
+// CommandUsage migrate 子命令用法
+const CommandUsage = `用法: devops [-config 配置文件] migrate <命令>
+
+命令:
+  status        查看迁移状态
+  up            执行全部未执行的迁移
+  down [n]      回滚最近 n 个迁移 (默认 1)
+  to <version>  迁移到指定版本 (0 表示回滚全部)
+`
+
+// RunCommand 执行 migrate 子命令
+func RunCommand(db *gorm.DB, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(w, CommandUsage)
+		return fmt.Errorf("缺少 migrate 命令")
+	}
+
+	switch args[0] {
+	case "status":
+		return printStatus(db, w)
+	case "up":
+		if err := Up(db); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("无效的回滚数量: %s", args[1])
+			}
+			steps = n
+		}
+		if err := Down(db, steps); err != nil {
+			return err
+		}
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("缺少目标版本")
+		}
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("无效的版本: %s", args[1])
+		}
+		if err := To(db, uint(version)); err != nil {
+			return err
+		}
+	default:
+		fmt.Fprint(w, CommandUsage)
+		return fmt.Errorf("未知的 migrate 命令: %s", args[0])
+	}
+
+	return printStatus(db, w)
+}
+
+// printStatus 输出迁移状态表
+func printStatus(db *gorm.DB, w io.Writer) error {
+	statuses, err := GetStatus(db)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	return tw.Flush()
+}
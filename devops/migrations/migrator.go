@@ -0,0 +1,221 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一次版本化的数据库结构变更
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   uint      `gorm:"primarykey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName 指定表名
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+var registry []Migration
+
+// register 注册迁移，由各迁移文件的 init 调用
+func register(m Migration) {
+	for _, existing := range registry {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf("迁移版本重复: %d", m.Version))
+		}
+	}
+	registry = append(registry, m)
+	sort.Slice(registry, func(i, j int) bool {
+		return registry[i].Version < registry[j].Version
+	})
+}
+
+// All 返回按版本排序的全部迁移
+func All() []Migration {
+	return append([]Migration(nil), registry...)
+}
+
+// Latest 返回最新的迁移版本
+func Latest() uint {
+	if len(registry) == 0 {
+		return 0
+	}
+	return registry[len(registry)-1].Version
+}
+
+// applied 读取已执行的迁移
+func applied(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("创建 schema_migrations 表失败: %v", err)
+	}
+
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint]SchemaMigration, len(records))
+	for _, record := range records {
+		result[record.Version] = record
+	}
+	return result, nil
+}
+
+// GetStatus 返回每个迁移的执行状态
+func GetStatus(db *gorm.DB) ([]Status, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Status
+	for _, m := range registry {
+		status := Status{Version: m.Version, Name: m.Name}
+		if record, ok := done[m.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// Pending 返回尚未执行的迁移
+func Pending(db *gorm.DB) ([]Migration, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Migration
+	for _, m := range registry {
+		if _, ok := done[m.Version]; !ok {
+			result = append(result, m)
+		}
+	}
+	return result, nil
+}
+
+// Up 执行全部未执行的迁移
+func Up(db *gorm.DB) error {
+	return To(db, Latest())
+}
+
+// Down 回滚最近执行的 steps 个迁移
+func Down(db *gorm.DB, steps int) error {
+	done, err := applied(db)
+	if err != nil {
+		return err
+	}
+
+	for i := len(registry) - 1; i >= 0 && steps > 0; i-- {
+		m := registry[i]
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+		if err := rollback(db, m); err != nil {
+			return err
+		}
+		steps--
+	}
+	return nil
+}
+
+// To 将数据库迁移到指定版本：执行不超过该版本的未执行迁移，回滚高于该版本的已执行迁移
+func To(db *gorm.DB, version uint) error {
+	if version != 0 && !known(version) {
+		return fmt.Errorf("未知的迁移版本: %d", version)
+	}
+
+	done, err := applied(db)
+	if err != nil {
+		return err
+	}
+
+	for i := len(registry) - 1; i >= 0; i-- {
+		m := registry[i]
+		if _, ok := done[m.Version]; ok && m.Version > version {
+			if err := rollback(db, m); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, m := range registry {
+		if _, ok := done[m.Version]; !ok && m.Version <= version {
+			if err := apply(db, m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply 执行单个迁移并记录
+func apply(db *gorm.DB, m Migration) error {
+	log.Printf("执行迁移 %04d_%s", m.Version, m.Name)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := m.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("迁移 %04d_%s 执行失败: %v", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+// rollback 回滚单个迁移并删除记录
+func rollback(db *gorm.DB, m Migration) error {
+	if m.Down == nil {
+		return fmt.Errorf("迁移 %04d_%s 不支持回滚", m.Version, m.Name)
+	}
+
+	log.Printf("回滚迁移 %04d_%s", m.Version, m.Name)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigration{}, m.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("迁移 %04d_%s 回滚失败: %v", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+// known 版本是否已注册
+func known(version uint) bool {
+	for _, m := range registry {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrPending 存在未执行的迁移
+var ErrPending = errors.New("数据库存在未执行的迁移，请先运行 migrate up")
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 命令审计状态
+const (
+	CommandExecuted = "executed" // 已执行
+	CommandBlocked  = "blocked"  // 被规则禁止
+	CommandPending  = "pending"  // 等待审批
+	CommandApproved = "approved" // 审批通过，命令随后发送到服务器并另行记录为已执行
+	CommandRejected = "rejected" // 审批拒绝、超时或用户取消
+)
+
+// CommandAudit WebShell 中执行的命令审计记录
+type CommandAudit struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	SessionID  uint       `gorm:"not null;index" json:"sessionId"`
+	UserID     uint       `gorm:"not null;index" json:"userId"`
+	Username   string     `gorm:"size:50;not null" json:"username"`
+	HostID     uint       `gorm:"not null;index" json:"hostId"`
+	HostName   string     `gorm:"size:100;not null" json:"hostName"`
+	Command    string     `gorm:"type:text;not null" json:"command"`
+	Elapsed    float64    `json:"elapsed"` // 命令在会话录像中的时间点（秒），用于定位回放
+	ExecutedAt time.Time  `gorm:"not null;index" json:"executedAt"`
+	Status     string     `gorm:"size:20;not null;default:executed;index" json:"status"`
+	RuleID     *uint      `json:"ruleId"` // 命中的命令规则
+	RuleName   string     `gorm:"size:100" json:"ruleName"`
+	Reviewer   string     `gorm:"size:50" json:"reviewer"` // 审批人，超时或用户取消时为空
+	ReviewedAt *time.Time `json:"reviewedAt"`
+	Remark     string     `gorm:"size:500" json:"remark"`
+}
+
+// TableName 指定表名
+func (CommandAudit) TableName() string {
+	return "command_audits"
+}
+
+// CommandAuditQuery 命令审计查询条件
+type CommandAuditQuery struct {
+	Keyword   string // 命令包含的内容
+	UserID    uint
+	Username  string
+	HostID    uint
+	HostName  string
+	SessionID uint
+	Status    string
+	From      *time.Time
+	To        *time.Time
+}
+
+// CreateCommandAudit 创建命令审计记录
+func CreateCommandAudit(db *gorm.DB, audit *CommandAudit) error {
+	return db.Create(audit).Error
+}
+
+// GetCommandAudit 获取命令审计记录
+func GetCommandAudit(db *gorm.DB, id uint) (*CommandAudit, error) {
+	var audit CommandAudit
+	err := db.First(&audit, id).Error
+	return &audit, err
+}
+
+// ResolveCommandAudit 更新等待审批的命令状态，记录已被处理时返回 false
+func ResolveCommandAudit(db *gorm.DB, id uint, status, reviewer, remark string) (bool, error) {
+	now := time.Now()
+	result := db.Model(&CommandAudit{}).Where("id = ? AND status = ?", id, CommandPending).Updates(map[string]interface{}{
+		"status":      status,
+		"reviewer":    reviewer,
+		"reviewed_at": &now,
+		"remark":      remark,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RejectPendingCommandAudits 将服务重启前未处理的审批标记为拒绝
+func RejectPendingCommandAudits(db *gorm.DB) error {
+	return db.Model(&CommandAudit{}).Where("status = ?", CommandPending).Updates(map[string]interface{}{
+		"status": CommandRejected,
+		"remark": "服务重启，审批已取消",
+	}).Error
+}
+
+// GetCommandAuditList 查询命令审计记录，按执行时间倒序
+func GetCommandAuditList(db *gorm.DB, page, pageSize int, q CommandAuditQuery) ([]CommandAudit, int64, error) {
+	var audits []CommandAudit
+	var total int64
+
+	query := db.Model(&CommandAudit{})
+	if q.Keyword != "" {
+		query = query.Where("command LIKE ?", "%"+q.Keyword+"%")
+	}
+	if q.UserID != 0 {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if q.Username != "" {
+		query = query.Where("username LIKE ?", "%"+q.Username+"%")
+	}
+	if q.HostID != 0 {
+		query = query.Where("host_id = ?", q.HostID)
+	}
+	if q.HostName != "" {
+		query = query.Where("host_name LIKE ?", "%"+q.HostName+"%")
+	}
+	if q.SessionID != 0 {
+		query = query.Where("session_id = ?", q.SessionID)
+	}
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	if q.From != nil {
+		query = query.Where("executed_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		query = query.Where("executed_at < ?", *q.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("executed_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&audits).Error
+	return audits, total, err
+}
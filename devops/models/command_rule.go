@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 命令规则动作
+const (
+	CommandRuleBlock   = "block"   // 禁止执行
+	CommandRuleApprove = "approve" // 审批通过后执行
+)
+
+// CommandRule WebShell 命令策略规则，命令行匹配 Pattern 时按 Action 处理。
+// Environments、GroupIDs 与 HostIDs 限定生效范围，均为空时对所有主机生效，否则主机满足其一即生效；
+// GroupIDs 同时包含各分组的下级分组。
+type CommandRule struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	Name         string    `gorm:"size:100;not null" json:"name"`
+	Pattern      string    `gorm:"size:500;not null" json:"pattern"` // 正则表达式
+	Action       string    `gorm:"size:20;not null" json:"action"`
+	Environments []string  `gorm:"type:text;serializer:json" json:"environments"`
+	GroupIDs     []uint    `gorm:"type:text;serializer:json" json:"groupIds"`
+	HostIDs      []uint    `gorm:"type:text;serializer:json" json:"hostIds"`
+	Enabled      bool      `gorm:"not null" json:"enabled"`
+	Description  string    `gorm:"size:500" json:"description"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (CommandRule) TableName() string {
+	return "command_rules"
+}
+
+// AppliesTo 判断规则是否对主机生效，hostGroups 为主机所属分组及其全部上级分组，见 GetHostGroupAncestors
+func (r *CommandRule) AppliesTo(host *Host, hostGroups []uint) bool {
+	if len(r.Environments) == 0 && len(r.GroupIDs) == 0 && len(r.HostIDs) == 0 {
+		return true
+	}
+	for _, env := range r.Environments {
+		if env == host.Environment {
+			return true
+		}
+	}
+	for _, id := range r.GroupIDs {
+		for _, group := range hostGroups {
+			if id == group {
+				return true
+			}
+		}
+	}
+	for _, id := range r.HostIDs {
+		if id == host.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateCommandRule 创建命令规则
+func CreateCommandRule(db *gorm.DB, rule *CommandRule) error {
+	return db.Create(rule).Error
+}
+
+// GetCommandRule 获取命令规则
+func GetCommandRule(db *gorm.DB, id uint) (*CommandRule, error) {
+	var rule CommandRule
+	err := db.First(&rule, id).Error
+	return &rule, err
+}
+
+// GetCommandRuleList 获取命令规则列表
+func GetCommandRuleList(db *gorm.DB, page, pageSize int, name string) ([]CommandRule, int64, error) {
+	var rules []CommandRule
+	var total int64
+
+	query := db.Model(&CommandRule{})
+	if name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&rules).Error
+	return rules, total, err
+}
+
+// GetEnabledCommandRules 获取全部启用的命令规则
+func GetEnabledCommandRules(db *gorm.DB) ([]CommandRule, error) {
+	var rules []CommandRule
+	err := db.Where("enabled = ?", true).Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// UpdateCommandRule 更新命令规则，生效范围与启用状态可更新为空值
+func UpdateCommandRule(db *gorm.DB, id uint, rule *CommandRule) error {
+	return db.Model(&CommandRule{}).Where("id = ?", id).
+		Select("Name", "Pattern", "Action", "Environments", "GroupIDs", "HostIDs", "Enabled", "Description").
+		Updates(rule).Error
+}
+
+// DeleteCommandRule 删除命令规则
+func DeleteCommandRule(db *gorm.DB, id uint) error {
+	return db.Delete(&CommandRule{}, id).Error
+}
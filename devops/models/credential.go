@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 凭据类型
+const (
+	CredentialPassword    = "password"    // 密码
+	CredentialPrivateKey  = "private_key" // PEM 私钥（可带密码短语）
+	CredentialCertificate = "certificate" // 私钥 + OpenSSH 用户证书
+)
+
+// Credential 主机登录凭据
+type Credential struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Type        string    `gorm:"size:20;not null" json:"type"`
+	Username    string    `gorm:"size:50" json:"username"` // 为空时使用主机上配置的用户名
+	Password    Secret    `gorm:"size:1024;serializer:encrypted" json:"password"`
+	PrivateKey  Secret    `gorm:"type:text;serializer:encrypted" json:"privateKey"`
+	Passphrase  Secret    `gorm:"size:1024;serializer:encrypted" json:"passphrase"`
+	Certificate string    `gorm:"type:text" json:"certificate"` // authorized_keys 格式的 OpenSSH 用户证书
+	Description string    `gorm:"size:500" json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (Credential) TableName() string {
+	return "credentials"
+}
+
+// CreateCredential 创建凭据
+func CreateCredential(db *gorm.DB, credential *Credential) error {
+	return db.Create(credential).Error
+}
+
+// GetCredential 获取凭据
+func GetCredential(db *gorm.DB, id uint) (*Credential, error) {
+	var credential Credential
+	err := db.First(&credential, id).Error
+	return &credential, err
+}
+
+// GetCredentialList 获取凭据列表
+func GetCredentialList(db *gorm.DB, page, pageSize int, name string) ([]Credential, int64, error) {
+	var credentials []Credential
+	var total int64
+
+	query := db.Model(&Credential{})
+	if name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Offset((page - 1) * pageSize).Limit(pageSize).Find(&credentials).Error
+	return credentials, total, err
+}
+
+// UpdateCredential 更新凭据，敏感字段为空时保持原值；更换私钥时密码短语随之替换
+func UpdateCredential(db *gorm.DB, id uint, credential *Credential) error {
+	fields := []string{"name", "type", "username", "certificate", "description"}
+	if credential.Password.IsSet() {
+		fields = append(fields, "password")
+	}
+	if credential.PrivateKey.IsSet() {
+		fields = append(fields, "private_key", "passphrase")
+	} else if credential.Passphrase.IsSet() {
+		fields = append(fields, "passphrase")
+	}
+	return db.Model(&Credential{}).Where("id = ?", id).Select(fields).Updates(credential).Error
+}
+
+// DeleteCredential 删除凭据
+func DeleteCredential(db *gorm.DB, id uint) error {
+	return db.Delete(&Credential{}, id).Error
+}
+
+// GetHostIDsByCredential 获取使用该凭据的主机ID
+func GetHostIDsByCredential(db *gorm.DB, id uint) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&Host{}).Where("credential_id = ?", id).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// CountHostsByCredential 统计使用该凭据的主机数量
+func CountHostsByCredential(db *gorm.DB, id uint) (int64, error) {
+	var count int64
+	err := db.Model(&Host{}).Where("credential_id = ?", id).Count(&count).Error
+	return count, err
+}
@@ -12,7 +12,7 @@ type DockerRegistry struct {
 	Type      string    `json:"type" gorm:"size:50;not null;comment:仓库类型(public/private)"`
 	URL       string    `json:"url" gorm:"size:255;not null;comment:仓库地址"`
 	Username  string    `json:"username" gorm:"size:100;comment:用户名"`
-	Password  string    `json:"password" gorm:"size:255;comment:密码"`
+	Password  Secret    `json:"password" gorm:"size:1024;serializer:encrypted;comment:密码"`
 	Status    string    `json:"status" gorm:"size:20;default:'active';comment:状态"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
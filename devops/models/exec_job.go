@@ -0,0 +1,160 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 批量执行任务状态
+const (
+	ExecJobRunning     = "running"
+	ExecJobFinished    = "finished"
+	ExecJobCanceled    = "canceled"
+	ExecJobInterrupted = "interrupted" // 服务重启时仍在执行
+)
+
+// 单台主机的执行状态
+const (
+	ExecPending  = "pending"
+	ExecRunning  = "running"
+	ExecSuccess  = "success" // 退出码为 0
+	ExecFailed   = "failed"  // 退出码非 0
+	ExecTimeout  = "timeout"
+	ExecError    = "error" // 连接失败等，命令未能执行
+	ExecCanceled = "canceled"
+)
+
+// ExecJob 在多台主机上批量执行命令的任务
+type ExecJob struct {
+	ID          uint         `gorm:"primarykey" json:"id"`
+	UserID      uint         `gorm:"not null;index" json:"userId"`
+	Username    string       `gorm:"size:50;not null" json:"username"`
+	Command     string       `gorm:"type:text;not null" json:"command"`
+	Parallelism int          `gorm:"not null" json:"parallelism"`
+	Timeout     int          `gorm:"not null" json:"timeout"` // 每台主机的超时时间，单位秒
+	Status      string       `gorm:"size:20;not null;index" json:"status"`
+	Total       int          `gorm:"not null" json:"total"`
+	Succeeded   int          `gorm:"not null" json:"succeeded"`
+	Failed      int          `gorm:"not null" json:"failed"`
+	StartedAt   time.Time    `gorm:"not null;index" json:"startedAt"`
+	FinishedAt  *time.Time   `json:"finishedAt"`
+	Results     []ExecResult `gorm:"foreignKey:JobID" json:"results,omitempty"`
+}
+
+// TableName 指定表名
+func (ExecJob) TableName() string {
+	return "exec_jobs"
+}
+
+// ExecResult 任务在单台主机上的执行结果
+type ExecResult struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	JobID      uint       `gorm:"not null;index" json:"jobId"`
+	HostID     uint       `gorm:"not null;index" json:"hostId"`
+	HostName   string     `gorm:"size:100;not null" json:"hostName"` // 主机名称快照
+	HostAddr   string     `gorm:"size:255;not null" json:"hostAddr"`
+	Status     string     `gorm:"size:20;not null" json:"status"`
+	ExitCode   *int       `json:"exitCode"`
+	Stdout     string     `gorm:"type:text" json:"stdout"`
+	Stderr     string     `gorm:"type:text" json:"stderr"`
+	Error      string     `gorm:"size:500" json:"error"`
+	StartedAt  *time.Time `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// TableName 指定表名
+func (ExecResult) TableName() string {
+	return "exec_results"
+}
+
+// ExecJobQuery 任务查询条件
+type ExecJobQuery struct {
+	UserID   uint
+	Username string
+	Status   string
+	From     *time.Time
+	To       *time.Time
+}
+
+// CreateExecJob 创建任务及各主机的执行记录
+func CreateExecJob(db *gorm.DB, job *ExecJob) error {
+	return db.Create(job).Error
+}
+
+// GetExecJob 获取任务及各主机的执行结果
+func GetExecJob(db *gorm.DB, id uint) (*ExecJob, error) {
+	var job ExecJob
+	err := db.Preload("Results", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&job, id).Error
+	return &job, err
+}
+
+// UpdateExecResult 更新单台主机的执行结果
+func UpdateExecResult(db *gorm.DB, result *ExecResult) error {
+	return db.Model(&ExecResult{}).Where("id = ?", result.ID).
+		Select("Status", "ExitCode", "Stdout", "Stderr", "Error", "StartedAt", "FinishedAt").
+		Updates(result).Error
+}
+
+// FinishExecJob 结束任务并记录成功与失败的主机数
+func FinishExecJob(db *gorm.DB, id uint, status string, succeeded, failed int, finishedAt time.Time) error {
+	return db.Model(&ExecJob{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      status,
+		"succeeded":   succeeded,
+		"failed":      failed,
+		"finished_at": finishedAt,
+	}).Error
+}
+
+// InterruptStaleExecJobs 将服务重启前仍在执行的任务及主机标记为中断
+func InterruptStaleExecJobs(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Model(&ExecJob{}).Where("status = ?", ExecJobRunning).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Model(&ExecResult{}).Where("job_id IN ? AND status IN ?", ids, []string{ExecPending, ExecRunning}).
+			Updates(map[string]interface{}{"status": ExecCanceled, "error": "服务重启，执行中断"}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&ExecJob{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":      ExecJobInterrupted,
+			"finished_at": time.Now(),
+		}).Error
+	})
+}
+
+// GetExecJobList 获取任务列表（不含执行结果），按开始时间倒序
+func GetExecJobList(db *gorm.DB, page, pageSize int, q ExecJobQuery) ([]ExecJob, int64, error) {
+	var jobs []ExecJob
+	var total int64
+
+	query := db.Model(&ExecJob{})
+	if q.UserID != 0 {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if q.Username != "" {
+		query = query.Where("username LIKE ?", "%"+q.Username+"%")
+	}
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	if q.From != nil {
+		query = query.Where("started_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		query = query.Where("started_at < ?", *q.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("started_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&jobs).Error
+	return jobs, total, err
+}
@@ -1,21 +1,40 @@
 package models
 
 import (
-	"gorm.io/gorm"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Host 主机模型
 type Host struct {
-	ID          uint      `gorm:"primarykey" json:"id"`
-	Name        string    `gorm:"size:100;not null" json:"name"`
-	IP          string    `gorm:"size:15;not null" json:"ip"`
-	Port        int       `gorm:"not null" json:"port"`
-	Username    string    `gorm:"size:50;not null" json:"username"`
-	Password    string    `gorm:"size:100;not null" json:"password"`
-	Description string    `gorm:"size:500" json:"description"`
-	CreatedTime time.Time `gorm:"autoCreateTime" json:"createdTime"`
-	UpdatedTime time.Time `gorm:"autoUpdateTime" json:"updatedTime"`
+	ID           uint        `gorm:"primarykey" json:"id"`
+	Name         string      `gorm:"size:100;not null" json:"name"`
+	IP           string      `gorm:"size:255;not null" json:"ip"` // IPv4、IPv6 地址或域名
+	Port         int         `gorm:"not null" json:"port"`
+	Username     string      `gorm:"size:50;not null" json:"username"`
+	Password     Secret      `gorm:"size:1024;not null;serializer:encrypted" json:"password"`
+	CredentialID *uint       `gorm:"index" json:"credentialId"` // 设置后使用凭据登录，否则使用 Password
+	Credential   *Credential `gorm:"foreignKey:CredentialID" json:"credential,omitempty"`
+	JumpHostIDs  []uint      `gorm:"type:text;serializer:json" json:"jumpHostIds"` // 跳板机链，按连接顺序排列
+	Environment  string      `gorm:"size:50;index" json:"environment"`             // 所属环境，如 prod、staging，用于命令策略等按环境生效的配置
+	Description  string      `gorm:"size:500" json:"description"`
+	Groups       []HostGroup `gorm:"many2many:host_group_hosts" json:"groups"`
+	LabelRefs    []Label     `gorm:"many2many:host_labels" json:"-"`
+	// 增改主机时提交的分组与标签，未提交（null）时保持不变，提交空值时清除；查询时由 Groups、LabelRefs 填充
+	GroupIDs []uint            `gorm:"-" json:"groupIds"`
+	Labels   map[string]string `gorm:"-" json:"labels"`
+	Facts    *HostFacts        `gorm:"-" json:"facts,omitempty"` // 最新的主机信息快照，仅在主机列表中返回
+	// 主机密钥，authorized_keys 格式，由首次连接、known_hosts 导入或人工接受写入
+	HostKey            string     `gorm:"type:text" json:"hostKey"`
+	HostKeyFingerprint string     `gorm:"size:100" json:"hostKeyFingerprint"`
+	HostKeyVerifiedAt  *time.Time `json:"hostKeyVerifiedAt"`
+	// 最近一次连接时服务器提供的、与记录不一致（或 strict 模式下未登记）的主机密钥，等待人工确认
+	PendingHostKey            string    `gorm:"type:text" json:"pendingHostKey"`
+	PendingHostKeyFingerprint string    `gorm:"size:100" json:"pendingHostKeyFingerprint"`
+	CreatedTime               time.Time `gorm:"autoCreateTime" json:"createdTime"`
+	UpdatedTime               time.Time `gorm:"autoUpdateTime" json:"updatedTime"`
 }
 
 // TableName 指定表名
@@ -23,47 +42,240 @@ func (Host) TableName() string {
 	return "hosts"
 }
 
-// CreateHost 创建主机
+// hostReadOnlyFields 不允许通过主机增改接口写入的字段，主机密钥只能经由专门的接口维护
+var hostReadOnlyFields = []string{
+	"Credential", "Groups", "LabelRefs", "HostKey", "HostKeyFingerprint", "HostKeyVerifiedAt",
+	"PendingHostKey", "PendingHostKeyFingerprint",
+}
+
+// CreateHost 创建主机及其分组与标签
 func CreateHost(db *gorm.DB, host *Host) error {
-	return db.Create(host).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(hostReadOnlyFields...).Create(host).Error; err != nil {
+			return err
+		}
+		return setHostGroupsAndLabels(tx, host.ID, host)
+	})
 }
 
 // GetHostList 获取主机列表
-func GetHostList(db *gorm.DB, page, pageSize int, name, ip string) ([]Host, int64, error) {
+func GetHostList(db *gorm.DB, page, pageSize int, filter HostFilter) ([]Host, int64, error) {
 	var hosts []Host
 	var total int64
 
-	query := db.Model(&Host{})
-
-	if name != "" {
-		query = query.Where("name LIKE ?", "%"+name+"%")
-	}
-	if ip != "" {
-		query = query.Where("ip LIKE ?", "%"+ip+"%")
+	query, err := filter.apply(db, db.Model(&Host{}))
+	if err != nil {
+		return nil, 0, err
 	}
 
-	err := query.Count(&total).Error
+	err = query.Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = query.Offset((page - 1) * pageSize).Limit(pageSize).Find(&hosts).Error
+	err = preloadHost(query).Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&hosts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	fillHostLabels(hosts)
+	err = fillLatestHostFacts(db, hosts)
 	return hosts, total, err
 }
 
-// UpdateHost 更新主机信息
+// HostFilter 选择主机的条件，各条件之间为且的关系
+type HostFilter struct {
+	IDs         []uint `json:"hostIds"`
+	Name        string `json:"name"`        // 名称模糊匹配
+	IP          string `json:"ip"`          // 地址模糊匹配
+	Environment string `json:"environment"` // 所属环境
+	GroupIDs    []uint `json:"groupIds"`    // 属于其中任一分组或其下级分组
+	Selector    string `json:"selector"`    // 标签选择器，如 env=prod,role=web
+}
+
+// Empty 是否未指定任何条件
+func (f HostFilter) Empty() bool {
+	return len(f.IDs) == 0 && f.Name == "" && f.IP == "" && f.Environment == "" &&
+		len(f.GroupIDs) == 0 && strings.TrimSpace(f.Selector) == ""
+}
+
+// apply 为主机查询追加筛选条件，标签选择器不合法时返回错误
+func (f HostFilter) apply(db, query *gorm.DB) (*gorm.DB, error) {
+	requirements, err := ParseLabelSelector(f.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(f.IDs) > 0 {
+		query = query.Where("hosts.id IN ?", f.IDs)
+	}
+	if f.Name != "" {
+		query = query.Where("hosts.name LIKE ?", "%"+f.Name+"%")
+	}
+	if f.IP != "" {
+		query = query.Where("hosts.ip LIKE ?", "%"+f.IP+"%")
+	}
+	if f.Environment != "" {
+		query = query.Where("hosts.environment = ?", f.Environment)
+	}
+	if len(f.GroupIDs) > 0 {
+		groupIDs, err := GetHostGroupDescendants(db, f.GroupIDs)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("hosts.id IN (?)", db.Table("host_group_hosts").Select("host_id").Where("host_group_id IN ?", groupIDs))
+	}
+	return applyLabelSelector(db, query, requirements), nil
+}
+
+// FindHosts 按条件查询主机，按ID排序
+func FindHosts(db *gorm.DB, filter HostFilter) ([]Host, error) {
+	var hosts []Host
+
+	query, err := filter.apply(db, db.Model(&Host{}))
+	if err != nil {
+		return nil, err
+	}
+
+	err = preloadHost(query).Order("id").Find(&hosts).Error
+	fillHostLabels(hosts)
+	return hosts, err
+}
+
+// UpdateHost 更新主机信息，credential_id 为空时改回密码登录，跳板机为空时改为直连，环境为空时清除环境。
+// 分组与标签仅在提交时替换
 func UpdateHost(db *gorm.DB, id uint, host *Host) error {
-	return db.Model(&Host{}).Where("id = ?", id).Updates(host).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Host{}).Where("id = ?", id).Omit(hostReadOnlyFields...).Updates(host).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Host{}).Where("id = ?", id).Select("CredentialID", "JumpHostIDs", "Environment").Updates(host).Error; err != nil {
+			return err
+		}
+		return setHostGroupsAndLabels(tx, id, host)
+	})
+}
+
+// setHostGroupsAndLabels 按提交的 GroupIDs、Labels 替换主机的分组与标签，为 nil 时保持不变
+func setHostGroupsAndLabels(tx *gorm.DB, id uint, host *Host) error {
+	if host.GroupIDs != nil {
+		groups := []HostGroup{}
+		if len(host.GroupIDs) > 0 {
+			if err := tx.Where("id IN ?", host.GroupIDs).Find(&groups).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&Host{ID: id}).Omit("Groups.*").Association("Groups").Replace(groups); err != nil {
+			return err
+		}
+	}
+	if host.Labels != nil {
+		return setHostLabels(tx, id, host.Labels)
+	}
+	return nil
 }
 
-// DeleteHost 删除主机
+// DeleteHost 删除主机及其分组与标签关联、信息快照
 func DeleteHost(db *gorm.DB, id uint) error {
-	return db.Delete(&Host{}, id).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("host_id = ?", id).Delete(&HostFacts{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("host_id = ?", id).Delete(&SftpUpload{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM host_group_hosts WHERE host_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := setHostLabels(tx, id, nil); err != nil {
+			return err
+		}
+		return tx.Delete(&Host{}, id).Error
+	})
 }
 
 // GetHostByID 根据ID获取主机
 func GetHostByID(db *gorm.DB, id uint) (*Host, error) {
-	var host Host
-	err := db.First(&host, id).Error
-	return &host, err
+	hosts := make([]Host, 1)
+	err := preloadHost(db).First(&hosts[0], id).Error
+	fillHostLabels(hosts)
+	return &hosts[0], err
+}
+
+// preloadHost 预加载主机的凭据、分组与标签
+func preloadHost(query *gorm.DB) *gorm.DB {
+	return query.Preload("Credential").
+		Preload("Groups", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("LabelRefs")
+}
+
+// fillHostLabels 根据预加载的关联填充 GroupIDs 与 Labels
+func fillHostLabels(hosts []Host) {
+	for i := range hosts {
+		host := &hosts[i]
+		host.GroupIDs = make([]uint, 0, len(host.Groups))
+		for _, group := range host.Groups {
+			host.GroupIDs = append(host.GroupIDs, group.ID)
+		}
+		host.Labels = make(map[string]string, len(host.LabelRefs))
+		for _, label := range host.LabelRefs {
+			host.Labels[label.Name] = label.Value
+		}
+	}
+}
+
+// SaveHostKey 记录已信任的主机密钥并清除待确认密钥
+func SaveHostKey(db *gorm.DB, id uint, hostKey, fingerprint string) error {
+	now := time.Now()
+	return db.Model(&Host{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"host_key":                     hostKey,
+		"host_key_fingerprint":         fingerprint,
+		"host_key_verified_at":         &now,
+		"pending_host_key":             "",
+		"pending_host_key_fingerprint": "",
+	}).Error
+}
+
+// SavePendingHostKey 记录等待人工确认的主机密钥
+func SavePendingHostKey(db *gorm.DB, id uint, hostKey, fingerprint string) error {
+	return db.Model(&Host{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"pending_host_key":             hostKey,
+		"pending_host_key_fingerprint": fingerprint,
+	}).Error
+}
+
+// ResetHostKey 清除主机密钥，下次连接时重新记录
+func ResetHostKey(db *gorm.DB, id uint) error {
+	return db.Model(&Host{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"host_key":                     "",
+		"host_key_fingerprint":         "",
+		"host_key_verified_at":         nil,
+		"pending_host_key":             "",
+		"pending_host_key_fingerprint": "",
+	}).Error
+}
+
+// GetAllHosts 获取全部主机
+func GetAllHosts(db *gorm.DB) ([]Host, error) {
+	var hosts []Host
+	err := db.Order("id").Find(&hosts).Error
+	return hosts, err
+}
+
+// GetHostsUsingJumpHost 获取跳板机链中包含指定主机的主机
+func GetHostsUsingJumpHost(db *gorm.DB, id uint) ([]Host, error) {
+	hosts, err := GetAllHosts(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Host
+	for _, host := range hosts {
+		for _, jumpID := range host.JumpHostIDs {
+			if jumpID == id {
+				result = append(result, host)
+				break
+			}
+		}
+	}
+	return result, nil
 }
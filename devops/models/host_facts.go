@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HostFactsHistory 每台主机保留的信息快照数
+const HostFactsHistory = 20
+
+// DiskFact 磁盘挂载点容量，单位字节
+type DiskFact struct {
+	Device     string `json:"device"`
+	MountPoint string `json:"mountPoint"`
+	Total      int64  `json:"total"`
+	Used       int64  `json:"used"`
+	Available  int64  `json:"available"`
+}
+
+// InterfaceFact 网络接口
+type InterfaceFact struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac"`
+	Addresses []string `json:"addresses"` // CIDR 格式，如 10.0.0.2/24
+}
+
+// HostFacts 通过 SSH 采集的主机信息快照，每次采集生成一个新版本
+type HostFacts struct {
+	ID            uint            `gorm:"primarykey" json:"id"`
+	HostID        uint            `gorm:"not null;uniqueIndex:idx_host_facts_host_version" json:"hostId"`
+	Version       int             `gorm:"not null;uniqueIndex:idx_host_facts_host_version" json:"version"`
+	Hostname      string          `gorm:"size:255" json:"hostname"`
+	OS            string          `gorm:"size:50" json:"os"`        // os-release 中的 ID，如 ubuntu、centos
+	OSName        string          `gorm:"size:255" json:"osName"`   // os-release 中的 PRETTY_NAME
+	OSVersion     string          `gorm:"size:50" json:"osVersion"` // os-release 中的 VERSION_ID
+	Kernel        string          `gorm:"size:100" json:"kernel"`   // uname -r
+	Arch          string          `gorm:"size:50" json:"arch"`      // uname -m
+	CPUCount      int             `json:"cpuCount"`
+	MemoryTotal   int64           `json:"memoryTotal"` // 字节
+	Disks         []DiskFact      `gorm:"type:text;serializer:json" json:"disks"`
+	Interfaces    []InterfaceFact `gorm:"type:text;serializer:json" json:"interfaces"`
+	Uptime        int64           `json:"uptime"`                       // 采集时已运行的秒数
+	DockerVersion string          `gorm:"size:50" json:"dockerVersion"` // 未安装 Docker 时为空
+	CollectedAt   time.Time       `gorm:"not null" json:"collectedAt"`
+}
+
+// TableName 指定表名
+func (HostFacts) TableName() string {
+	return "host_facts"
+}
+
+// CreateHostFacts 保存主机信息快照，版本号在该主机已有快照的基础上递增，并清理超出保留数量的旧快照
+func CreateHostFacts(db *gorm.DB, facts *HostFacts) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&HostFacts{}).Where("host_id = ?", facts.HostID).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		facts.ID = 0
+		facts.Version = latest + 1
+		if err := tx.Create(facts).Error; err != nil {
+			return err
+		}
+		return tx.Where("host_id = ? AND version <= ?", facts.HostID, facts.Version-HostFactsHistory).
+			Delete(&HostFacts{}).Error
+	})
+}
+
+// GetLatestHostFacts 获取主机最新的信息快照
+func GetLatestHostFacts(db *gorm.DB, hostID uint) (*HostFacts, error) {
+	var facts HostFacts
+	err := db.Where("host_id = ?", hostID).Order("version DESC").First(&facts).Error
+	return &facts, err
+}
+
+// GetHostFactsHistory 获取主机的信息快照，按版本倒序
+func GetHostFactsHistory(db *gorm.DB, hostID uint) ([]HostFacts, error) {
+	var facts []HostFacts
+	err := db.Where("host_id = ?", hostID).Order("version DESC").Find(&facts).Error
+	return facts, err
+}
+
+// fillLatestHostFacts 为主机填充最新的信息快照
+func fillLatestHostFacts(db *gorm.DB, hosts []Host) error {
+	if len(hosts) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(hosts))
+	for _, host := range hosts {
+		ids = append(ids, host.ID)
+	}
+
+	var facts []HostFacts
+	latest := db.Model(&HostFacts{}).Select("MAX(id)").Where("host_id IN ?", ids).Group("host_id")
+	if err := db.Where("id IN (?)", latest).Find(&facts).Error; err != nil {
+		return err
+	}
+
+	byHost := make(map[uint]*HostFacts, len(facts))
+	for i := range facts {
+		byHost[facts[i].HostID] = &facts[i]
+	}
+	for i := range hosts {
+		hosts[i].Facts = byHost[hosts[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HostGroup 主机分组，可通过 ParentID 组成层级，例如 prod/us-east/web。主机与分组多对多关联
+type HostGroup struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	ParentID    *uint     `gorm:"index" json:"parentId"` // 为空时是顶层分组
+	Description string    `gorm:"size:500" json:"description"`
+	Path        string    `gorm:"-" json:"path,omitempty"`      // 从顶层分组到本分组的名称路径，以 / 分隔
+	HostCount   int64     `gorm:"-" json:"hostCount,omitempty"` // 直接属于本分组的主机数
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (HostGroup) TableName() string {
+	return "host_groups"
+}
+
+// CreateHostGroup 创建主机分组
+func CreateHostGroup(db *gorm.DB, group *HostGroup) error {
+	return db.Create(group).Error
+}
+
+// GetHostGroup 获取主机分组
+func GetHostGroup(db *gorm.DB, id uint) (*HostGroup, error) {
+	var group HostGroup
+	err := db.First(&group, id).Error
+	return &group, err
+}
+
+// GetHostGroupList 获取全部主机分组，填充路径与主机数并按路径排序
+func GetHostGroupList(db *gorm.DB) ([]HostGroup, error) {
+	var groups []HostGroup
+	if err := db.Order("id").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		HostGroupID uint
+		Count       int64
+	}
+	if err := db.Table("host_group_hosts").Select("host_group_id, COUNT(*) AS count").
+		Group("host_group_id").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	countByGroup := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		countByGroup[c.HostGroupID] = c.Count
+	}
+
+	paths := hostGroupPaths(groups)
+	for i := range groups {
+		groups[i].Path = paths[groups[i].ID]
+		groups[i].HostCount = countByGroup[groups[i].ID]
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Path < groups[j].Path })
+	return groups, nil
+}
+
+// hostGroupPaths 计算各分组的名称路径
+func hostGroupPaths(groups []HostGroup) map[uint]string {
+	byID := make(map[uint]*HostGroup, len(groups))
+	for i := range groups {
+		byID[groups[i].ID] = &groups[i]
+	}
+
+	paths := make(map[uint]string, len(groups))
+	for _, group := range groups {
+		names := []string{group.Name}
+		// 层级在写入时已保证无环，深度上限只用于防御异常数据
+		for parent := group.ParentID; parent != nil && len(names) <= len(groups); {
+			p, ok := byID[*parent]
+			if !ok {
+				break
+			}
+			names = append(names, p.Name)
+			parent = p.ParentID
+		}
+		for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+			names[i], names[j] = names[j], names[i]
+		}
+		paths[group.ID] = strings.Join(names, "/")
+	}
+	return paths
+}
+
+// GetHostGroupDescendants 返回指定分组及其全部下级分组的ID
+func GetHostGroupDescendants(db *gorm.DB, ids []uint) ([]uint, error) {
+	var groups []HostGroup
+	if err := db.Select("id", "parent_id").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint][]uint)
+	for _, group := range groups {
+		if group.ParentID != nil {
+			children[*group.ParentID] = append(children[*group.ParentID], group.ID)
+		}
+	}
+
+	seen := make(map[uint]bool)
+	var result []uint
+	queue := append([]uint(nil), ids...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+		queue = append(queue, children[id]...)
+	}
+	return result, nil
+}
+
+// GetHostGroupAncestors 返回指定分组及其全部上级分组的ID，用于判断主机是否属于某分组或其下级分组
+func GetHostGroupAncestors(db *gorm.DB, ids []uint) ([]uint, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var groups []HostGroup
+	if err := db.Select("id", "parent_id").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	parents := make(map[uint]uint)
+	for _, group := range groups {
+		if group.ParentID != nil {
+			parents[group.ID] = *group.ParentID
+		}
+	}
+
+	seen := make(map[uint]bool)
+	var result []uint
+	for _, id := range ids {
+		// 层级在写入时已保证无环，seen 同时用于防御异常数据
+		for !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+			parent, ok := parents[id]
+			if !ok {
+				break
+			}
+			id = parent
+		}
+	}
+	return result, nil
+}
+
+// HostGroupNameExists 判断同一上级分组下是否已有同名分组，excludeID 为更新时的分组自身
+func HostGroupNameExists(db *gorm.DB, parentID *uint, name string, excludeID uint) (bool, error) {
+	var count int64
+	query := db.Model(&HostGroup{}).Where("name = ? AND id <> ?", name, excludeID)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+// CountHostGroupChildren 获取下级分组数量
+func CountHostGroupChildren(db *gorm.DB, id uint) (int64, error) {
+	var count int64
+	err := db.Model(&HostGroup{}).Where("parent_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// UpdateHostGroup 更新主机分组，上级分组可更新为空
+func UpdateHostGroup(db *gorm.DB, id uint, group *HostGroup) error {
+	return db.Model(&HostGroup{}).Where("id = ?", id).
+		Select("Name", "ParentID", "Description").
+		Updates(group).Error
+}
+
+// DeleteHostGroup 删除主机分组及其与主机的关联，不删除主机
+func DeleteHostGroup(db *gorm.DB, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM host_group_hosts WHERE host_group_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&HostGroup{}, id).Error
+	})
+}
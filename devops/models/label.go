@@ -0,0 +1,202 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Label 主机标签，同一 name=value 只保存一条，通过 host_labels 与主机多对多关联
+type Label struct {
+	ID    uint   `gorm:"primarykey" json:"id"`
+	Name  string `gorm:"size:63;not null;uniqueIndex:idx_labels_name_value" json:"name"`
+	Value string `gorm:"size:63;not null;uniqueIndex:idx_labels_name_value" json:"value"`
+}
+
+// TableName 指定表名
+func (Label) TableName() string {
+	return "labels"
+}
+
+// 标签键以字母或数字开头和结尾，中间可包含 . _ / -；值的规则相同但可以为空
+var (
+	labelKeyPattern   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+// ValidateLabels 校验主机标签的键和值
+func ValidateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("标签键 %q 不合法：最长 63 个字符，以字母或数字开头和结尾，中间可包含 . _ / -", key)
+		}
+		if !labelValuePattern.MatchString(value) {
+			return fmt.Errorf("标签 %s 的值 %q 不合法：最长 63 个字符，以字母或数字开头和结尾，中间可包含 . _ / -", key, value)
+		}
+	}
+	return nil
+}
+
+// LabelKey 标签键及其在主机上出现过的值
+type LabelKey struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// GetLabelKeys 获取主机正在使用的全部标签键和值，按名称排序
+func GetLabelKeys(db *gorm.DB) ([]LabelKey, error) {
+	var labels []Label
+	if err := db.Where("id IN (?)", db.Table("host_labels").Select("label_id")).
+		Order("name").Order("value").Find(&labels).Error; err != nil {
+		return nil, err
+	}
+
+	keys := []LabelKey{}
+	for _, label := range labels {
+		if len(keys) == 0 || keys[len(keys)-1].Name != label.Name {
+			keys = append(keys, LabelKey{Name: label.Name})
+		}
+		keys[len(keys)-1].Values = append(keys[len(keys)-1].Values, label.Value)
+	}
+	return keys, nil
+}
+
+// setHostLabels 将主机标签替换为 labels，并清理不再被任何主机使用的标签
+func setHostLabels(tx *gorm.DB, hostID uint, labels map[string]string) error {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	refs := make([]Label, 0, len(labels))
+	for _, name := range names {
+		label := Label{Name: name, Value: labels[name]}
+		if err := tx.Where(&label, "Name", "Value").FirstOrCreate(&label).Error; err != nil {
+			return err
+		}
+		refs = append(refs, label)
+	}
+
+	if err := tx.Model(&Host{ID: hostID}).Omit("LabelRefs.*").Association("LabelRefs").Replace(refs); err != nil {
+		return err
+	}
+	return tx.Where("id NOT IN (?)", tx.Table("host_labels").Select("label_id")).Delete(&Label{}).Error
+}
+
+// 标签选择器运算符
+const (
+	SelectorEquals       = "="
+	SelectorNotEquals    = "!="
+	SelectorIn           = "in"
+	SelectorNotIn        = "notin"
+	SelectorExists       = "exists"
+	SelectorDoesNotExist = "!"
+)
+
+// LabelRequirement 标签选择器中的一个条件
+type LabelRequirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+// ParseLabelSelector 解析标签选择器，多个条件以逗号分隔且需同时满足。支持的写法：
+// env=prod、env==prod、env!=prod、env in (prod,staging)、env notin (dev)、env（存在该标签）、!env（不存在该标签）。
+// 与 Kubernetes 一致，!= 与 notin 也会匹配没有该标签的主机
+func ParseLabelSelector(selector string) ([]LabelRequirement, error) {
+	var requirements []LabelRequirement
+	for _, term := range splitSelector(selector) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		requirement, err := parseLabelRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
+
+// splitSelector 按不在括号内的逗号切分选择器
+func splitSelector(selector string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range selector {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, selector[start:])
+}
+
+// parseLabelRequirement 解析单个条件
+func parseLabelRequirement(term string) (LabelRequirement, error) {
+	var r LabelRequirement
+	switch {
+	case strings.HasPrefix(term, "!"):
+		r = LabelRequirement{Key: strings.TrimSpace(term[1:]), Operator: SelectorDoesNotExist}
+	case strings.Contains(term, "!="):
+		key, value, _ := strings.Cut(term, "!=")
+		r = LabelRequirement{Key: strings.TrimSpace(key), Operator: SelectorNotEquals, Values: []string{strings.TrimSpace(value)}}
+	case strings.Contains(term, "="):
+		key, value, _ := strings.Cut(term, "=")
+		value = strings.TrimPrefix(value, "=")
+		r = LabelRequirement{Key: strings.TrimSpace(key), Operator: SelectorEquals, Values: []string{strings.TrimSpace(value)}}
+	case strings.Contains(term, "("):
+		fields := strings.Fields(term[:strings.Index(term, "(")])
+		list := strings.TrimSpace(term[strings.Index(term, "("):])
+		if len(fields) != 2 || (fields[1] != SelectorIn && fields[1] != SelectorNotIn) || !strings.HasSuffix(list, ")") {
+			return r, fmt.Errorf("无法解析标签条件 %q", term)
+		}
+		r = LabelRequirement{Key: fields[0], Operator: fields[1]}
+		for _, value := range strings.Split(list[1:len(list)-1], ",") {
+			r.Values = append(r.Values, strings.TrimSpace(value))
+		}
+	default:
+		r = LabelRequirement{Key: term, Operator: SelectorExists}
+	}
+
+	if !labelKeyPattern.MatchString(r.Key) {
+		return r, fmt.Errorf("标签条件 %q 中的键不合法", term)
+	}
+	for _, value := range r.Values {
+		if !labelValuePattern.MatchString(value) {
+			return r, fmt.Errorf("标签条件 %q 中的值 %q 不合法", term, value)
+		}
+	}
+	return r, nil
+}
+
+// applyLabelSelector 为主机查询追加标签选择器条件
+func applyLabelSelector(db, query *gorm.DB, requirements []LabelRequirement) *gorm.DB {
+	for _, r := range requirements {
+		matched := db.Table("host_labels").Select("host_labels.host_id").
+			Joins("JOIN labels ON labels.id = host_labels.label_id").
+			Where("labels.name = ?", r.Key)
+		switch r.Operator {
+		case SelectorEquals, SelectorIn:
+			query = query.Where("hosts.id IN (?)", matched.Where("labels.value IN ?", r.Values))
+		case SelectorNotEquals, SelectorNotIn:
+			query = query.Where("hosts.id NOT IN (?)", matched.Where("labels.value IN ?", r.Values))
+		case SelectorExists:
+			query = query.Where("hosts.id IN (?)", matched)
+		case SelectorDoesNotExist:
+			query = query.Where("hosts.id NOT IN (?)", matched)
+		}
+	}
+	return query
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     []LabelRequirement
+		wantErr  bool
+	}{
+		{name: "空选择器", selector: "  ", want: nil},
+		{name: "等于", selector: "env=prod", want: []LabelRequirement{{Key: "env", Operator: SelectorEquals, Values: []string{"prod"}}}},
+		{name: "双等号", selector: "env==prod", want: []LabelRequirement{{Key: "env", Operator: SelectorEquals, Values: []string{"prod"}}}},
+		{name: "不等于", selector: "env != prod", want: []LabelRequirement{{Key: "env", Operator: SelectorNotEquals, Values: []string{"prod"}}}},
+		{name: "值为空", selector: "env=", want: []LabelRequirement{{Key: "env", Operator: SelectorEquals, Values: []string{""}}}},
+		{name: "in", selector: "env in (prod, staging)", want: []LabelRequirement{{Key: "env", Operator: SelectorIn, Values: []string{"prod", "staging"}}}},
+		{name: "notin", selector: "env notin (dev)", want: []LabelRequirement{{Key: "env", Operator: SelectorNotIn, Values: []string{"dev"}}}},
+		{name: "存在", selector: "app.kubernetes.io/name", want: []LabelRequirement{{Key: "app.kubernetes.io/name", Operator: SelectorExists}}},
+		{name: "不存在", selector: "!env", want: []LabelRequirement{{Key: "env", Operator: SelectorDoesNotExist}}},
+		{
+			name:     "多个条件，括号内的逗号不切分",
+			selector: "env in (prod,staging), role=web,,!canary",
+			want: []LabelRequirement{
+				{Key: "env", Operator: SelectorIn, Values: []string{"prod", "staging"}},
+				{Key: "role", Operator: SelectorEquals, Values: []string{"web"}},
+				{Key: "canary", Operator: SelectorDoesNotExist},
+			},
+		},
+		{name: "未知运算符", selector: "env like (prod)", wantErr: true},
+		{name: "缺少右括号", selector: "env in (prod", wantErr: true},
+		{name: "in 缺少括号", selector: "env in prod", wantErr: true},
+		{name: "键不合法", selector: "-env=prod", wantErr: true},
+		{name: "值不合法", selector: "env=pr od", wantErr: true},
+		{name: "列表中的值不合法", selector: "env in (prod,st*ging)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabelSelector(tt.selector)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseLabelSelector(%q) = %+v，期望返回错误", tt.selector, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLabelSelector(%q) 返回错误: %v", tt.selector, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLabelSelector(%q) = %+v，期望 %+v", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
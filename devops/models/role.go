@@ -0,0 +1,110 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Role 角色模型，Permissions 为 "资源:操作" 形式的权限列表，支持 * 通配
+type Role struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:50;not null;uniqueIndex" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	Permissions []string  `gorm:"type:text;serializer:json" json:"permissions"`
+	BuiltIn     bool      `gorm:"not null;default:false" json:"builtIn"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (Role) TableName() string {
+	return "roles"
+}
+
+// RoleBinding 角色绑定，ResourceType 为空表示所有资源类型，ResourceID 为 0 表示该类型下的全部资源
+type RoleBinding struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"userId"`
+	User         *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	RoleID       uint      `gorm:"not null;index" json:"roleId"`
+	Role         *Role     `gorm:"foreignKey:RoleID" json:"role,omitempty"`
+	ResourceType string    `gorm:"size:50" json:"resourceType"`
+	ResourceID   uint      `gorm:"not null;default:0" json:"resourceId"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TableName 指定表名
+func (RoleBinding) TableName() string {
+	return "role_bindings"
+}
+
+// CreateRole 创建角色
+func CreateRole(db *gorm.DB, role *Role) error {
+	return db.Create(role).Error
+}
+
+// GetRoleByID 根据ID获取角色
+func GetRoleByID(db *gorm.DB, id uint) (*Role, error) {
+	var role Role
+	err := db.First(&role, id).Error
+	return &role, err
+}
+
+// GetRoleByName 根据名称获取角色
+func GetRoleByName(db *gorm.DB, name string) (*Role, error) {
+	var role Role
+	err := db.Where("name = ?", name).First(&role).Error
+	return &role, err
+}
+
+// GetRoleList 获取角色列表
+func GetRoleList(db *gorm.DB) ([]Role, error) {
+	var roles []Role
+	err := db.Order("id").Find(&roles).Error
+	return roles, err
+}
+
+// UpdateRole 更新角色
+func UpdateRole(db *gorm.DB, id uint, role *Role) error {
+	return db.Model(&Role{}).Where("id = ?", id).
+		Select("description", "permissions").Updates(role).Error
+}
+
+// DeleteRole 删除角色及其绑定
+func DeleteRole(db *gorm.DB, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", id).Delete(&RoleBinding{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Role{}, id).Error
+	})
+}
+
+// CreateRoleBinding 创建角色绑定
+func CreateRoleBinding(db *gorm.DB, binding *RoleBinding) error {
+	return db.Create(binding).Error
+}
+
+// GetRoleBindingList 获取角色绑定列表，userID 为 0 时返回全部
+func GetRoleBindingList(db *gorm.DB, userID uint) ([]RoleBinding, error) {
+	var bindings []RoleBinding
+	query := db.Preload("Role").Preload("User")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	err := query.Order("id").Find(&bindings).Error
+	return bindings, err
+}
+
+// GetUserRoleBindings 获取用户的角色绑定（包含角色信息）
+func GetUserRoleBindings(db *gorm.DB, userID uint) ([]RoleBinding, error) {
+	var bindings []RoleBinding
+	err := db.Preload("Role").Where("user_id = ?", userID).Find(&bindings).Error
+	return bindings, err
+}
+
+// DeleteRoleBinding 删除角色绑定
+func DeleteRoleBinding(db *gorm.DB, id uint) error {
+	return db.Delete(&RoleBinding{}, id).Error
+}
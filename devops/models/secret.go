@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+
+	_ "devops/secrets" // 注册 encrypted 序列化器
+)
+
+// Secret 敏感字段：数据库中加密保存 (配合 `gorm:"serializer:encrypted"`)，
+// 接口只写不读，序列化为 JSON 时始终输出空字符串
+type Secret string
+
+// MarshalJSON 不向接口返回明文
+func (Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`""`), nil
+}
+
+// UnmarshalJSON 接收明文
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*s = Secret(value)
+	return nil
+}
+
+// String 返回明文
+func (s Secret) String() string {
+	return string(s)
+}
+
+// IsSet 是否已设置
+func (s Secret) IsSet() bool {
+	return s != ""
+}
+
+// SecretModels 返回包含加密字段的模型，用于密钥轮换
+func SecretModels() []interface{} {
+	return []interface{}{&Host{}, &Repository{}, &DockerRegistry{}, &Credential{}}
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ByteRange 已上传的字节区间 [Start, End)
+type ByteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// SftpUpload 分片上传到主机的文件。分片写入与最终文件同目录的临时文件，全部写入并校验通过后重命名为最终文件
+type SftpUpload struct {
+	ID        uint        `gorm:"primarykey" json:"id"`
+	HostID    uint        `gorm:"not null;index" json:"hostId"`
+	UserID    uint        `gorm:"not null;index" json:"userId"`
+	Path      string      `gorm:"size:1024;not null" json:"path"`     // 最终文件路径
+	TempPath  string      `gorm:"size:1024;not null" json:"tempPath"` // 上传中的临时文件路径
+	Size      int64       `gorm:"not null" json:"size"`
+	SHA256    string      `gorm:"size:64" json:"sha256"` // 期望的 SHA-256（十六进制小写），可在完成时再提供
+	Received  []ByteRange `gorm:"type:text;serializer:json" json:"received"`
+	Uploaded  int64       `gorm:"not null" json:"uploaded"` // 已上传的字节数
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (SftpUpload) TableName() string {
+	return "sftp_uploads"
+}
+
+// AddRange 记录已写入的区间，与已有区间合并
+func (u *SftpUpload) AddRange(start, end int64) {
+	if end <= start {
+		return
+	}
+	ranges := append(u.Received, ByteRange{Start: start, End: end})
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+
+	merged := []ByteRange{}
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.Start <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	u.Received = merged
+	u.Uploaded = 0
+	for _, r := range merged {
+		u.Uploaded += r.End - r.Start
+	}
+}
+
+// Missing 尚未上传的区间
+func (u *SftpUpload) Missing() []ByteRange {
+	missing := []ByteRange{}
+	var next int64
+	for _, r := range u.Received {
+		if r.Start > next {
+			missing = append(missing, ByteRange{Start: next, End: r.Start})
+		}
+		next = max(next, r.End)
+	}
+	if next < u.Size {
+		missing = append(missing, ByteRange{Start: next, End: u.Size})
+	}
+	return missing
+}
+
+// ResetRanges 清空已上传的区间，需重新上传全部内容
+func (u *SftpUpload) ResetRanges() {
+	u.Received = []ByteRange{}
+	u.Uploaded = 0
+}
+
+// CreateSftpUpload 创建分片上传记录
+func CreateSftpUpload(db *gorm.DB, upload *SftpUpload) error {
+	return db.Create(upload).Error
+}
+
+// GetSftpUpload 获取主机上的分片上传记录
+func GetSftpUpload(db *gorm.DB, hostID, id uint) (*SftpUpload, error) {
+	var upload SftpUpload
+	err := db.Where("host_id = ?", hostID).First(&upload, id).Error
+	return &upload, err
+}
+
+// FindSftpUpload 查找用户上传到同一路径、大小与校验值相同的未完成上传，用于续传
+func FindSftpUpload(db *gorm.DB, hostID, userID uint, path string, size int64, sha256 string) (*SftpUpload, error) {
+	var upload SftpUpload
+	err := db.Where("host_id = ? AND user_id = ? AND path = ? AND size = ? AND sha256 = ?", hostID, userID, path, size, sha256).
+		Order("id DESC").First(&upload).Error
+	return &upload, err
+}
+
+// SaveSftpUploadProgress 保存已上传的区间与校验值
+func SaveSftpUploadProgress(db *gorm.DB, upload *SftpUpload) error {
+	return db.Model(upload).Select("Received", "Uploaded", "SHA256", "UpdatedAt").Updates(upload).Error
+}
+
+// DeleteSftpUpload 删除分片上传记录
+func DeleteSftpUpload(db *gorm.DB, id uint) error {
+	return db.Delete(&SftpUpload{}, id).Error
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSftpUploadRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int64
+		add      []ByteRange
+		received []ByteRange
+		uploaded int64
+		missing  []ByteRange
+	}{
+		{name: "未上传", size: 100, received: nil, missing: []ByteRange{{0, 100}}},
+		{name: "空文件", size: 0, received: nil, missing: []ByteRange{}},
+		{name: "空区间被忽略", size: 100, add: []ByteRange{{10, 10}, {20, 5}}, received: nil, missing: []ByteRange{{0, 100}}},
+		{name: "全部上传", size: 100, add: []ByteRange{{0, 100}}, received: []ByteRange{{0, 100}}, uploaded: 100, missing: []ByteRange{}},
+		{
+			name: "乱序且不相邻", size: 100, add: []ByteRange{{60, 80}, {0, 20}},
+			received: []ByteRange{{0, 20}, {60, 80}}, uploaded: 40, missing: []ByteRange{{20, 60}, {80, 100}},
+		},
+		{
+			name: "相邻区间合并", size: 100, add: []ByteRange{{0, 50}, {50, 100}},
+			received: []ByteRange{{0, 100}}, uploaded: 100, missing: []ByteRange{},
+		},
+		{
+			name: "重叠与重复上传", size: 100, add: []ByteRange{{10, 40}, {30, 60}, {10, 40}, {20, 30}},
+			received: []ByteRange{{10, 60}}, uploaded: 50, missing: []ByteRange{{0, 10}, {60, 100}},
+		},
+		{
+			name: "填补中间的空洞", size: 100, add: []ByteRange{{0, 30}, {70, 100}, {30, 70}},
+			received: []ByteRange{{0, 100}}, uploaded: 100, missing: []ByteRange{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upload := &SftpUpload{Size: tt.size}
+			for _, r := range tt.add {
+				upload.AddRange(r.Start, r.End)
+			}
+			if !reflect.DeepEqual(upload.Received, tt.received) {
+				t.Errorf("Received = %v，期望 %v", upload.Received, tt.received)
+			}
+			if upload.Uploaded != tt.uploaded {
+				t.Errorf("Uploaded = %d，期望 %d", upload.Uploaded, tt.uploaded)
+			}
+			if got := upload.Missing(); !reflect.DeepEqual(got, tt.missing) {
+				t.Errorf("Missing() = %v，期望 %v", got, tt.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 终端会话状态
+const (
+	ShellSessionActive = "active"
+	ShellSessionClosed = "closed"
+)
+
+// ShellSession WebShell 会话记录，终端输出以 asciicast v2 格式保存在 RecordingPath
+type ShellSession struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	UserID        uint       `gorm:"not null;index" json:"userId"`
+	Username      string     `gorm:"size:50;not null" json:"username"`
+	HostID        uint       `gorm:"not null;index" json:"hostId"`
+	HostName      string     `gorm:"size:100;not null" json:"hostName"` // 主机名称快照，主机删除后仍可查询
+	HostAddr      string     `gorm:"size:255;not null" json:"hostAddr"`
+	ClientIP      string     `gorm:"size:64" json:"clientIp"`
+	Status        string     `gorm:"size:20;not null;index" json:"status"`
+	StartedAt     time.Time  `gorm:"not null;index" json:"startedAt"`
+	EndedAt       *time.Time `json:"endedAt"`
+	ExitStatus    *int       `json:"exitStatus"` // 连接中断等未取得退出码时为空
+	RecordingPath string     `gorm:"size:500" json:"-"`
+	RecordingSize int64      `json:"recordingSize"`
+}
+
+// TableName 指定表名
+func (ShellSession) TableName() string {
+	return "shell_sessions"
+}
+
+// ShellSessionQuery 会话查询条件
+type ShellSessionQuery struct {
+	UserID   uint
+	Username string
+	HostID   uint
+	Status   string
+	From     *time.Time
+	To       *time.Time
+}
+
+// CreateShellSession 创建会话记录
+func CreateShellSession(db *gorm.DB, session *ShellSession) error {
+	return db.Create(session).Error
+}
+
+// GetShellSession 获取会话记录
+func GetShellSession(db *gorm.DB, id uint) (*ShellSession, error) {
+	var session ShellSession
+	err := db.First(&session, id).Error
+	return &session, err
+}
+
+// SetShellSessionRecording 设置会话录像文件路径
+func SetShellSessionRecording(db *gorm.DB, id uint, path string) error {
+	return db.Model(&ShellSession{}).Where("id = ?", id).Update("recording_path", path).Error
+}
+
+// CloseShellSession 结束会话并记录退出码与录像大小
+func CloseShellSession(db *gorm.DB, id uint, exitStatus *int, recordingSize int64) error {
+	now := time.Now()
+	return db.Model(&ShellSession{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":         ShellSessionClosed,
+		"ended_at":       &now,
+		"exit_status":    exitStatus,
+		"recording_size": recordingSize,
+	}).Error
+}
+
+// CloseStaleShellSessions 将服务重启前未正常结束的会话标记为已结束
+func CloseStaleShellSessions(db *gorm.DB) error {
+	return db.Model(&ShellSession{}).Where("status = ?", ShellSessionActive).Updates(map[string]interface{}{
+		"status":   ShellSessionClosed,
+		"ended_at": time.Now(),
+	}).Error
+}
+
+// GetShellSessionList 获取会话列表，按开始时间倒序
+func GetShellSessionList(db *gorm.DB, page, pageSize int, q ShellSessionQuery) ([]ShellSession, int64, error) {
+	var sessions []ShellSession
+	var total int64
+
+	query := db.Model(&ShellSession{})
+	if q.UserID != 0 {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if q.Username != "" {
+		query = query.Where("username LIKE ?", "%"+q.Username+"%")
+	}
+	if q.HostID != 0 {
+		query = query.Where("host_id = ?", q.HostID)
+	}
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	if q.From != nil {
+		query = query.Where("started_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		query = query.Where("started_at < ?", *q.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("started_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&sessions).Error
+	return sessions, total, err
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// User 用户模型
+type User struct {
+	ID               uint       `gorm:"primarykey" json:"id"`
+	Username         string     `gorm:"size:50;not null;uniqueIndex" json:"username"`
+	Password         string     `gorm:"size:100;not null" json:"-"`
+	Nickname         string     `gorm:"size:100" json:"nickname"`
+	Email            string     `gorm:"size:100" json:"email"`
+	Status           string     `gorm:"size:20;not null;default:'active'" json:"status"`
+	LastLoginAt      *time.Time `json:"lastLoginAt"`
+	TokensValidAfter *time.Time `json:"-"` // 早于该时间签发的 token 均失效，用户被禁用或修改密码时更新
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (User) TableName() string {
+	return "users"
+}
+
+// SetPassword 使用 bcrypt 加密并设置密码
+func (u *User) SetPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.Password = string(hash)
+	return nil
+}
+
+// CheckPassword 校验密码
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) == nil
+}
+
+// IsActive 用户是否可用
+func (u *User) IsActive() bool {
+	return u.Status == "" || u.Status == "active"
+}
+
+// CreateUser 创建用户
+func CreateUser(db *gorm.DB, user *User) error {
+	return db.Create(user).Error
+}
+
+// GetUserByID 根据ID获取用户
+func GetUserByID(db *gorm.DB, id uint) (*User, error) {
+	var user User
+	err := db.First(&user, id).Error
+	return &user, err
+}
+
+// GetUserByUsername 根据用户名获取用户
+func GetUserByUsername(db *gorm.DB, username string) (*User, error) {
+	var user User
+	err := db.Where("username = ?", username).First(&user).Error
+	return &user, err
+}
+
+// TokenValid 判断签发时间为 issuedAt 的 token 是否仍然有效
+func (u *User) TokenValid(issuedAt time.Time) bool {
+	return u.TokensValidAfter == nil || !issuedAt.Before(*u.TokensValidAfter)
+}
+
+// UpdateUserPassword 更新用户密码，此前签发的 token 随之失效
+func UpdateUserPassword(db *gorm.DB, id uint, hash string) error {
+	return db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password":           hash,
+		"tokens_valid_after": time.Now().Truncate(time.Millisecond),
+	}).Error
+}
+
+// RevokeUserTokens 使用户此前签发的 token 全部失效
+func RevokeUserTokens(db *gorm.DB, id uint) error {
+	return db.Model(&User{}).Where("id = ?", id).Update("tokens_valid_after", time.Now().Truncate(time.Millisecond)).Error
+}
+
+// UpdateUserLastLogin 更新最后登录时间
+func UpdateUserLastLogin(db *gorm.DB, id uint, t time.Time) error {
+	return db.Model(&User{}).Where("id = ?", id).Update("last_login_at", t).Error
+}
+
+// GetUserList 获取用户列表
+func GetUserList(db *gorm.DB, page, pageSize int, username string) ([]User, int64, error) {
+	var users []User
+	var total int64
+
+	query := db.Model(&User{})
+	if username != "" {
+		query = query.Where("username LIKE ?", "%"+username+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Offset((page - 1) * pageSize).Limit(pageSize).Find(&users).Error
+	return users, total, err
+}
+
+// UpdateUser 更新用户信息
+func UpdateUser(db *gorm.DB, id uint, user *User) error {
+	return db.Model(&User{}).Where("id = ?", id).
+		Select("nickname", "email", "status").Updates(user).Error
+}
+
+// DeleteUser 删除用户及其角色绑定
+func DeleteUser(db *gorm.DB, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&RoleBinding{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&User{}, id).Error
+	})
+}
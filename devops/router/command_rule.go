@@ -0,0 +1,26 @@
+package router
+
+import (
+	"devops/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCommandRuleRoutes 设置 WebShell 命令策略与审批路由
+func SetupCommandRuleRoutes(router *gin.RouterGroup) {
+	ruleController := controllers.NewCommandRuleController()
+
+	rules := router.Group("/command-rules")
+	{
+		rules.GET("", ruleController.GetCommandRules)
+		rules.POST("", ruleController.CreateCommandRule)
+		rules.PUT("/:id", ruleController.UpdateCommandRule)
+		rules.DELETE("/:id", ruleController.DeleteCommandRule)
+	}
+
+	approvals := router.Group("/command-approvals")
+	{
+		approvals.GET("", ruleController.GetCommandApprovals)
+		approvals.POST("/:id/approve", ruleController.ApproveCommand)
+		approvals.POST("/:id/reject", ruleController.RejectCommand)
+	}
+}
@@ -0,0 +1,19 @@
+package router
+
+import (
+	"devops/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCredentialRoutes 设置主机凭据管理路由
+func SetupCredentialRoutes(router *gin.RouterGroup) {
+	credentialController := controllers.NewCredentialController()
+
+	credentials := router.Group("/credentials")
+	{
+		credentials.GET("", credentialController.GetCredentials)
+		credentials.POST("", credentialController.CreateCredential)
+		credentials.PUT("/:id", credentialController.UpdateCredential)
+		credentials.DELETE("/:id", credentialController.DeleteCredential)
+	}
+}
@@ -0,0 +1,20 @@
+package router
+
+import (
+	"devops/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupExecJobRoutes 设置批量执行路由
+func SetupExecJobRoutes(router *gin.RouterGroup) {
+	execJobController := controllers.NewExecJobController()
+
+	jobs := router.Group("/exec-jobs")
+	{
+		jobs.GET("", execJobController.GetExecJobs)
+		jobs.POST("", execJobController.CreateExecJob)
+		jobs.GET("/:id", execJobController.GetExecJob)
+		jobs.GET("/:id/stream", execJobController.StreamExecJob)
+		jobs.POST("/:id/cancel", execJobController.CancelExecJob)
+	}
+}
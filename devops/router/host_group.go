@@ -0,0 +1,21 @@
+package router
+
+import (
+	"devops/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupHostGroupRoutes 设置主机分组与标签路由
+func SetupHostGroupRoutes(router *gin.RouterGroup) {
+	groupController := controllers.NewHostGroupController()
+
+	groups := router.Group("/host-groups")
+	{
+		groups.GET("", groupController.GetHostGroups)
+		groups.POST("", groupController.CreateHostGroup)
+		groups.PUT("/:id", groupController.UpdateHostGroup)
+		groups.DELETE("/:id", groupController.DeleteHostGroup)
+	}
+
+	router.GET("/host-labels", groupController.GetHostLabels)
+}
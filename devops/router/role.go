@@ -0,0 +1,26 @@
+package router
+
+import (
+	"devops/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRoleRoutes 设置角色与授权管理路由
+func SetupRoleRoutes(router *gin.RouterGroup) {
+	roleController := controllers.NewRoleController()
+
+	roles := router.Group("/roles")
+	{
+		roles.GET("", roleController.GetRoles)
+		roles.POST("", roleController.CreateRole)
+		roles.PUT("/:id", roleController.UpdateRole)
+		roles.DELETE("/:id", roleController.DeleteRole)
+	}
+
+	bindings := router.Group("/role-bindings")
+	{
+		bindings.GET("", roleController.GetRoleBindings)
+		bindings.POST("", roleController.CreateRoleBinding)
+		bindings.DELETE("/:id", roleController.DeleteRoleBinding)
+	}
+}
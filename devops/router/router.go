@@ -2,6 +2,7 @@ package router
 
 import (
 	"devops/controllers"
+	"devops/middleware"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,7 +14,8 @@ func SetupRouter() *gin.Engine {
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range, If-Range")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Range, Content-Disposition, ETag")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -22,11 +24,36 @@ func SetupRouter() *gin.Engine {
 	})
 
 	// API 路由组
+	public := r.Group("/api")
+
+	// 需要认证的路由组
 	api := r.Group("/api")
+	api.Use(middleware.AuthMiddleware())
+
+	// 用户认证路由
+	SetupUserRoutes(public, api)
 
 	// 主机管理路由
 	setupHostRoutes(api)
 
+	// 主机分组与标签路由
+	SetupHostGroupRoutes(api)
+
+	// 主机凭据路由
+	SetupCredentialRoutes(api)
+
+	// WebShell 会话录像路由
+	SetupShellSessionRoutes(api)
+
+	// WebShell 命令策略路由
+	SetupCommandRuleRoutes(api)
+
+	// 批量执行路由
+	SetupExecJobRoutes(api)
+
+	// 后台任务路由
+	SetupTaskRoutes(api)
+
 	// 仓库管理路由
 	setupRepositoryRoutes(api)
 
@@ -36,6 +63,9 @@ func SetupRouter() *gin.Engine {
 	//项目中心
 	SetupProjectRoutes(api)
 
+	// 角色与授权
+	SetupRoleRoutes(api)
+
 	return r
 }
 
@@ -48,14 +78,31 @@ func setupHostRoutes(api *gin.RouterGroup) {
 		hostGroup.PUT("/:id", controllers.UpdateHost)
 		hostGroup.DELETE("/:id", controllers.DeleteHost)
 
+		// 主机信息采集
+		hostGroup.GET("/:id/facts", controllers.GetHostFacts)
+		hostGroup.POST("/:id/facts/refresh", controllers.RefreshHostFacts)
+
+		// 主机密钥
+		hostGroup.GET("/known_hosts", controllers.ExportKnownHosts)
+		hostGroup.POST("/known_hosts", controllers.ImportKnownHosts)
+		hostGroup.GET("/:id/hostkey", controllers.GetHostKey)
+		hostGroup.POST("/:id/hostkey/accept", controllers.AcceptHostKey)
+		hostGroup.DELETE("/:id/hostkey", controllers.ResetHostKey)
+
 		// SFTP相关路由
 		hostGroup.GET("/:id/sftp", controllers.GetSftpFiles)
 		hostGroup.POST("/:id/sftp/upload", controllers.UploadSftpFile)
+		hostGroup.POST("/:id/sftp/uploads", controllers.InitSftpUpload)
+		hostGroup.GET("/:id/sftp/uploads/:uploadId", controllers.GetSftpUpload)
+		hostGroup.PUT("/:id/sftp/uploads/:uploadId", controllers.UploadSftpChunk)
+		hostGroup.POST("/:id/sftp/uploads/:uploadId/complete", controllers.CompleteSftpUpload)
+		hostGroup.DELETE("/:id/sftp/uploads/:uploadId", controllers.AbortSftpUpload)
 		hostGroup.GET("/:id/sftp/download", controllers.DownloadSftpFile)
 		hostGroup.GET("/:id/sftp/download-dir", controllers.DownloadSftpDir)
 		hostGroup.DELETE("/:id/sftp", controllers.DeleteSftpFile)
 		hostGroup.PUT("/:id/sftp/rename", controllers.RenameSftpFile)
 		hostGroup.POST("/:id/sftp/compress", controllers.CompressSftpDir)
+		hostGroup.POST("/:id/sftp/extract", controllers.ExtractSftpFile)
 		hostGroup.GET("/:id/webshell", controllers.WebShell)
 		hostGroup.POST("/:id/upload", controllers.UploadFile)
 		hostGroup.GET("/:id/download", controllers.DownloadFile)
@@ -0,0 +1,28 @@
+package router
+
+import (
+	"devops/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupShellSessionRoutes 设置 WebShell 会话记录、录像、共享与命令审计路由
+func SetupShellSessionRoutes(router *gin.RouterGroup) {
+	sessionController := controllers.NewShellSessionController()
+
+	sessions := router.Group("/sessions")
+	{
+		sessions.GET("", sessionController.GetSessions)
+		sessions.GET("/:id", sessionController.GetSession)
+		sessions.GET("/:id/recording", sessionController.DownloadRecording)
+		sessions.GET("/:id/replay", sessionController.ReplaySession)
+		sessions.GET("/:id/commands", sessionController.GetSessionCommands)
+		sessions.GET("/:id/shares", sessionController.GetShares)
+		sessions.POST("/:id/shares", sessionController.CreateShare)
+		sessions.DELETE("/:id/shares/:shareId", sessionController.RevokeShare)
+		sessions.GET("/:id/attach", sessionController.AttachSession)
+		sessions.GET("/:id/resume", sessionController.ResumeSession)
+	}
+
+	// 命令审计搜索
+	router.GET("/commands", sessionController.SearchCommands)
+}
@@ -0,0 +1,18 @@
+package router
+
+import (
+	"devops/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupTaskRoutes 设置后台任务路由
+func SetupTaskRoutes(router *gin.RouterGroup) {
+	taskController := controllers.NewTaskController()
+
+	tasks := router.Group("/tasks")
+	{
+		tasks.GET("", taskController.GetTasks)
+		tasks.GET("/:id", taskController.GetTask)
+		tasks.POST("/:id/cancel", taskController.CancelTask)
+	}
+}
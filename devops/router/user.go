@@ -0,0 +1,31 @@
+package router
+
+import (
+	"devops/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupUserRoutes 设置用户认证路由
+func SetupUserRoutes(public *gin.RouterGroup, protected *gin.RouterGroup) {
+	userController := controllers.NewUserController()
+
+	public.POST("/login", userController.Login)
+
+	protected.DELETE("/logout", userController.Logout)
+	protected.POST("/refresh", userController.RefreshToken)
+
+	user := protected.Group("/user")
+	{
+		user.GET("/info", userController.GetUserInfo)
+		user.PUT("/password", userController.ChangePassword)
+		user.GET("/permissions", userController.GetPermissions)
+	}
+
+	users := protected.Group("/users")
+	{
+		users.GET("", userController.GetUsers)
+		users.POST("", userController.CreateUser)
+		users.PUT("/:id", userController.UpdateUser)
+		users.DELETE("/:id", userController.DeleteUser)
+	}
+}
@@ -0,0 +1,176 @@
+package secrets
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gorm.io/gorm"
+)
+
+// CommandUsage secrets 子命令用法
+const CommandUsage = `用法: devops [-config 配置文件] secrets <命令>
+
+命令:
+  status        查看各加密字段使用的主密钥
+  rotate        使用当前主密钥重新加密全部加密字段（含历史明文）
+`
+
+// encryptedColumn 模型中使用加密序列化器的列
+type encryptedColumn struct {
+	table   string
+	primary string
+	columns []string
+}
+
+// RunCommand 执行 secrets 子命令，models 为包含加密字段的模型
+func RunCommand(db *gorm.DB, args []string, models []interface{}, w io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(w, CommandUsage)
+		return fmt.Errorf("缺少 secrets 命令")
+	}
+
+	switch args[0] {
+	case "status":
+		return printStatus(db, models, w)
+	case "rotate":
+		count, err := Rotate(db, models...)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "已使用主密钥 %s 重新加密 %d 个字段\n", ActiveKeyID(), count)
+		return printStatus(db, models, w)
+	default:
+		fmt.Fprint(w, CommandUsage)
+		return fmt.Errorf("未知的 secrets 命令: %s", args[0])
+	}
+}
+
+// Rotate 将明文或使用旧主密钥加密的字段用当前主密钥重新加密，返回更新的字段数
+func Rotate(db *gorm.DB, models ...interface{}) (int, error) {
+	targets, err := encryptedColumns(db, models)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, target := range targets {
+		for _, column := range target.columns {
+			rows, err := loadColumn(db, target, column)
+			if err != nil {
+				return total, err
+			}
+
+			err = db.Transaction(func(tx *gorm.DB) error {
+				for id, value := range rows {
+					if !NeedsRotation(value) {
+						continue
+					}
+					plaintext, err := Decrypt(value)
+					if err != nil {
+						return fmt.Errorf("%s.%s (id=%v): %v", target.table, column, id, err)
+					}
+					ciphertext, err := Encrypt(plaintext)
+					if err != nil {
+						return err
+					}
+					if err := tx.Table(target.table).Where(target.primary+" = ?", id).
+						UpdateColumn(column, ciphertext).Error; err != nil {
+						return err
+					}
+					total++
+				}
+				return nil
+			})
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// printStatus 统计每个加密列中各主密钥的使用情况
+func printStatus(db *gorm.DB, models []interface{}, w io.Writer) error {
+	targets, err := encryptedColumns(db, models)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "COLUMN\tKEY\tCOUNT\t(active=%s)\n", ActiveKeyID())
+	for _, target := range targets {
+		for _, column := range target.columns {
+			rows, err := loadColumn(db, target, column)
+			if err != nil {
+				return err
+			}
+			counts := make(map[string]int)
+			for _, value := range rows {
+				switch {
+				case value == "":
+					counts["(empty)"]++
+				case !IsEncrypted(value):
+					counts["(plaintext)"]++
+				default:
+					keyID, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+					counts[keyID]++
+				}
+			}
+			for key, count := range counts {
+				fmt.Fprintf(tw, "%s.%s\t%s\t%d\t\n", target.table, column, key, count)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// encryptedColumns 解析模型，找出使用加密序列化器的列
+func encryptedColumns(db *gorm.DB, models []interface{}) ([]encryptedColumn, error) {
+	var result []encryptedColumn
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		if stmt.Schema.PrioritizedPrimaryField == nil {
+			return nil, fmt.Errorf("secrets: 表 %s 没有主键", stmt.Schema.Table)
+		}
+
+		target := encryptedColumn{
+			table:   stmt.Schema.Table,
+			primary: stmt.Schema.PrioritizedPrimaryField.DBName,
+		}
+		for _, field := range stmt.Schema.Fields {
+			if strings.EqualFold(field.TagSettings["SERIALIZER"], SerializerName) && field.DBName != "" {
+				target.columns = append(target.columns, field.DBName)
+			}
+		}
+		if len(target.columns) > 0 {
+			result = append(result, target)
+		}
+	}
+	return result, nil
+}
+
+// loadColumn 读取整列原始值（不经过序列化器）
+func loadColumn(db *gorm.DB, target encryptedColumn, column string) (map[interface{}]string, error) {
+	var rows []map[string]interface{}
+	if err := db.Table(target.table).Select(target.primary, column).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[interface{}]string, len(rows))
+	for _, row := range rows {
+		switch v := row[column].(type) {
+		case string:
+			result[row[target.primary]] = v
+		case []byte:
+			result[row[target.primary]] = string(v)
+		case nil:
+			result[row[target.primary]] = ""
+		}
+	}
+	return result, nil
+}
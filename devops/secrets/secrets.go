@@ -0,0 +1,222 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"devops/config"
+)
+
+// 密文格式: enc:v1:<主密钥ID>:<被主密钥加密的数据密钥>:<被数据密钥加密的明文>
+// 每个值使用独立的随机数据密钥 (DEK) 加密，DEK 再由主密钥 (KEK) 加密后与密文一起保存。
+const (
+	prefix  = "enc:v1:"
+	keySize = 32
+)
+
+var (
+	// ErrNotInitialized 未初始化主密钥
+	ErrNotInitialized = errors.New("secrets: 主密钥未初始化")
+	// ErrUnknownKey 密文使用了未配置的主密钥
+	ErrUnknownKey = errors.New("secrets: 未知的主密钥")
+	// ErrMalformed 密文格式错误
+	ErrMalformed = errors.New("secrets: 密文格式错误")
+)
+
+// Keyring 主密钥集合，新数据总是使用 active 主密钥加密
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+var (
+	mu      sync.RWMutex
+	keyring *Keyring
+)
+
+// Init 根据配置初始化主密钥
+func Init(conf config.SecretsConfig) error {
+	kr, err := NewKeyring(conf.MasterKeys, conf.ActiveKey)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	keyring = kr
+	mu.Unlock()
+	return nil
+}
+
+// NewKeyring 解析 "ID:base64密钥" 形式的主密钥列表
+func NewKeyring(masterKeys []string, active string) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string][]byte)}
+	for _, item := range masterKeys {
+		id, encoded, ok := strings.Cut(item, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("secrets: 主密钥格式应为 ID:base64")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: 主密钥 %s 不是有效的 base64: %v", id, err)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("secrets: 主密钥 %s 长度必须为 %d 字节", id, keySize)
+		}
+		if _, dup := kr.keys[id]; dup {
+			return nil, fmt.Errorf("secrets: 主密钥 %s 重复", id)
+		}
+		kr.keys[id] = key
+	}
+
+	if active == "" && len(masterKeys) == 1 {
+		active, _, _ = strings.Cut(masterKeys[0], ":")
+	}
+	if _, ok := kr.keys[active]; !ok {
+		return nil, fmt.Errorf("secrets: 当前主密钥 %q 未配置", active)
+	}
+	kr.active = active
+	return kr, nil
+}
+
+// ActiveKeyID 返回当前使用的主密钥ID
+func ActiveKeyID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if keyring == nil {
+		return ""
+	}
+	return keyring.active
+}
+
+// IsEncrypted 判断值是否为密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// NeedsRotation 判断值是否需要用当前主密钥重新加密（明文或使用了旧主密钥）
+func NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	keyID, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return keyID != ActiveKeyID()
+}
+
+// Encrypt 使用当前主密钥加密，空字符串不加密
+func Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	mu.RLock()
+	kr := keyring
+	mu.RUnlock()
+	if kr == nil {
+		return "", ErrNotInitialized
+	}
+
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(kr.keys[kr.active], dek, []byte(kr.active))
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(dek, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + kr.active + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt 解密密文；不带密文前缀的值视为历史明文原样返回
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	keyID := parts[0]
+
+	mu.RLock()
+	kr := keyring
+	mu.RUnlock()
+	if kr == nil {
+		return "", ErrNotInitialized
+	}
+	kek, ok := kr.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	data, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	dek, err := open(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dek, data, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// seal AES-256-GCM 加密，输出 nonce|ciphertext
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open AES-256-GCM 解密
+func open(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: 解密失败: %v", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
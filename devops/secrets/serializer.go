@@ -0,0 +1,55 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// SerializerName 加密字段使用的 GORM 序列化器名称，用法: `gorm:"serializer:encrypted"`
+const SerializerName = "encrypted"
+
+func init() {
+	schema.RegisterSerializer(SerializerName, Serializer{})
+}
+
+// Serializer 在写入数据库时透明加密、读取时透明解密字符串字段
+type Serializer struct{}
+
+// Scan 从数据库读取并解密
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var raw string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		raw = string(v)
+	case string:
+		raw = v
+	default:
+		return fmt.Errorf("secrets: 字段 %s 不支持的数据库类型 %T", field.Name, dbValue)
+	}
+
+	plaintext, err := Decrypt(raw)
+	if err != nil {
+		return fmt.Errorf("secrets: 字段 %s: %v", field.Name, err)
+	}
+
+	value := reflect.New(field.FieldType).Elem()
+	if value.Kind() != reflect.String {
+		return fmt.Errorf("secrets: 字段 %s 必须为字符串类型", field.Name)
+	}
+	value.SetString(plaintext)
+	field.ReflectValueOf(ctx, dst).Set(value)
+	return nil
+}
+
+// Value 加密后写入数据库
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value := reflect.ValueOf(fieldValue)
+	if value.Kind() != reflect.String {
+		return nil, fmt.Errorf("secrets: 字段 %s 必须为字符串类型", field.Name)
+	}
+	return Encrypt(value.String())
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// asciicastHeader asciicast v2 文件头
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder 以 asciicast v2 格式记录终端输出与窗口尺寸变化，可并发调用。
+// 每个事件写入后立即落盘，便于回放进行中的会话。
+type Recorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	start   time.Time
+	size    int64
+	pending []byte // 上次输出末尾不完整的 UTF-8 字符
+	err     error
+}
+
+// NewRecorder 创建录像文件并写入文件头
+func NewRecorder(path string, width, height int, title string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{file: file, w: bufio.NewWriter(file), start: time.Now()}
+	header := asciicastHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: r.start.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": "xterm"},
+	}
+	if err := r.writeLine(header); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return r, nil
+}
+
+// Output 记录一段终端输出，跨片段截断的多字节字符会合并到下一个事件
+func (r *Recorder) Output(data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pending) > 0 {
+		data = append(r.pending, data...)
+		r.pending = nil
+	}
+	if cut := incompleteUTF8(data); cut > 0 {
+		r.pending = append([]byte(nil), data[len(data)-cut:]...)
+		data = data[:len(data)-cut]
+	}
+	if len(data) == 0 {
+		return r.err
+	}
+	return r.event("o", string(data))
+}
+
+// Resize 记录终端尺寸变化
+func (r *Recorder) Resize(width, height int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// Elapsed 返回录像开始至今的秒数
+func (r *Recorder) Elapsed() float64 {
+	return time.Since(r.start).Seconds()
+}
+
+// Close 写出剩余内容并关闭文件，返回录像大小
+func (r *Recorder) Close() (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pending) > 0 {
+		r.event("o", string(r.pending))
+		r.pending = nil
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.size, r.err
+}
+
+// event 写入一个事件 [时间, 类型, 数据]
+func (r *Recorder) event(kind, data string) error {
+	elapsed := float64(time.Since(r.start).Microseconds()) / 1e6
+	return r.writeLine([]interface{}{elapsed, kind, data})
+}
+
+// writeLine 写入一行 JSON 并落盘，出错后不再写入
+func (r *Recorder) writeLine(v interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := r.w.Write(line); err != nil {
+		r.err = err
+		return err
+	}
+	if err := r.w.Flush(); err != nil {
+		r.err = err
+		return err
+	}
+	r.size += int64(len(line))
+	return nil
+}
+
+// incompleteUTF8 返回末尾不完整 UTF-8 字符的字节数
+func incompleteUTF8(data []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if b < utf8.RuneSelf {
+			return 0
+		}
+		if utf8.RuneStart(b) {
+			if utf8.FullRune(data[len(data)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"devops/models"
+)
+
+// 资源类型
+const (
+	ResourceHost        = "host"
+	ResourceRepository  = "repository"
+	ResourceRegistry    = "registry"
+	ResourceProject     = "project"
+	ResourceUser        = "user"
+	ResourceRole        = "role"
+	ResourceCredential  = "credential"
+	ResourceSession     = "session"      // WebShell 会话记录与录像
+	ResourceCommandRule = "command_rule" // WebShell 命令策略
+)
+
+// 操作类型
+const (
+	ActionRead    = "read"
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionSftp    = "sftp"    // 主机文件浏览与传输
+	ActionShell   = "shell"   // 主机 WebShell
+	ActionBuild   = "build"   // 项目构建
+	ActionBind    = "bind"    // 角色授权
+	ActionApprove = "approve" // 审批 WebShell 命令
+)
+
+// 内置角色
+const (
+	RoleAdmin     = "admin"
+	RoleDeveloper = "developer"
+	RoleOperator  = "operator"
+	RoleViewer    = "viewer"
+)
+
+// BuiltInRoles 内置角色及其默认权限
+var BuiltInRoles = []models.Role{
+	{
+		Name:        RoleAdmin,
+		Description: "管理员，拥有全部权限",
+		Permissions: []string{"*:*"},
+	},
+	{
+		Name:        RoleDeveloper,
+		Description: "开发人员，可管理代码仓库与项目构建，只读访问主机与镜像仓库",
+		Permissions: []string{"repository:*", "project:*", "registry:read", "host:read"},
+	},
+	{
+		Name:        RoleOperator,
+		Description: "运维人员，可管理主机（含文件与终端）与镜像仓库",
+		Permissions: []string{"host:*", "credential:*", "registry:*", "repository:read", "project:read"},
+	},
+	{
+		Name:        RoleViewer,
+		Description: "只读用户",
+		Permissions: []string{"host:read", "repository:read", "registry:read", "project:read"},
+	},
+}
+
+// AuthzService 权限服务
+type AuthzService struct {
+	DB *gorm.DB
+}
+
+// NewAuthzService 创建权限服务实例
+func NewAuthzService(db *gorm.DB) *AuthzService {
+	return &AuthzService{DB: db}
+}
+
+// Authorize 判断用户是否可以对资源执行操作。
+// resourceID 为 0 表示集合级操作（列表、创建），此时只有不限定资源ID的绑定才生效。
+func (s *AuthzService) Authorize(userID uint, resource, action string, resourceID uint) (bool, error) {
+	if userID == 0 {
+		return false, nil
+	}
+
+	bindings, err := models.GetUserRoleBindings(s.DB, userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, binding := range bindings {
+		if binding.Role == nil || !bindingCovers(binding, resource, resourceID) {
+			continue
+		}
+		if RoleAllows(binding.Role, resource, action) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// EnsureBuiltInRoles 初始化内置角色，并在没有任何角色绑定时将 admin 用户设为管理员
+func (s *AuthzService) EnsureBuiltInRoles() error {
+	for _, builtIn := range BuiltInRoles {
+		_, err := models.GetRoleByName(s.DB, builtIn.Name)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		role := builtIn
+		role.BuiltIn = true
+		if err := models.CreateRole(s.DB, &role); err != nil {
+			return err
+		}
+	}
+
+	var count int64
+	if err := s.DB.Model(&models.RoleBinding{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	admin, err := models.GetUserByUsername(s.DB, "admin")
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	role, err := models.GetRoleByName(s.DB, RoleAdmin)
+	if err != nil {
+		return err
+	}
+	return models.CreateRoleBinding(s.DB, &models.RoleBinding{UserID: admin.ID, RoleID: role.ID})
+}
+
+// RoleAllows 判断角色是否包含指定权限
+func RoleAllows(role *models.Role, resource, action string) bool {
+	for _, perm := range role.Permissions {
+		res, act, ok := strings.Cut(perm, ":")
+		if !ok {
+			continue
+		}
+		if (res == "*" || res == resource) && (act == "*" || act == action) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPermission 校验权限字符串格式
+func ValidPermission(perm string) bool {
+	res, act, ok := strings.Cut(perm, ":")
+	return ok && res != "" && act != ""
+}
+
+// bindingCovers 判断绑定的作用范围是否覆盖目标资源
+func bindingCovers(binding models.RoleBinding, resource string, resourceID uint) bool {
+	if binding.ResourceType != "" && binding.ResourceType != resource {
+		return false
+	}
+	if binding.ResourceID == 0 {
+		return true
+	}
+	return resourceID != 0 && binding.ResourceID == resourceID
+}
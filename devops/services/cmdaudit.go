@@ -0,0 +1,345 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxCommandLine    = 4096 // 还原的命令行最大字符数，超出部分丢弃
+	maxPendingCommand = 16   // 等待回显的输入行数上限
+)
+
+// 输出流解析状态
+const (
+	termText = iota
+	termEscape
+	termCSI
+	termOSC
+	termOSCEscape
+)
+
+// CommandAssembler 从终端输入输出中还原用户执行的命令行。
+//
+// 输入侧按键组装当前行，回车时记下组装结果；输出侧模拟光标维护屏幕当前行，
+// 回车之后服务器回显的换行到达时，取该行按提示符规则截取命令，
+// 因此 Tab 补全、历史命令等由服务器回显的内容也能还原。
+// 共享会话中每个可输入的用户各自组装输入行，回车时按顺序等待回显，还原的命令归属于回车的用户；
+// 服务器上只有一个输入行，任一用户回车或清除输入行时所有用户的输入行都被清空。
+// 行内没有提示符时，只有回显内容以输入结尾才记录，避免记录关闭回显的密码输入；
+// 全屏程序（vim、top 等）使用备用屏幕期间不记录。
+type CommandAssembler struct {
+	prompt *regexp.Regexp
+
+	inputs  map[ShellUser]*inputLine // 各用户输入侧组装的当前行
+	pending []typedLine              // 已回车、等待回显的输入
+
+	line      []rune // 输出侧屏幕当前行
+	cursor    int
+	state     int
+	seq       []byte // 未结束的控制序列
+	partial   []byte // 跨片段截断的 UTF-8 字符
+	altScreen bool
+}
+
+// inputLine 输入侧组装中的行
+type inputLine struct {
+	text  []rune
+	exact bool // 没有使用 Tab、方向键等需要服务器补全的按键
+}
+
+// typedLine 回车时输入侧组装的行
+type typedLine struct {
+	user  ShellUser
+	text  string
+	exact bool
+}
+
+// AssembledCommand 还原的命令及输入该命令的用户
+type AssembledCommand struct {
+	User    ShellUser
+	Command string
+}
+
+// NewCommandAssembler 创建命令还原器，prompt 匹配行首提示符（含命令前的空白）
+func NewCommandAssembler(prompt *regexp.Regexp) *CommandAssembler {
+	return &CommandAssembler{prompt: prompt, inputs: make(map[ShellUser]*inputLine)}
+}
+
+// Input 处理用户输入
+func (a *CommandAssembler) Input(user ShellUser, data []byte) {
+	in := a.inputs[user]
+	if in == nil {
+		in = &inputLine{exact: true}
+		a.inputs[user] = in
+	}
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case isAcceptLine(b):
+			if !a.altScreen && len(a.pending) < maxPendingCommand {
+				a.pending = append(a.pending, typedLine{user: user, text: string(in.text), exact: in.exact})
+			}
+			a.resetInputs()
+			if b == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+				i++
+			}
+		case b == 0x7f || b == '\b':
+			if len(in.text) > 0 {
+				in.text = in.text[:len(in.text)-1]
+			}
+		case b == 0x03 || b == 0x15: // Ctrl-C、Ctrl-U
+			a.resetInputs()
+		case b == 0x17: // Ctrl-W
+			in.text = []rune(strings.TrimRightFunc(strings.TrimRightFunc(string(in.text), unicode.IsSpace),
+				func(r rune) bool { return !unicode.IsSpace(r) }))
+		case b == 0x1b:
+			// 方向键等控制序列无法在输入侧还原，跳过
+			in.exact = false
+			i += skipInputEscape(data[i:])
+			continue
+		case b < 0x20:
+			in.exact = false
+		default:
+			r, size := utf8.DecodeRune(data[i:])
+			if len(in.text) < maxCommandLine {
+				in.text = append(in.text, r)
+			}
+			i += size
+			continue
+		}
+		i++
+	}
+}
+
+// resetInputs 清空所有用户的输入行
+func (a *CommandAssembler) resetInputs() {
+	for _, in := range a.inputs {
+		in.text = in.text[:0]
+		in.exact = true
+	}
+}
+
+// skipInputEscape 返回输入中控制序列的长度
+func skipInputEscape(data []byte) int {
+	if len(data) < 2 {
+		return len(data)
+	}
+	if data[1] != '[' && data[1] != 'O' {
+		return 2
+	}
+	for i := 2; i < len(data); i++ {
+		if data[i] >= 0x40 && data[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// Output 处理终端输出，返回本段输出中确认执行的命令
+func (a *CommandAssembler) Output(data []byte) []AssembledCommand {
+	if len(a.partial) > 0 {
+		data = append(a.partial, data...)
+		a.partial = nil
+	}
+
+	var commands []AssembledCommand
+	for i := 0; i < len(data); {
+		b := data[i]
+		if a.state != termText {
+			a.control(b)
+			i++
+			continue
+		}
+
+		switch {
+		case b == 0x1b:
+			a.state = termEscape
+			a.seq = a.seq[:0]
+		case b == '\n':
+			if cmd, ok := a.commit(); ok {
+				commands = append(commands, cmd)
+			}
+		case b == '\r':
+			a.cursor = 0
+		case b == '\b':
+			if a.cursor > 0 {
+				a.cursor--
+			}
+		case b == '\t':
+			a.put(' ')
+		case b < 0x20 || b == 0x7f:
+		default:
+			if !utf8.FullRune(data[i:]) {
+				a.partial = append([]byte(nil), data[i:]...)
+				return commands
+			}
+			r, size := utf8.DecodeRune(data[i:])
+			a.put(r)
+			i += size
+			continue
+		}
+		i++
+	}
+	return commands
+}
+
+// put 在光标处写入字符
+func (a *CommandAssembler) put(r rune) {
+	if a.cursor < len(a.line) {
+		a.line[a.cursor] = r
+	} else if len(a.line) < maxCommandLine {
+		a.line = append(a.line, r)
+	} else {
+		return
+	}
+	a.cursor++
+}
+
+// control 处理控制序列中的一个字节
+func (a *CommandAssembler) control(b byte) {
+	switch a.state {
+	case termEscape:
+		switch b {
+		case '[':
+			a.state = termCSI
+		case ']':
+			a.state = termOSC
+		default:
+			a.state = termText
+		}
+	case termCSI:
+		if b >= 0x40 && b <= 0x7e {
+			a.csi(string(a.seq), b)
+			a.state = termText
+			return
+		}
+		if len(a.seq) < 32 {
+			a.seq = append(a.seq, b)
+		}
+	case termOSC:
+		// 窗口标题等 OSC 序列以 BEL 或 ST 结束
+		if b == 0x07 {
+			a.state = termText
+		} else if b == 0x1b {
+			a.state = termOSCEscape
+		}
+	case termOSCEscape:
+		if b == '\\' {
+			a.state = termText
+		} else {
+			a.state = termOSC
+		}
+	}
+}
+
+// csi 执行影响当前行的 CSI 序列
+func (a *CommandAssembler) csi(params string, final byte) {
+	n := 1
+	if v, err := strconv.Atoi(params); err == nil && v > 0 {
+		n = v
+	}
+
+	switch final {
+	case 'C':
+		a.cursor += n
+		for len(a.line) < a.cursor && len(a.line) < maxCommandLine {
+			a.line = append(a.line, ' ')
+		}
+		if a.cursor > len(a.line) {
+			a.cursor = len(a.line)
+		}
+	case 'D':
+		a.cursor -= n
+		if a.cursor < 0 {
+			a.cursor = 0
+		}
+	case 'G':
+		a.cursor = n - 1
+		for len(a.line) < a.cursor && len(a.line) < maxCommandLine {
+			a.line = append(a.line, ' ')
+		}
+		if a.cursor > len(a.line) {
+			a.cursor = len(a.line)
+		}
+	case 'K':
+		switch params {
+		case "", "0":
+			a.line = a.line[:a.cursor]
+		case "2":
+			a.line = a.line[:0]
+			a.cursor = 0
+		}
+	case 'P':
+		if a.cursor < len(a.line) {
+			end := a.cursor + n
+			if end > len(a.line) {
+				end = len(a.line)
+			}
+			a.line = append(a.line[:a.cursor], a.line[end:]...)
+		}
+	case '@':
+		if a.cursor < len(a.line) {
+			blanks := []rune(strings.Repeat(" ", n))
+			a.line = append(a.line[:a.cursor], append(blanks, a.line[a.cursor:]...)...)
+			if len(a.line) > maxCommandLine {
+				a.line = a.line[:maxCommandLine]
+			}
+		}
+	case 'J':
+		if params == "2" {
+			a.line = a.line[:0]
+			a.cursor = 0
+		}
+	case 'h', 'l':
+		switch params {
+		case "?1049", "?1047", "?47":
+			a.altScreen = final == 'h'
+			a.pending = nil
+		}
+	}
+}
+
+// Current 返回尚未回车的当前行：用户输入侧组装的内容，以及屏幕当前行去掉提示符后的内容
+func (a *CommandAssembler) Current(user ShellUser) (typed, screen string) {
+	if in := a.inputs[user]; in != nil {
+		typed = strings.TrimSpace(string(in.text))
+	}
+	if a.altScreen {
+		return typed, ""
+	}
+	screen = string(a.line)
+	if loc := a.prompt.FindStringIndex(screen); loc != nil {
+		screen = screen[loc[1]:]
+	}
+	return typed, strings.TrimSpace(screen)
+}
+
+// commit 输出换行时结束当前行，若有等待回显的输入则还原命令
+func (a *CommandAssembler) commit() (AssembledCommand, bool) {
+	line := string(a.line)
+	a.line = a.line[:0]
+	a.cursor = 0
+
+	if len(a.pending) == 0 || a.altScreen {
+		return AssembledCommand{}, false
+	}
+	typed := a.pending[0]
+	a.pending = a.pending[1:]
+	text := strings.TrimSpace(typed.text)
+
+	var cmd string
+	if loc := a.prompt.FindStringIndex(line); loc != nil {
+		cmd = strings.TrimSpace(line[loc[1]:])
+	} else if text != "" && strings.HasSuffix(strings.TrimRight(line, " "), text) {
+		cmd = text
+	}
+	// 输入可以完整还原时回显必须一致，否则该行不是回显（如关闭回显的密码输入后的输出）
+	if typed.exact && cmd != text {
+		return AssembledCommand{}, false
+	}
+	return AssembledCommand{User: typed.user, Command: cmd}, cmd != ""
+}
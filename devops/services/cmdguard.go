@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"devops/config"
+	"devops/models"
+)
+
+// clearLine 发送给服务器以清除 shell 当前输入行（Ctrl-E、Ctrl-U）并换行显示新的提示符
+var clearLine = []byte{0x05, 0x15, '\r'}
+
+// isAcceptLine 判断输入是否会使 shell 执行当前行：回车（Ctrl-M）、换行（Ctrl-J）以及 bash 的 operate-and-get-next（Ctrl-O）
+func isAcceptLine(b byte) bool {
+	return b == '\r' || b == '\n' || b == 0x0f
+}
+
+// indexAcceptLine 返回输入中第一个提交命令行的字节位置，没有时返回 -1
+func indexAcceptLine(data []byte) int {
+	for i, b := range data {
+		if isAcceptLine(b) {
+			return i
+		}
+	}
+	return -1
+}
+
+// CommandGuard 在 WebShell 用户回车提交命令行前按命令策略检查：
+// 禁止的命令不发送到服务器，需要审批的命令在审批通过后才发送。
+type CommandGuard struct {
+	policy    *CommandPolicyService
+	recording *ShellRecording
+	host      *models.Host
+	stdin     io.Writer
+	terminal  func(text string) // 向用户终端输出提示
+
+	mu      sync.Mutex
+	waiting chan struct{} // 等待审批期间非空，关闭表示用户取消
+	closed  bool
+}
+
+// NewCommandGuard 创建命令检查器，terminal 用于向用户终端输出提示
+func NewCommandGuard(db *gorm.DB, recording *ShellRecording, host *models.Host, stdin io.Writer, terminal func(text string)) *CommandGuard {
+	return &CommandGuard{
+		policy:    NewCommandPolicyService(db),
+		recording: recording,
+		host:      host,
+		stdin:     stdin,
+		terminal:  terminal,
+	}
+}
+
+// Input 处理用户输入并转发到服务器，命令审计记录在输入的用户名下。等待审批期间忽略输入，Ctrl-C 取消审批；
+// 同一段输入中被禁止或需要审批的命令之后的内容会被丢弃。
+func (g *CommandGuard) Input(user ShellUser, data []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.waiting != nil {
+		if bytes.IndexByte(data, 0x03) >= 0 {
+			g.cancel()
+		}
+		return nil
+	}
+
+	for len(data) > 0 {
+		i := indexAcceptLine(data)
+		if i < 0 {
+			return g.forward(user, data)
+		}
+		if err := g.forward(user, data[:i]); err != nil {
+			return err
+		}
+		enter := data[i]
+		data = data[i+1:]
+
+		typed, screen := g.recording.CurrentLine(user)
+		rule, command, err := g.policy.Match(g.host, typed, screen)
+		if err != nil {
+			// 无法确认命令是否允许执行时不放行
+			log.Printf("加载命令策略失败: %v", err)
+			g.terminal("\r\n\x1b[31m命令策略加载失败，命令未执行\x1b[0m\r\n")
+			return g.forward(user, clearLine)
+		}
+		if rule == nil {
+			if err := g.forward(user, []byte{enter}); err != nil {
+				return err
+			}
+			continue
+		}
+		if rule.Action == models.CommandRuleBlock {
+			return g.block(user, rule, command)
+		}
+		return g.hold(user, rule, command)
+	}
+	return nil
+}
+
+// Close 会话结束时取消等待中的审批
+func (g *CommandGuard) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	g.cancel()
+}
+
+// cancel 取消等待中的审批，调用方需持有锁
+func (g *CommandGuard) cancel() {
+	if g.waiting == nil {
+		return
+	}
+	select {
+	case <-g.waiting:
+	default:
+		close(g.waiting)
+	}
+}
+
+// forward 将用户的输入发送到服务器并用于命令还原
+func (g *CommandGuard) forward(user ShellUser, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	g.recording.Input(user, data)
+	_, err := g.stdin.Write(data)
+	return err
+}
+
+// block 拒绝执行命令并记录审计
+func (g *CommandGuard) block(user ShellUser, rule *models.CommandRule, command string) error {
+	if _, err := g.recording.Audit(user, command, models.CommandBlocked, rule); err != nil {
+		log.Printf("记录会话 %d 命令审计失败: %v", g.recording.Session.ID, err)
+	}
+	g.terminal(fmt.Sprintf("\r\n\x1b[31m命令被禁止执行 (规则: %s)\x1b[0m\r\n", rule.Name))
+	return g.forward(user, clearLine)
+}
+
+// hold 登记审批并在后台等待结果，命令行保留在服务器的输入行中
+func (g *CommandGuard) hold(user ShellUser, rule *models.CommandRule, command string) error {
+	audit, err := g.recording.Audit(user, command, models.CommandPending, rule)
+	if err != nil {
+		log.Printf("记录会话 %d 命令审计失败: %v", g.recording.Session.ID, err)
+		g.terminal("\r\n\x1b[31m提交命令审批失败，命令未执行\x1b[0m\r\n")
+		return g.forward(user, clearLine)
+	}
+
+	g.terminal(fmt.Sprintf("\r\n\x1b[33m命令需要审批 (规则: %s, 审批单 #%d)，等待审批中，按 Ctrl-C 取消\x1b[0m\r\n",
+		rule.Name, audit.ID))
+	g.waiting = make(chan struct{})
+	go g.wait(user, audit.ID, g.waiting)
+	return nil
+}
+
+// wait 等待审批结果，通过后以提交审批的用户发送回车执行命令，否则清除输入行
+func (g *CommandGuard) wait(user ShellUser, auditID uint, cancel chan struct{}) {
+	audit, err := g.policy.WaitApproval(auditID, cancel, config.Conf.SSH.ApprovalTimeout)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.waiting = nil
+	if g.closed {
+		return
+	}
+
+	switch {
+	case err != nil:
+		log.Printf("等待命令审批失败: %v", err)
+		g.terminal("\r\n\x1b[31m等待审批失败，命令未执行\x1b[0m\r\n")
+		err = g.forward(user, clearLine)
+	case audit.Status == models.CommandApproved:
+		g.terminal(fmt.Sprintf("\x1b[32m审批通过 (审批人: %s)\x1b[0m\r\n", audit.Reviewer))
+		err = g.forward(user, []byte{'\r'})
+	default:
+		reason := audit.Remark
+		if reason == "" {
+			reason = "审批未通过"
+		}
+		if audit.Reviewer != "" {
+			reason = fmt.Sprintf("%s (审批人: %s)", reason, audit.Reviewer)
+		}
+		g.terminal(fmt.Sprintf("\x1b[31m命令未执行: %s\x1b[0m\r\n", reason))
+		err = g.forward(user, clearLine)
+	}
+	if err != nil {
+		log.Printf("写入标准输入失败: %v", err)
+	}
+}
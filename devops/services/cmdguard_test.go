@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"devops/models"
+)
+
+// 测试会话的创建者与经可写共享连接的用户
+var (
+	testOwner  = ShellUser{ID: 1, Name: "admin"}
+	testViewer = ShellUser{ID: 2, Name: "alice"}
+)
+
+// newTestDB 创建临时的 SQLite 数据库并建表
+func newTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	return db
+}
+
+// newTestGuard 创建写入 stdin 的命令检查器，规则为禁止 rm -rf /
+func newTestGuard(t *testing.T, stdin *bytes.Buffer) (*CommandGuard, *gorm.DB) {
+	t.Helper()
+	db := newTestDB(t, &models.CommandRule{}, &models.CommandAudit{})
+	rule := &models.CommandRule{Name: "禁止删除根目录", Pattern: `rm\s+-rf\s+/(\s|$)`, Action: models.CommandRuleBlock, Enabled: true}
+	if err := models.CreateCommandRule(db, rule); err != nil {
+		t.Fatal(err)
+	}
+	return newHostGuard(t, db, &models.Host{ID: 1, Name: "web-1"}, stdin), db
+}
+
+// newHostGuard 为指定主机创建写入 stdin 的命令检查器
+func newHostGuard(t *testing.T, db *gorm.DB, host *models.Host, stdin *bytes.Buffer) *CommandGuard {
+	t.Helper()
+	recorder, err := NewRecorder(filepath.Join(t.TempDir(), "1.cast"), 80, 24, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { recorder.Close() })
+	recording := &ShellRecording{
+		Recorder: recorder,
+		Session:  &models.ShellSession{ID: 1, UserID: testOwner.ID, Username: testOwner.Name, HostID: host.ID, HostName: host.Name},
+		db:       db,
+		commands: NewCommandAssembler(regexp.MustCompile(`^.*?[$#%>] `)),
+	}
+	return NewCommandGuard(db, recording, host, stdin, func(string) {})
+}
+
+func TestCommandGuardInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []string
+		stdin   string
+		blocked int
+	}{
+		{name: "回车提交被禁止", input: []string{"rm -rf /\r"}, stdin: "rm -rf /" + string(clearLine), blocked: 1},
+		{name: "换行提交被禁止", input: []string{"rm -rf /\n"}, stdin: "rm -rf /" + string(clearLine), blocked: 1},
+		{name: "Ctrl-O 提交被禁止", input: []string{"rm -rf /\x0f"}, stdin: "rm -rf /" + string(clearLine), blocked: 1},
+		{name: "分段输入", input: []string{"rm -r", "f /", "\n"}, stdin: "rm -rf /" + string(clearLine), blocked: 1},
+		{name: "允许的命令原样转发", input: []string{"ls -l\n"}, stdin: "ls -l\n"},
+		{name: "CRLF 原样转发", input: []string{"ls\r\n"}, stdin: "ls\r\n"},
+		{name: "禁止命令之后的输入被丢弃", input: []string{"ls\nrm -rf /\nid\n"}, stdin: "ls\nrm -rf /" + string(clearLine), blocked: 1},
+		{name: "未提交的输入直接转发", input: []string{"rm -rf /"}, stdin: "rm -rf /"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdin bytes.Buffer
+			guard, db := newTestGuard(t, &stdin)
+			for _, data := range tt.input {
+				if err := guard.Input(testOwner, []byte(data)); err != nil {
+					t.Fatalf("Input(%q) 返回错误: %v", data, err)
+				}
+			}
+			if got := stdin.String(); got != tt.stdin {
+				t.Errorf("发送到服务器的内容为 %q，期望 %q", got, tt.stdin)
+			}
+
+			var blocked int64
+			if err := db.Model(&models.CommandAudit{}).Where("status = ?", models.CommandBlocked).Count(&blocked).Error; err != nil {
+				t.Fatal(err)
+			}
+			if blocked != int64(tt.blocked) {
+				t.Errorf("记录了 %d 条禁止执行的审计，期望 %d", blocked, tt.blocked)
+			}
+		})
+	}
+}
+
+// 主机从数据库加载，按上级分组生效的规则也要拦截
+func TestCommandGuardGroupRule(t *testing.T) {
+	db := newTestDB(t, &models.CommandRule{}, &models.CommandAudit{}, &models.HostGroup{}, &models.Label{}, &models.Credential{}, &models.Host{})
+
+	prod := &models.HostGroup{Name: "生产"}
+	if err := models.CreateHostGroup(db, prod); err != nil {
+		t.Fatal(err)
+	}
+	mysql := &models.HostGroup{Name: "数据库", ParentID: &prod.ID}
+	if err := models.CreateHostGroup(db, mysql); err != nil {
+		t.Fatal(err)
+	}
+	rule := &models.CommandRule{Name: "生产分组禁止删库", Pattern: `drop\s+database`, Action: models.CommandRuleBlock, GroupIDs: []uint{prod.ID}, Enabled: true}
+	if err := models.CreateCommandRule(db, rule); err != nil {
+		t.Fatal(err)
+	}
+	created := &models.Host{Name: "mysql-1", IP: "10.0.0.1", Port: 22, Username: "root", GroupIDs: []uint{mysql.ID}}
+	if err := models.CreateHost(db, created); err != nil {
+		t.Fatal(err)
+	}
+	host, err := models.GetHostByID(db, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdin bytes.Buffer
+	guard := newHostGuard(t, db, host, &stdin)
+	if err := guard.Input(testOwner, []byte("mysql -e 'drop database app'\r")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdin.String(), "mysql -e 'drop database app'"+string(clearLine); got != want {
+		t.Errorf("发送到服务器的内容为 %q，期望 %q", got, want)
+	}
+	var audit models.CommandAudit
+	if err := db.Where("status = ?", models.CommandBlocked).First(&audit).Error; err != nil {
+		t.Fatalf("没有记录禁止执行的审计: %v", err)
+	}
+	if audit.RuleName != rule.Name {
+		t.Errorf("审计记录的规则为 %q，期望 %q", audit.RuleName, rule.Name)
+	}
+}
+
+// 共享会话中各用户的输入分别组装，命令审计记录在输入的用户名下
+func TestCommandGuardSharedWriter(t *testing.T) {
+	var stdin bytes.Buffer
+	guard, db := newTestGuard(t, &stdin)
+
+	// 创建者输入到一半时，共享用户输入并提交禁止的命令
+	steps := []struct {
+		user ShellUser
+		data string
+	}{
+		{testOwner, "ec"},
+		{testViewer, "rm -rf /"},
+		{testOwner, "ho"},
+		{testViewer, "\r"},
+	}
+	for _, step := range steps {
+		if err := guard.Input(step.user, []byte(step.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := stdin.String(), "ecrm -rf /ho"+string(clearLine); got != want {
+		t.Errorf("发送到服务器的内容为 %q，期望 %q", got, want)
+	}
+
+	// 禁止的命令被清除后各自提交命令
+	for _, step := range []struct {
+		user   ShellUser
+		data   string
+		output string
+	}{
+		{testViewer, "id\r", "[u@web-1 ~]$ \r\n[u@web-1 ~]$ id\r\nuid=0(root)\r\n"},
+		{testOwner, "ls\r", "[u@web-1 ~]$ ls\r\n"},
+	} {
+		if err := guard.Input(step.user, []byte(step.data)); err != nil {
+			t.Fatal(err)
+		}
+		if err := guard.recording.Output([]byte(step.output)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var audits []models.CommandAudit
+	if err := db.Order("id").Find(&audits).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		command, status, username string
+		userID                    uint
+	}{
+		{"rm -rf /", models.CommandBlocked, testViewer.Name, testViewer.ID},
+		{"id", models.CommandExecuted, testViewer.Name, testViewer.ID},
+		{"ls", models.CommandExecuted, testOwner.Name, testOwner.ID},
+	}
+	if len(audits) != len(want) {
+		t.Fatalf("记录了 %d 条审计，期望 %d 条: %+v", len(audits), len(want), audits)
+	}
+	for i, w := range want {
+		a := audits[i]
+		if a.Command != w.command || a.Status != w.status || a.Username != w.username || a.UserID != w.userID {
+			t.Errorf("第 %d 条审计为 %q %s %s(%d)，期望 %q %s %s(%d)",
+				i+1, a.Command, a.Status, a.Username, a.UserID, w.command, w.status, w.username, w.userID)
+		}
+	}
+}
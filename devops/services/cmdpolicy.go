@@ -0,0 +1,186 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"devops/models"
+)
+
+// ErrCommandReviewed 命令已审批或已取消
+var ErrCommandReviewed = errors.New("该命令已处理")
+
+// CommandPolicyService WebShell 命令策略服务
+type CommandPolicyService struct {
+	DB *gorm.DB
+}
+
+// NewCommandPolicyService 创建命令策略服务实例
+func NewCommandPolicyService(db *gorm.DB) *CommandPolicyService {
+	return &CommandPolicyService{DB: db}
+}
+
+// ValidateCommandRule 校验命令规则
+func ValidateCommandRule(rule *models.CommandRule) error {
+	if strings.TrimSpace(rule.Name) == "" {
+		return errors.New("规则名称不能为空")
+	}
+	if rule.Pattern == "" {
+		return errors.New("匹配规则不能为空")
+	}
+	if _, err := regexp.Compile(rule.Pattern); err != nil {
+		return fmt.Errorf("匹配规则不是有效的正则表达式: %v", err)
+	}
+	switch rule.Action {
+	case models.CommandRuleBlock, models.CommandRuleApprove:
+	default:
+		return errors.New("规则动作必须为 block/approve")
+	}
+	return nil
+}
+
+// Match 返回对主机生效且匹配任一命令行的规则及匹配的命令行，禁止规则优先于审批规则。
+// 规则同时与整行及行内的各条命令匹配（见 splitCommandLine），以 ^ 开头的规则也能拦截 true; rm -rf / 这样的写法
+func (s *CommandPolicyService) Match(host *models.Host, lines ...string) (*models.CommandRule, string, error) {
+	rules, err := models.GetEnabledCommandRules(s.DB)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var hostGroups []uint
+	for _, rule := range rules {
+		if len(rule.GroupIDs) > 0 {
+			if hostGroups, err = models.GetHostGroupAncestors(s.DB, host.GroupIDs); err != nil {
+				return nil, "", err
+			}
+			break
+		}
+	}
+
+	var approve *models.CommandRule
+	var approveLine string
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.AppliesTo(host, hostGroups) {
+			continue
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			if line == "" || !matchCommandLine(re, line) {
+				continue
+			}
+			if rule.Action == models.CommandRuleBlock {
+				return rule, line, nil
+			}
+			if approve == nil {
+				approve, approveLine = rule, line
+			}
+		}
+	}
+	return approve, approveLine, nil
+}
+
+// matchCommandLine 判断整行或行内的任一条命令是否匹配规则
+func matchCommandLine(re *regexp.Regexp, line string) bool {
+	if re.MatchString(line) {
+		return true
+	}
+	for _, command := range splitCommandLine(line) {
+		if re.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommandLine 按 ;、&、|（含 &&、||）、换行以及子 shell 的括号与反引号切分命令行，返回去掉首尾空白的各条命令。
+// 不区分引号，bash -c "true; rm -rf /" 中的命令同样会被切分出来，宁可多匹配也不漏过
+func splitCommandLine(line string) []string {
+	fields := strings.FieldsFunc(line, func(r rune) bool {
+		return strings.ContainsRune(";&|\n()`", r)
+	})
+	commands := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if command := strings.TrimSpace(field); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// commandApprovals 等待审批结果的会话，审批结果以数据库中的状态为准，这里只负责及时通知
+var commandApprovals = struct {
+	sync.Mutex
+	waiters map[uint]chan struct{}
+}{waiters: make(map[uint]chan struct{})}
+
+// Review 审批命令，只能处理等待审批的命令
+func (s *CommandPolicyService) Review(auditID uint, approved bool, reviewer, remark string) error {
+	status := models.CommandRejected
+	if approved {
+		status = models.CommandApproved
+	}
+	ok, err := models.ResolveCommandAudit(s.DB, auditID, status, reviewer, remark)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCommandReviewed
+	}
+
+	commandApprovals.Lock()
+	if ch, ok := commandApprovals.waiters[auditID]; ok {
+		close(ch)
+		delete(commandApprovals.waiters, auditID)
+	}
+	commandApprovals.Unlock()
+	return nil
+}
+
+// WaitApproval 等待命令审批结果，cancel 关闭或超时后视为拒绝，返回处理后的审计记录
+func (s *CommandPolicyService) WaitApproval(auditID uint, cancel <-chan struct{}, timeout time.Duration) (*models.CommandAudit, error) {
+	ch := make(chan struct{})
+	commandApprovals.Lock()
+	commandApprovals.waiters[auditID] = ch
+	commandApprovals.Unlock()
+	defer func() {
+		commandApprovals.Lock()
+		if commandApprovals.waiters[auditID] == ch {
+			delete(commandApprovals.waiters, auditID)
+		}
+		commandApprovals.Unlock()
+	}()
+
+	// 登记之前可能已被审批
+	audit, err := models.GetCommandAudit(s.DB, auditID)
+	if err != nil {
+		return nil, err
+	}
+	if audit.Status != models.CommandPending {
+		return audit, nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+	case <-cancel:
+		if _, err := models.ResolveCommandAudit(s.DB, auditID, models.CommandRejected, "", "审批已取消"); err != nil {
+			return nil, err
+		}
+	case <-timer.C:
+		if _, err := models.ResolveCommandAudit(s.DB, auditID, models.CommandRejected, "", "审批超时"); err != nil {
+			return nil, err
+		}
+	}
+	return models.GetCommandAudit(s.DB, auditID)
+}
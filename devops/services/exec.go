@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+
+	"devops/models"
+)
+
+// 批量执行的默认值与上限
+const (
+	DefaultExecParallelism = 10
+	MaxExecParallelism     = 50
+	DefaultExecTimeout     = time.Minute
+	MaxExecTimeout         = time.Hour
+
+	execOutputLimit      = 256 * 1024 // 每台主机每个输出流保存的最大字节数
+	execSubscriberBuffer = 1024
+)
+
+// execTruncated 输出超过 execOutputLimit 时追加的提示
+const execTruncated = "\n...[输出过长，已截断]\n"
+
+// ExecEvent 批量执行任务的实时事件
+type ExecEvent struct {
+	Type   string             `json:"type"`             // output：输出片段；status：主机执行状态变化；done：任务结束
+	HostID uint               `json:"hostId,omitempty"` // output 事件所属主机
+	Stream string             `json:"stream,omitempty"` // stdout/stderr
+	Data   string             `json:"data,omitempty"`
+	Result *models.ExecResult `json:"result,omitempty"` // status 事件的主机执行结果，不含输出
+	Job    *models.ExecJob    `json:"job,omitempty"`    // done 事件的任务
+}
+
+// ExecService 批量执行服务
+type ExecService struct {
+	DB *gorm.DB
+}
+
+// NewExecService 创建批量执行服务实例
+func NewExecService(db *gorm.DB) *ExecService {
+	return &ExecService{DB: db}
+}
+
+// CheckPolicy 按命令规则检查命令能否在各主机上执行，批量执行不支持需要审批的命令
+func (s *ExecService) CheckPolicy(hosts []models.Host, command string) error {
+	policy := NewCommandPolicyService(s.DB)
+	lines := strings.Split(command, "\n")
+	for i := range hosts {
+		rule, _, err := policy.Match(&hosts[i], lines...)
+		if err != nil {
+			return fmt.Errorf("加载命令策略失败: %v", err)
+		}
+		if rule == nil {
+			continue
+		}
+		if rule.Action == models.CommandRuleBlock {
+			return fmt.Errorf("命令在主机 %s 上被禁止执行 (规则: %s)", hosts[i].Name, rule.Name)
+		}
+		return fmt.Errorf("命令在主机 %s 上需要审批 (规则: %s)，不能批量执行", hosts[i].Name, rule.Name)
+	}
+	return nil
+}
+
+// Start 创建任务并在后台按并发数在各主机上执行命令
+func (s *ExecService) Start(job *models.ExecJob, hosts []models.Host) error {
+	job.ID = 0
+	job.Status = models.ExecJobRunning
+	job.Total = len(hosts)
+	job.StartedAt = time.Now()
+	job.Results = make([]models.ExecResult, len(hosts))
+	for i, host := range hosts {
+		job.Results[i] = models.ExecResult{
+			HostID:   host.ID,
+			HostName: host.Name,
+			HostAddr: net.JoinHostPort(host.IP, strconv.Itoa(host.Port)),
+			Status:   models.ExecPending,
+		}
+	}
+	if err := models.CreateExecJob(s.DB, job); err != nil {
+		return err
+	}
+
+	// 执行过程中只修改副本，调用方持有的任务保持创建时的状态
+	running := *job
+	running.Results = append([]models.ExecResult(nil), job.Results...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &execRun{
+		db:          s.DB,
+		job:         &running,
+		ctx:         ctx,
+		cancel:      cancel,
+		subscribers: make(map[chan ExecEvent]struct{}),
+	}
+	execRuns.Lock()
+	execRuns.runs[job.ID] = run
+	execRuns.Unlock()
+
+	go run.execute(hosts)
+	return nil
+}
+
+// CloseStale 将服务重启前仍在执行的任务标记为中断
+func (s *ExecService) CloseStale() error {
+	return models.InterruptStaleExecJobs(s.DB)
+}
+
+// execRuns 本进程中执行中的任务
+var execRuns = struct {
+	sync.Mutex
+	runs map[uint]*execRun
+}{runs: make(map[uint]*execRun)}
+
+// CancelExecJob 取消执行中的任务，正在执行的命令会被终止，任务不存在或已结束时返回 false
+func CancelExecJob(jobID uint) bool {
+	execRuns.Lock()
+	run, ok := execRuns.runs[jobID]
+	execRuns.Unlock()
+	if ok {
+		run.cancel()
+	}
+	return ok
+}
+
+// SubscribeExecJob 订阅执行中任务的事件，同时返回各主机当前的结果（含已产生的输出）。
+// 任务结束后发送 done 事件并关闭通道；接收过慢时通道直接关闭。任务已结束时 ok 为 false。
+func SubscribeExecJob(jobID uint) (results []models.ExecResult, events <-chan ExecEvent, unsubscribe func(), ok bool) {
+	execRuns.Lock()
+	run, ok := execRuns.runs[jobID]
+	execRuns.Unlock()
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if run.finished {
+		return nil, nil, nil, false
+	}
+	ch := make(chan ExecEvent, execSubscriberBuffer)
+	run.subscribers[ch] = struct{}{}
+	results = append([]models.ExecResult(nil), run.job.Results...)
+	unsubscribe = func() {
+		run.mu.Lock()
+		defer run.mu.Unlock()
+		if _, ok := run.subscribers[ch]; ok {
+			delete(run.subscribers, ch)
+			close(ch)
+		}
+	}
+	return results, ch, unsubscribe, true
+}
+
+// execRun 执行中的任务
+type execRun struct {
+	db     *gorm.DB
+	job    *models.ExecJob
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu          sync.Mutex // 保护 job.Results、subscribers 与 finished
+	subscribers map[chan ExecEvent]struct{}
+	finished    bool
+}
+
+// execute 按并发数在各主机上执行命令，全部结束后记录任务结果
+func (r *execRun) execute(hosts []models.Host) {
+	timeout := time.Duration(r.job.Timeout) * time.Second
+	sem := make(chan struct{}, r.job.Parallelism)
+	var wg sync.WaitGroup
+
+	for i := range hosts {
+		select {
+		case sem <- struct{}{}:
+		case <-r.ctx.Done():
+		}
+		if r.ctx.Err() != nil {
+			r.finishHost(i, models.ExecCanceled, nil, "任务已取消")
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r.runHost(i, &hosts[i], timeout)
+		}(i)
+	}
+	wg.Wait()
+
+	status := models.ExecJobFinished
+	if r.ctx.Err() != nil {
+		status = models.ExecJobCanceled
+	}
+	r.cancel()
+
+	r.mu.Lock()
+	succeeded, failed := 0, 0
+	for _, result := range r.job.Results {
+		if result.Status == models.ExecSuccess {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+	r.mu.Unlock()
+
+	now := time.Now()
+	if err := models.FinishExecJob(r.db, r.job.ID, status, succeeded, failed, now); err != nil {
+		log.Printf("更新批量执行任务 %d 失败: %v", r.job.ID, err)
+	}
+
+	execRuns.Lock()
+	delete(execRuns.runs, r.job.ID)
+	execRuns.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.job.Status = status
+	r.job.Succeeded = succeeded
+	r.job.Failed = failed
+	r.job.FinishedAt = &now
+	r.finished = true
+	done := *r.job
+	done.Results = nil
+	for ch := range r.subscribers {
+		select {
+		case ch <- ExecEvent{Type: "done", Job: &done}:
+		default:
+		}
+		close(ch)
+	}
+	r.subscribers = nil
+}
+
+// runHost 在一台主机上执行命令，超时或任务取消时终止命令
+func (r *execRun) runHost(i int, host *models.Host, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(r.ctx, timeout)
+	defer cancel()
+
+	now := time.Now()
+	r.mu.Lock()
+	r.job.Results[i].Status = models.ExecRunning
+	r.job.Results[i].StartedAt = &now
+	r.mu.Unlock()
+	r.publishStatus(i)
+
+	exitCode, err := r.command(ctx, i, host)
+	switch {
+	case r.ctx.Err() != nil:
+		r.finishHost(i, models.ExecCanceled, nil, "任务已取消")
+	case ctx.Err() != nil:
+		r.finishHost(i, models.ExecTimeout, nil, fmt.Sprintf("执行超时 (%s)", timeout))
+	case exitCode == nil:
+		r.finishHost(i, models.ExecError, nil, err.Error())
+	case *exitCode == 0:
+		r.finishHost(i, models.ExecSuccess, exitCode, "")
+	default:
+		r.finishHost(i, models.ExecFailed, exitCode, "")
+	}
+}
+
+// command 通过连接池执行命令并转发输出，返回退出码，未取得退出码时返回错误
+func (r *execRun) command(ctx context.Context, i int, host *models.Host) (*int, error) {
+	lease, err := AcquireSSH(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("SSH连接失败: %v", err)
+	}
+	defer lease.Release()
+
+	session, err := lease.Client().NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("获取标准输出失败: %v", err)
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("获取标准错误失败: %v", err)
+	}
+	if err := session.Start(r.job.Command); err != nil {
+		return nil, fmt.Errorf("执行命令失败: %v", err)
+	}
+
+	var output sync.WaitGroup
+	output.Add(2)
+	go r.forward(i, "stdout", stdout, &output)
+	go r.forward(i, "stderr", stderr, &output)
+
+	wait := make(chan error, 1)
+	go func() { wait <- session.Wait() }()
+
+	select {
+	case err = <-wait:
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		err = <-wait
+	}
+	output.Wait()
+
+	exitCode := SessionExitStatus(err)
+	if exitCode == nil && err != nil {
+		return nil, fmt.Errorf("执行命令失败: %v", err)
+	}
+	return exitCode, nil
+}
+
+// forward 读取一个输出流，保存到结果并推送给订阅者
+func (r *execRun) forward(i int, stream string, reader io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
+	buffer := make([]byte, 32*1024)
+	var pending []byte // 上次读取末尾不完整的 UTF-8 字符
+	for {
+		n, err := reader.Read(buffer)
+		if n > 0 {
+			data := append(pending, buffer[:n]...)
+			cut := incompleteUTF8(data)
+			pending = append([]byte(nil), data[len(data)-cut:]...)
+			r.output(i, stream, string(data[:len(data)-cut]))
+		}
+		if err != nil {
+			if len(pending) > 0 {
+				r.output(i, stream, string(pending))
+			}
+			return
+		}
+	}
+}
+
+// output 追加输出，超出保存上限的部分丢弃
+func (r *execRun) output(i int, stream, data string) {
+	if data == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &r.job.Results[i]
+	saved := &result.Stdout
+	if stream == "stderr" {
+		saved = &result.Stderr
+	}
+	if len(*saved) >= execOutputLimit {
+		return
+	}
+	if len(*saved)+len(data) > execOutputLimit {
+		// 在字符边界截断，避免保存不完整的 UTF-8 字符
+		n := execOutputLimit - len(*saved)
+		for n > 0 && !utf8.RuneStart(data[n]) {
+			n--
+		}
+		data = data[:n] + execTruncated
+	}
+	*saved += data
+	r.publish(ExecEvent{Type: "output", HostID: result.HostID, Stream: stream, Data: data})
+}
+
+// finishHost 记录主机的执行结果
+func (r *execRun) finishHost(i int, status string, exitCode *int, message string) {
+	now := time.Now()
+	r.mu.Lock()
+	result := &r.job.Results[i]
+	result.Status = status
+	result.ExitCode = exitCode
+	result.Error = message
+	result.FinishedAt = &now
+	saved := *result
+	r.mu.Unlock()
+
+	if err := models.UpdateExecResult(r.db, &saved); err != nil {
+		log.Printf("保存主机 %s 的执行结果失败: %v", saved.HostName, err)
+	}
+	r.publishStatus(i)
+}
+
+// publishStatus 推送主机的执行状态
+func (r *execRun) publishStatus(i int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := r.job.Results[i]
+	result.Stdout, result.Stderr = "", ""
+	r.publish(ExecEvent{Type: "status", Result: &result})
+}
+
+// publish 推送事件，调用方需持有锁。订阅者接收过慢时断开
+func (r *execRun) publish(event ExecEvent) {
+	for ch := range r.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(r.subscribers, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"devops/models"
+)
+
+func TestExecServiceCheckPolicy(t *testing.T) {
+	db := newTestDB(t, &models.CommandRule{}, &models.HostGroup{}, &models.Label{}, &models.Credential{}, &models.Host{})
+
+	// 分组：生产(1) > 数据库(2)，测试(3)
+	prod := &models.HostGroup{Name: "生产"}
+	if err := models.CreateHostGroup(db, prod); err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range []*models.HostGroup{{Name: "数据库", ParentID: &prod.ID}, {Name: "测试"}} {
+		if err := models.CreateHostGroup(db, group); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := []models.CommandRule{
+		{Name: "禁止关机", Pattern: `^\s*(shutdown|reboot)\b`, Action: models.CommandRuleBlock, Enabled: true},
+		{Name: "生产环境重启服务需审批", Pattern: `^\s*systemctl\s+restart\b`, Action: models.CommandRuleApprove, Environments: []string{"prod"}, Enabled: true},
+		{Name: "生产分组禁止删库", Pattern: `drop\s+database`, Action: models.CommandRuleBlock, GroupIDs: []uint{prod.ID}, Enabled: true},
+		{Name: "指定主机禁止清空目录", Pattern: `rm\s+-rf\b`, Action: models.CommandRuleBlock, HostIDs: []uint{2}, Enabled: true},
+		{Name: "已停用", Pattern: `^\s*ls\b`, Action: models.CommandRuleBlock, Enabled: false},
+	}
+	for i := range rules {
+		if err := models.CreateCommandRule(db, &rules[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 与批量执行接口一样经由 FindHosts 加载主机，分组由数据库填充
+	for _, host := range []*models.Host{
+		{Name: "web-1", IP: "10.0.0.1", Port: 22, Username: "root", Environment: "prod"},
+		{Name: "mysql-1", IP: "10.0.0.2", Port: 22, Username: "root", GroupIDs: []uint{2}},
+		{Name: "dev-1", IP: "10.0.0.3", Port: 22, Username: "root", Environment: "dev", GroupIDs: []uint{3}},
+	} {
+		if err := models.CreateHost(db, host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hosts, err := models.FindHosts(db, models.HostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	web, mysql, dev := hosts[0], hosts[1], hosts[2]
+
+	tests := []struct {
+		name    string
+		hosts   []models.Host
+		command string
+		wantErr string
+	}{
+		{name: "没有匹配的规则", hosts: []models.Host{web, mysql, dev}, command: "uptime"},
+		{name: "对所有主机生效的禁止规则", hosts: []models.Host{dev}, command: "reboot", wantErr: "被禁止执行 (规则: 禁止关机)"},
+		{name: "多行命令中任一行匹配", hosts: []models.Host{dev}, command: "uptime\nshutdown -h now", wantErr: "禁止关机"},
+		{name: "需要审批的命令不能批量执行", hosts: []models.Host{dev, web}, command: "systemctl restart nginx", wantErr: "命令在主机 web-1 上需要审批"},
+		{name: "环境不匹配时审批规则不生效", hosts: []models.Host{dev, mysql}, command: "systemctl restart nginx"},
+		{name: "分组规则对下级分组的主机生效", hosts: []models.Host{mysql}, command: "mysql -e 'drop database app'", wantErr: "命令在主机 mysql-1 上被禁止执行"},
+		{name: "分组规则对其他分组不生效", hosts: []models.Host{dev, web}, command: "mysql -e 'drop database app'"},
+		{name: "主机规则", hosts: []models.Host{web, mysql}, command: "rm -rf /data/tmp", wantErr: "mysql-1"},
+		{name: "主机规则对其他主机不生效", hosts: []models.Host{web, dev}, command: "rm -rf /data/tmp"},
+		{name: "停用的规则不生效", hosts: []models.Host{web}, command: "ls"},
+		{name: "分号连接的命令", hosts: []models.Host{dev}, command: "true; reboot", wantErr: "禁止关机"},
+		{name: "&& 连接的命令", hosts: []models.Host{dev}, command: "uptime && shutdown -h now", wantErr: "禁止关机"},
+		{name: "|| 连接的命令", hosts: []models.Host{dev}, command: "false||reboot", wantErr: "禁止关机"},
+		{name: "管道之后的命令", hosts: []models.Host{dev}, command: "echo y | reboot", wantErr: "禁止关机"},
+		{name: "后台执行之后的命令", hosts: []models.Host{dev}, command: "sleep 1 & reboot", wantErr: "禁止关机"},
+		{name: "子 shell 中的命令", hosts: []models.Host{dev}, command: "echo $(reboot)", wantErr: "禁止关机"},
+		{name: "bash -c 中的命令", hosts: []models.Host{dev}, command: `bash -c "true;reboot"`, wantErr: "禁止关机"},
+		{name: "连接的命令需要审批", hosts: []models.Host{web}, command: "cd /srv && systemctl restart nginx", wantErr: "需要审批"},
+		{name: "参数中的关键字不匹配", hosts: []models.Host{dev}, command: "echo reboot; man shutdown"},
+	}
+	service := NewExecService(db)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.CheckPolicy(tt.hosts, tt.command)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckPolicy(%q) 返回错误: %v", tt.command, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CheckPolicy(%q) 返回 %v，期望包含 %q", tt.command, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecRunOutputLimit(t *testing.T) {
+	pad := func(n int) string { return strings.Repeat("a", n) }
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{name: "未超出上限", chunks: []string{"中文", "输出"}, want: "中文输出"},
+		{name: "恰好达到上限", chunks: []string{pad(execOutputLimit - 3), "中"}, want: pad(execOutputLimit-3) + "中"},
+		{name: "在 ASCII 处截断", chunks: []string{pad(execOutputLimit - 1), "ab"}, want: pad(execOutputLimit-1) + "a" + execTruncated},
+		{name: "不截断半个字符", chunks: []string{pad(execOutputLimit - 1), "中文"}, want: pad(execOutputLimit-1) + execTruncated},
+		{name: "保留完整的字符", chunks: []string{pad(execOutputLimit - 4), "中文"}, want: pad(execOutputLimit-4) + "中" + execTruncated},
+		{name: "超出上限后的输出被丢弃", chunks: []string{pad(execOutputLimit - 2), "中", "文"}, want: pad(execOutputLimit-2) + execTruncated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := &execRun{
+				job:         &models.ExecJob{Results: make([]models.ExecResult, 1)},
+				subscribers: make(map[chan ExecEvent]struct{}),
+			}
+			for _, chunk := range tt.chunks {
+				run.output(0, "stdout", chunk)
+			}
+			got := run.job.Results[0].Stdout
+			if !utf8.ValidString(got) {
+				t.Errorf("保存的输出不是有效的 UTF-8，结尾为 %q", got[max(len(got)-16, 0):])
+			}
+			if got != tt.want {
+				t.Errorf("保存的输出长度 %d，结尾为 %q；期望长度 %d，结尾为 %q",
+					len(got), got[max(len(got)-16, 0):], len(tt.want), tt.want[max(len(tt.want)-16, 0):])
+			}
+		})
+	}
+}
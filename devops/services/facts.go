@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"devops/models"
+)
+
+const (
+	factsTimeout     = 30 * time.Second // 单台主机采集的超时时间
+	factsParallelism = 10               // 定时采集时同时采集的主机数
+)
+
+// ErrFactsRefreshing 主机信息正在采集中
+var ErrFactsRefreshing = errors.New("主机信息正在采集中")
+
+// factsScript 采集主机信息的脚本，只使用 POSIX sh 与常见的 Linux 命令，各部分以 @@ 开头的行分隔，
+// 缺少的命令只会使对应部分为空
+const factsScript = `export LC_ALL=C
+echo @@hostname; hostname 2>/dev/null || cat /proc/sys/kernel/hostname 2>/dev/null
+echo @@os; cat /etc/os-release 2>/dev/null
+echo @@kernel; uname -r 2>/dev/null
+echo @@arch; uname -m 2>/dev/null
+echo @@cpu; nproc 2>/dev/null || grep -c ^processor /proc/cpuinfo 2>/dev/null
+echo @@memory; grep ^MemTotal: /proc/meminfo 2>/dev/null
+echo @@uptime; cat /proc/uptime 2>/dev/null
+echo @@disks; df -P -k 2>/dev/null
+echo @@links; ip -o link show 2>/dev/null
+echo @@addrs; ip -o addr show 2>/dev/null
+echo @@docker; docker version --format '{{.Server.Version}}' 2>/dev/null || docker --version 2>/dev/null
+true
+`
+
+// factsRefreshing 正在采集信息的主机
+var factsRefreshing sync.Map
+
+// FactsService 主机信息采集服务
+type FactsService struct {
+	DB *gorm.DB
+}
+
+// NewFactsService 创建主机信息采集服务实例
+func NewFactsService(db *gorm.DB) *FactsService {
+	return &FactsService{DB: db}
+}
+
+// Refresh 通过 SSH 采集主机信息并保存为新的快照版本，同一主机同时只进行一次采集
+func (s *FactsService) Refresh(ctx context.Context, host *models.Host) (*models.HostFacts, error) {
+	if _, busy := factsRefreshing.LoadOrStore(host.ID, struct{}{}); busy {
+		return nil, ErrFactsRefreshing
+	}
+	defer factsRefreshing.Delete(host.ID)
+
+	ctx, cancel := context.WithTimeout(ctx, factsTimeout)
+	defer cancel()
+
+	output, err := s.run(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	facts := parseFacts(output)
+	facts.HostID = host.ID
+	facts.CollectedAt = time.Now()
+	if err := models.CreateHostFacts(s.DB, facts); err != nil {
+		return nil, fmt.Errorf("保存主机信息失败: %v", err)
+	}
+	return facts, nil
+}
+
+// RefreshInBackground 在后台采集主机信息，失败时只记录日志
+func (s *FactsService) RefreshInBackground(hostID uint) {
+	go func() {
+		host, err := models.GetHostByID(s.DB, hostID)
+		if err != nil {
+			log.Printf("读取主机 %d 失败: %v", hostID, err)
+			return
+		}
+		if _, err := s.Refresh(context.Background(), host); err != nil {
+			log.Printf("采集主机 %s 的信息失败: %v", host.Name, err)
+		}
+	}()
+}
+
+// RefreshAll 采集全部主机的信息，返回成功与失败的主机数
+func (s *FactsService) RefreshAll(ctx context.Context) (succeeded, failed int, err error) {
+	hosts, err := models.FindHosts(s.DB, models.HostFilter{})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, factsParallelism)
+	for i := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(host *models.Host) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			_, err := s.Refresh(ctx, host)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed++
+				log.Printf("采集主机 %s 的信息失败: %v", host.Name, err)
+				return
+			}
+			succeeded++
+		}(&hosts[i])
+	}
+	wg.Wait()
+	return succeeded, failed, nil
+}
+
+// StartFactsScheduler 按 interval 定时采集全部主机的信息，interval 为 0 时不启动
+func StartFactsScheduler(db *gorm.DB, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			succeeded, failed, err := NewFactsService(db).RefreshAll(context.Background())
+			if err != nil {
+				log.Printf("定时采集主机信息失败: %v", err)
+				continue
+			}
+			log.Printf("定时采集主机信息完成: 成功 %d 台, 失败 %d 台", succeeded, failed)
+		}
+	}()
+}
+
+// run 在主机上执行采集脚本并返回标准输出
+func (s *FactsService) run(ctx context.Context, host *models.Host) (string, error) {
+	lease, err := AcquireSSH(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("SSH连接失败: %v", err)
+	}
+	defer lease.Release()
+
+	session, err := lease.Client().NewSession()
+	if err != nil {
+		return "", fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	type result struct {
+		output []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := session.Output(factsScript)
+		done <- result{output, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return "", fmt.Errorf("执行采集脚本失败: %v", r.err)
+		}
+		return string(r.output), nil
+	case <-ctx.Done():
+		session.Close()
+		return "", fmt.Errorf("采集超时: %v", ctx.Err())
+	}
+}
+
+// parseFacts 解析采集脚本的输出
+func parseFacts(output string) *models.HostFacts {
+	sections := make(map[string][]string)
+	var current string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "@@") {
+			current = line[2:]
+			continue
+		}
+		if current != "" && strings.TrimSpace(line) != "" {
+			sections[current] = append(sections[current], line)
+		}
+	}
+
+	first := func(name string) string {
+		if lines := sections[name]; len(lines) > 0 {
+			return strings.TrimSpace(lines[0])
+		}
+		return ""
+	}
+
+	facts := &models.HostFacts{
+		Hostname: first("hostname"),
+		Kernel:   first("kernel"),
+		Arch:     first("arch"),
+	}
+
+	osRelease := parseOSRelease(sections["os"])
+	facts.OS = osRelease["ID"]
+	facts.OSName = osRelease["PRETTY_NAME"]
+	facts.OSVersion = osRelease["VERSION_ID"]
+
+	facts.CPUCount, _ = strconv.Atoi(first("cpu"))
+	// MemTotal:        8048576 kB
+	if fields := strings.Fields(first("memory")); len(fields) >= 2 {
+		if kb, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			facts.MemoryTotal = kb * 1024
+		}
+	}
+	// /proc/uptime: 12345.67 23456.78
+	if fields := strings.Fields(first("uptime")); len(fields) > 0 {
+		if uptime, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			facts.Uptime = int64(uptime)
+		}
+	}
+
+	facts.Disks = parseDisks(sections["disks"])
+	facts.Interfaces = parseInterfaces(sections["links"], sections["addrs"])
+	facts.DockerVersion = parseDockerVersion(first("docker"))
+	return facts
+}
+
+// parseOSRelease 解析 /etc/os-release 的 KEY=value 行
+func parseOSRelease(lines []string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return values
+}
+
+// parseDisks 解析 df -P -k 的输出，只保留挂载块设备的文件系统
+func parseDisks(lines []string) []models.DiskFact {
+	disks := []models.DiskFact{}
+	for _, line := range lines {
+		// Filesystem 1024-blocks Used Available Capacity Mounted on
+		fields := strings.Fields(line)
+		if len(fields) < 6 || !strings.HasPrefix(fields[0], "/") {
+			continue
+		}
+		total, err1 := strconv.ParseInt(fields[1], 10, 64)
+		used, err2 := strconv.ParseInt(fields[2], 10, 64)
+		available, err3 := strconv.ParseInt(fields[3], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		disks = append(disks, models.DiskFact{
+			Device:     fields[0],
+			MountPoint: strings.Join(fields[5:], " "),
+			Total:      total * 1024,
+			Used:       used * 1024,
+			Available:  available * 1024,
+		})
+	}
+	return disks
+}
+
+// parseInterfaces 解析 ip -o link 与 ip -o addr 的输出，忽略回环接口
+func parseInterfaces(links, addrs []string) []models.InterfaceFact {
+	interfaces := []models.InterfaceFact{}
+	index := make(map[string]int)
+	find := func(name string) int {
+		if i, ok := index[name]; ok {
+			return i
+		}
+		index[name] = len(interfaces)
+		interfaces = append(interfaces, models.InterfaceFact{Name: name, Addresses: []string{}})
+		return len(interfaces) - 1
+	}
+
+	// 2: eth0@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 ... link/ether 02:42:ac:11:00:02 brd ff:ff:ff:ff:ff:ff
+	for _, line := range links {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimSuffix(fields[1], ":"), "@")
+		if name == "lo" {
+			continue
+		}
+		i := find(name)
+		for j := 2; j+1 < len(fields); j++ {
+			if fields[j] == "link/ether" {
+				interfaces[i].MAC = fields[j+1]
+				break
+			}
+		}
+	}
+
+	// 2: eth0    inet 172.17.0.2/16 brd 172.17.255.255 scope global eth0\       valid_lft forever ...
+	for _, line := range addrs {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || (fields[2] != "inet" && fields[2] != "inet6") {
+			continue
+		}
+		name, _, _ := strings.Cut(fields[1], "@")
+		if name == "lo" {
+			continue
+		}
+		i := find(name)
+		interfaces[i].Addresses = append(interfaces[i].Addresses, fields[3])
+	}
+	return interfaces
+}
+
+// parseDockerVersion 解析 docker version 的服务端版本，或 docker --version 的输出（Docker version 24.0.7, build afdd53b）
+func parseDockerVersion(line string) string {
+	if rest, ok := strings.CutPrefix(line, "Docker version "); ok {
+		version, _, _ := strings.Cut(rest, ",")
+		return strings.TrimSpace(version)
+	}
+	if strings.ContainsAny(line, " :") {
+		return ""
+	}
+	return line
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"devops/config"
+	"devops/models"
+)
+
+// HostKeyError 主机密钥校验失败
+type HostKeyError struct {
+	Host     string // 主机名称
+	Expected string // 已记录的指纹，未登记时为空
+	Actual   string // 服务器提供的指纹
+}
+
+func (e *HostKeyError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("主机 %s 的密钥未登记 (%s)，请核实后在主机密钥管理中接受", e.Host, e.Actual)
+	}
+	return fmt.Sprintf("主机 %s 的密钥已变更 (记录 %s, 实际 %s)，可能存在中间人攻击，请核实后在主机密钥管理中接受新密钥",
+		e.Host, e.Expected, e.Actual)
+}
+
+// hostKeyVerifier 按主机记录校验服务器密钥，并记录校验结果供连接结束后持久化
+type hostKeyVerifier struct {
+	host     *models.Host
+	policy   string
+	known    ssh.PublicKey
+	accepted ssh.PublicKey // 首次连接时接受的密钥
+	rejected ssh.PublicKey // 被拒绝的密钥
+	err      *HostKeyError
+}
+
+// newHostKeyVerifier 创建主机密钥校验器，已记录的密钥无法解析时返回错误
+func newHostKeyVerifier(host *models.Host) (*hostKeyVerifier, error) {
+	v := &hostKeyVerifier{host: host, policy: config.Conf.SSH.HostKeyPolicy}
+	if host.HostKey != "" {
+		key, err := ParseHostKey(host.HostKey)
+		if err != nil {
+			return nil, fmt.Errorf("主机 %s 记录的密钥无效: %v", host.Name, err)
+		}
+		v.known = key
+	}
+	return v, nil
+}
+
+// Callback 实现 ssh.HostKeyCallback
+func (v *hostKeyVerifier) Callback(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if v.known == nil {
+		if v.policy == config.HostKeyPolicyTOFU {
+			v.accepted = key
+			return nil
+		}
+		v.rejected = key
+		v.err = &HostKeyError{Host: v.host.Name, Actual: ssh.FingerprintSHA256(key)}
+		return v.err
+	}
+
+	if bytes.Equal(v.known.Marshal(), key.Marshal()) {
+		return nil
+	}
+	v.rejected = key
+	v.err = &HostKeyError{
+		Host:     v.host.Name,
+		Expected: ssh.FingerprintSHA256(v.known),
+		Actual:   ssh.FingerprintSHA256(key),
+	}
+	return v.err
+}
+
+// Algorithms 已记录密钥时只协商该密钥类型，避免服务器提供其他类型的密钥导致误报
+func (v *hostKeyVerifier) Algorithms() []string {
+	if v.known == nil {
+		return nil
+	}
+	if v.known.Type() == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{v.known.Type()}
+}
+
+// ParseHostKey 解析 authorized_keys 格式的公钥
+func ParseHostKey(text string) (ssh.PublicKey, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(text))
+	return key, err
+}
+
+// MarshalHostKey 将公钥序列化为 authorized_keys 格式（不含换行）
+func MarshalHostKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// knownHostsAddress 主机在 known_hosts 中的地址，22 端口省略端口号
+func knownHostsAddress(host *models.Host) string {
+	return knownhosts.Normalize(net.JoinHostPort(host.IP, strconv.Itoa(host.Port)))
+}
+
+// ExportKnownHosts 导出全部已记录主机密钥，格式与 OpenSSH known_hosts 相同
+func (s *SSHService) ExportKnownHosts() (string, error) {
+	hosts, err := models.GetAllHosts(s.DB)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	for _, host := range hosts {
+		if host.HostKey == "" {
+			continue
+		}
+		key, err := ParseHostKey(host.HostKey)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&buf, "# %s\n%s\n", host.Name, knownhosts.Line([]string{knownHostsAddress(&host)}, key))
+	}
+	return buf.String(), nil
+}
+
+// KnownHostsImportResult known_hosts 导入结果
+type KnownHostsImportResult struct {
+	Imported  []string `json:"imported"`  // 新记录密钥的主机
+	Unchanged []string `json:"unchanged"` // 密钥与记录一致的主机
+	Replaced  []string `json:"replaced"`  // 覆盖了原密钥的主机
+	Conflicts []string `json:"conflicts"` // 密钥不一致、已记录为待确认的主机
+	Skipped   int      `json:"skipped"`   // 无法解析、带标记或未匹配任何主机的行数
+}
+
+// knownHostsEntry known_hosts 中的一行
+type knownHostsEntry struct {
+	patterns []string
+	key      ssh.PublicKey
+	matched  bool
+}
+
+// ImportKnownHosts 按主机地址导入 known_hosts 内容。
+// 主机已有不同密钥时，overwrite 为 true 直接覆盖，否则记录为待确认密钥。
+func (s *SSHService) ImportKnownHosts(content []byte, overwrite bool) (*KnownHostsImportResult, error) {
+	result := &KnownHostsImportResult{
+		Imported:  []string{},
+		Unchanged: []string{},
+		Replaced:  []string{},
+		Conflicts: []string{},
+	}
+
+	var entries []*knownHostsEntry
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		marker, patterns, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err != nil || marker != "" {
+			result.Skipped++
+			continue
+		}
+		entries = append(entries, &knownHostsEntry{patterns: patterns, key: key})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	hosts, err := models.GetAllHosts(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, host := range hosts {
+		address := knownHostsAddress(&host)
+		var keys []ssh.PublicKey
+		for _, entry := range entries {
+			if matchKnownHosts(entry.patterns, address) {
+				entry.matched = true
+				keys = append(keys, entry.key)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		label := fmt.Sprintf("%s (%s)", host.Name, address)
+		key := keys[0]
+		if host.HostKey != "" {
+			current, err := ParseHostKey(host.HostKey)
+			if err == nil && containsKey(keys, current) {
+				result.Unchanged = append(result.Unchanged, label)
+				continue
+			}
+		}
+
+		switch {
+		case host.HostKey == "":
+			err = models.SaveHostKey(s.DB, host.ID, MarshalHostKey(key), ssh.FingerprintSHA256(key))
+			result.Imported = append(result.Imported, label)
+		case overwrite:
+			err = models.SaveHostKey(s.DB, host.ID, MarshalHostKey(key), ssh.FingerprintSHA256(key))
+			result.Replaced = append(result.Replaced, label)
+			InvalidateSSH(host.ID)
+		default:
+			err = models.SavePendingHostKey(s.DB, host.ID, MarshalHostKey(key), ssh.FingerprintSHA256(key))
+			result.Conflicts = append(result.Conflicts, label)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range entries {
+		if !entry.matched {
+			result.Skipped++
+		}
+	}
+	return result, nil
+}
+
+// matchKnownHosts 判断 known_hosts 的主机字段是否包含地址，支持明文与 |1| 哈希形式，不支持通配符
+func matchKnownHosts(patterns []string, address string) bool {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "|1|") {
+			if matchHashedHost(pattern, address) {
+				return true
+			}
+			continue
+		}
+		if pattern == address {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHashedHost 校验 |1|salt|hash 形式的哈希主机名
+func matchHashedHost(pattern, address string) bool {
+	parts := strings.Split(strings.TrimPrefix(pattern, "|1|"), "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(address))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+// containsKey 判断公钥是否在列表中
+func containsKey(keys []ssh.PublicKey, key ssh.PublicKey) bool {
+	for _, k := range keys {
+		if bytes.Equal(k.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
@@ -17,6 +17,7 @@ import (
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 
+	"devops/config"
 	"devops/models"
 	"devops/utils"
 )
@@ -29,7 +30,7 @@ type RepositoryService struct {
 
 // NewRepositoryService 创建仓库服务实例
 func NewRepositoryService(db *gorm.DB) *RepositoryService {
-	basePath := filepath.Join(os.TempDir(), "devops", "repositories")
+	basePath := config.Conf.Repository.BasePath
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		utils.Logger.Error("创建仓库目录失败", zap.Error(err))
 	}
@@ -78,7 +79,7 @@ func (s *RepositoryService) SyncRepository(repo *models.Repository) error {
 			URL: repo.URL,
 			Auth: &gitHttp.BasicAuth{
 				Username: "token",
-				Password: repo.Token,
+				Password: repo.Token.String(),
 			},
 		})
 		if err != nil {
@@ -100,7 +101,7 @@ func (s *RepositoryService) SyncRepository(repo *models.Repository) error {
 		err = w.Pull(&git.PullOptions{
 			Auth: &gitHttp.BasicAuth{
 				Username: "token",
-				Password: repo.Token,
+				Password: repo.Token.String(),
 			},
 		})
 		if err != nil && err != git.NoErrAlreadyUpToDate {
@@ -115,8 +116,10 @@ func (s *RepositoryService) SyncRepository(repo *models.Repository) error {
 	}
 
 	// 更新仓库信息
+	now := time.Now()
 	repo.DefaultBranch = head.Name().Short()
 	repo.Status = "active"
+	repo.LastSyncAt = &now
 
 	return nil
 }
@@ -128,11 +131,9 @@ func (s *RepositoryService) GetBranches(ctx context.Context, repo *models.Reposi
 		URL: repo.URL,
 		Auth: &gitHttp.BasicAuth{
 			Username: "token",
-			Password: repo.Token,
-		},
-		ProxyOptions: transport.ProxyOptions{
-			URL: "http://127.0.0.1:7890",
+			Password: repo.Token.String(),
 		},
+		ProxyOptions: proxyOptions(),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("clone repository failed: %v", err)
@@ -169,11 +170,9 @@ func (s *RepositoryService) GetCommits(ctx context.Context, repo *models.Reposit
 		URL: repo.URL,
 		Auth: &gitHttp.BasicAuth{
 			Username: "token",
-			Password: repo.Token,
-		},
-		ProxyOptions: transport.ProxyOptions{
-			URL: "http://127.0.0.1:7890",
+			Password: repo.Token.String(),
 		},
+		ProxyOptions: proxyOptions(),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("clone repository failed: %v", err)
@@ -254,3 +253,8 @@ func parseGitHubURL(url string) (owner, repo string) {
 	}
 	return "", ""
 }
+
+// proxyOptions 访问远程仓库使用的代理配置
+func proxyOptions() transport.ProxyOptions {
+	return transport.ProxyOptions{URL: config.Conf.Repository.Proxy}
+}
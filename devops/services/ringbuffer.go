@@ -0,0 +1,50 @@
+package services
+
+import "unicode/utf8"
+
+// ringBuffer 固定容量的字节环形缓冲区，写满后覆盖最早的内容
+type ringBuffer struct {
+	data   []byte
+	start  int
+	length int
+}
+
+// newRingBuffer 创建容量为 size 字节的环形缓冲区，size 为 0 时不保存任何内容
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{data: make([]byte, size)}
+}
+
+// Write 追加内容
+func (r *ringBuffer) Write(p []byte) {
+	size := len(r.data)
+	if size == 0 || len(p) == 0 {
+		return
+	}
+	if len(p) >= size {
+		copy(r.data, p[len(p)-size:])
+		r.start, r.length = 0, size
+		return
+	}
+
+	end := (r.start + r.length) % size
+	n := copy(r.data[end:], p)
+	copy(r.data, p[n:])
+	r.length += len(p)
+	if r.length > size {
+		r.start = (r.start + r.length - size) % size
+		r.length = size
+	}
+}
+
+// Bytes 按写入顺序返回缓冲区内容的副本，跳过开头被截断的 UTF-8 字符
+func (r *ringBuffer) Bytes() []byte {
+	out := make([]byte, r.length)
+	n := copy(out, r.data[r.start:min(r.start+r.length, len(r.data))])
+	copy(out[n:], r.data[:r.length-n])
+
+	skip := 0
+	for skip < len(out) && skip < utf8.UTFMax-1 && !utf8.RuneStart(out[skip]) {
+		skip++
+	}
+	return out[skip:]
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// 目录打包格式
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+// archiveBufferSize 打包时复制文件内容使用的缓冲区大小，每次打包的内存占用与目录大小无关
+const archiveBufferSize = 32 * 1024
+
+// ParseArchiveFormat 解析打包格式，空值为 zip，tgz 视同 tar.gz
+func ParseArchiveFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", ArchiveZip:
+		return ArchiveZip, nil
+	case ArchiveTarGz, "tgz":
+		return ArchiveTarGz, nil
+	default:
+		return "", fmt.Errorf("不支持的打包格式: %s，可选 zip、tar.gz", format)
+	}
+}
+
+// ArchiveContentType 打包格式对应的 Content-Type
+func ArchiveContentType(format string) string {
+	if format == ArchiveTarGz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+// archiveWriter 按条目写入归档
+type archiveWriter interface {
+	dir(name string, info os.FileInfo) error
+	file(name string, info os.FileInfo, content *contextReader) error
+	symlink(name string, info os.FileInfo, target string) error
+	Close() error
+}
+
+// WriteSftpArchive 遍历远程目录并把其中的目录、普通文件与符号链接依次以 format 格式写入 w，条目路径相对于 root。
+// 文件内容边读边写，不落盘也不整体读入内存；ctx 取消（如客户端断开）时停止。每写入一个条目调用一次 written（可为 nil）。
+// 出错时不写入归档结尾，使接收方能发现归档不完整
+func WriteSftpArchive(ctx context.Context, client *sftp.Client, root, format string, w io.Writer, written func()) error {
+	if real, err := client.RealPath(root); err == nil {
+		root = real
+	}
+
+	var archive archiveWriter
+	switch format {
+	case ArchiveZip:
+		archive = &zipArchive{writer: zip.NewWriter(w)}
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		archive = &tarArchive{gzip: gz, writer: tar.NewWriter(gz)}
+	default:
+		return fmt.Errorf("不支持的打包格式: %s", format)
+	}
+
+	buffer := make([]byte, archiveBufferSize)
+	walker := client.Walk(root)
+	for walker.Step() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := walker.Err(); err != nil {
+			return err
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), root), "/")
+		if rel == "" {
+			continue
+		}
+		info := walker.Stat()
+
+		var err error
+		switch {
+		case info.IsDir():
+			err = archive.dir(rel, info)
+		case info.Mode()&os.ModeSymlink != 0:
+			var target string
+			if target, err = client.ReadLink(walker.Path()); err == nil {
+				err = archive.symlink(rel, info, target)
+			}
+		case info.Mode().IsRegular():
+			err = writeSftpFile(ctx, client, archive, walker.Path(), rel, info, buffer)
+		default:
+			// 设备文件、管道与套接字没有可打包的内容
+		}
+		if err != nil {
+			return fmt.Errorf("打包 %s 失败: %w", path.Join(root, rel), err)
+		}
+		if written != nil {
+			written()
+		}
+	}
+	return archive.Close()
+}
+
+// writeSftpFile 打开远程文件并写入归档
+func writeSftpFile(ctx context.Context, client *sftp.Client, archive archiveWriter, remotePath, name string, info os.FileInfo, buffer []byte) error {
+	file, err := client.Open(remotePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return archive.file(name, info, &contextReader{ctx: ctx, reader: file, buffer: buffer})
+}
+
+// contextReader 在每次读取前检查 ctx，buffer 为复制内容时使用的固定缓冲区
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+	buffer []byte
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+// zipArchive zip 格式归档
+type zipArchive struct {
+	writer *zip.Writer
+}
+
+func (a *zipArchive) header(name string, info os.FileInfo) (*zip.FileHeader, error) {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	header.Name = name
+	return header, nil
+}
+
+func (a *zipArchive) dir(name string, info os.FileInfo) error {
+	header, err := a.header(name+"/", info)
+	if err != nil {
+		return err
+	}
+	header.Method = zip.Store
+	_, err = a.writer.CreateHeader(header)
+	return err
+}
+
+func (a *zipArchive) file(name string, info os.FileInfo, content *contextReader) error {
+	header, err := a.header(name, info)
+	if err != nil {
+		return err
+	}
+	header.Method = zip.Deflate
+	w, err := a.writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyBuffer(w, content, content.buffer)
+	return err
+}
+
+func (a *zipArchive) symlink(name string, info os.FileInfo, target string) error {
+	header, err := a.header(name, info)
+	if err != nil {
+		return err
+	}
+	header.Method = zip.Store
+	w, err := a.writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, target)
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.writer.Close()
+}
+
+// tarArchive tar.gz 格式归档
+type tarArchive struct {
+	gzip   *gzip.Writer
+	writer *tar.Writer
+}
+
+func (a *tarArchive) header(name string, info os.FileInfo, link string) (*tar.Header, error) {
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return nil, err
+	}
+	header.Name = name
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		header.Uid, header.Gid = int(stat.UID), int(stat.GID)
+	}
+	return header, nil
+}
+
+func (a *tarArchive) dir(name string, info os.FileInfo) error {
+	header, err := a.header(name+"/", info, "")
+	if err != nil {
+		return err
+	}
+	return a.writer.WriteHeader(header)
+}
+
+func (a *tarArchive) file(name string, info os.FileInfo, content *contextReader) error {
+	header, err := a.header(name, info, "")
+	if err != nil {
+		return err
+	}
+	if err := a.writer.WriteHeader(header); err != nil {
+		return err
+	}
+	// tar 头部已写入文件大小，读取期间文件被截断时补零，变长时只写入原大小
+	n, err := io.CopyBuffer(a.writer, io.LimitReader(content, header.Size), content.buffer)
+	if err != nil {
+		return err
+	}
+	if n < header.Size {
+		_, err = io.CopyN(a.writer, zeroReader{}, header.Size-n)
+	}
+	return err
+}
+
+func (a *tarArchive) symlink(name string, info os.FileInfo, target string) error {
+	header, err := a.header(name, info, target)
+	if err != nil {
+		return err
+	}
+	return a.writer.WriteHeader(header)
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.writer.Close(); err != nil {
+		return err
+	}
+	return a.gzip.Close()
+}
+
+// zeroReader 读出全零字节
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
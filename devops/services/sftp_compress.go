@@ -0,0 +1,230 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+
+	"devops/models"
+)
+
+// errRemoteToolMissing 主机上缺少压缩所需的命令
+var errRemoteToolMissing = errors.New("主机上缺少压缩命令")
+
+// CompressOutput 目录压缩后生成的文件路径：与目录同级，名称为目录名加扩展名
+func CompressOutput(dir, format string) string {
+	dir = path.Clean(dir)
+	return path.Join(path.Dir(dir), path.Base(dir)+"."+format)
+}
+
+// StartCompressTask 在后台压缩主机上的目录，压缩文件与目录同级，已存在时覆盖。
+// 优先在主机上执行 tar/zip，主机缺少对应命令时经由平台读取目录并写回压缩文件
+func StartCompressTask(host *models.Host, userID uint, username, dir, format string) Task {
+	task := Task{
+		Type:     TaskCompress,
+		HostID:   host.ID,
+		UserID:   userID,
+		Username: username,
+		Target:   dir,
+	}
+	return StartTask(task, func(ctx context.Context, progress *TaskProgress) (string, error) {
+		lease, err := AcquireSSH(ctx, host)
+		if err != nil {
+			return "", fmt.Errorf("SSH连接失败: %v", err)
+		}
+		defer lease.Release()
+
+		output := CompressOutput(dir, format)
+		// 先写入临时文件，成功后再替换，失败或取消时不会留下不完整的压缩文件
+		temp := fmt.Sprintf("%s.%d.tmp", output, progress.TaskID())
+
+		progress.SetMessage("在主机上执行压缩")
+		err = compressOnHost(ctx, lease.Client(), dir, temp, output, format, progress)
+		if errors.Is(err, errRemoteToolMissing) {
+			progress.SetMessage("主机缺少压缩命令，经由平台压缩")
+			err = compressViaSftp(ctx, lease, dir, temp, output, format, progress)
+		}
+		if err != nil {
+			if client, sftpErr := lease.SFTP(); sftpErr == nil {
+				client.Remove(temp)
+			}
+			return "", err
+		}
+		return output, nil
+	})
+}
+
+// compressScript 在主机上压缩目录的脚本：缺少命令时以 127 退出；先输出条目总数，再以 verbose 模式压缩，每处理一个条目输出一行
+func compressScript(dir, temp, output, format string) string {
+	tools, command := "zip", "zip -r -y "+shellQuote(temp)+" ."
+	if format == ArchiveTarGz {
+		tools, command = "tar gzip", "tar -czvf "+shellQuote(temp)+" ."
+	}
+	return fmt.Sprintf(`cd %s || exit 2
+for tool in %s; do command -v $tool >/dev/null 2>&1 || exit 127; done
+echo "@@total $(find . | wc -l)"
+%s && mv -f %s %s`, shellQuote(dir), tools, command, shellQuote(temp), shellQuote(output))
+}
+
+// compressOnHost 通过 SSH 在主机上执行压缩，按输出的行数更新进度；主机缺少命令时返回 errRemoteToolMissing
+func compressOnHost(ctx context.Context, client *ssh.Client, dir, temp, output, format string, progress *TaskProgress) error {
+	var lastError string
+	status, err := runHostScript(ctx, client, compressScript(dir, temp, output, format),
+		func(line string) {
+			if total, ok := strings.CutPrefix(line, "@@total "); ok {
+				if n, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64); err == nil {
+					progress.SetTotal(n)
+				}
+				return
+			}
+			progress.Add(1)
+		},
+		func(line string) {
+			// bsdtar 的 verbose 输出写入标准错误，以 "a " 开头
+			if strings.HasPrefix(line, "a ") {
+				progress.Add(1)
+				return
+			}
+			lastError = line
+		})
+	switch {
+	case err != nil:
+		return err
+	case status == 0:
+		return nil
+	case status == 127:
+		return errRemoteToolMissing
+	default:
+		return hostScriptError("压缩失败", status, lastError)
+	}
+}
+
+// runHostScript 通过 SSH 在主机上执行脚本，逐行回调标准输出与标准错误，返回退出码。
+// ctx 取消时结束远程进程并返回 ctx 的错误
+func runHostScript(ctx context.Context, client *ssh.Client, script string, stdoutLine, stderrLine func(line string)) (int, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return 0, fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return 0, fmt.Errorf("获取标准输出失败: %v", err)
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		return 0, fmt.Errorf("获取标准错误失败: %v", err)
+	}
+	if err := session.Start(script); err != nil {
+		return 0, fmt.Errorf("执行命令失败: %v", err)
+	}
+
+	var reading sync.WaitGroup
+	reading.Add(2)
+	go func() {
+		defer reading.Done()
+		scanLines(stdout, stdoutLine)
+	}()
+	go func() {
+		defer reading.Done()
+		scanLines(stderr, stderrLine)
+	}()
+
+	wait := make(chan error, 1)
+	go func() { wait <- session.Wait() }()
+
+	select {
+	case err = <-wait:
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		<-wait
+		reading.Wait()
+		return 0, ctx.Err()
+	}
+	reading.Wait()
+
+	status := SessionExitStatus(err)
+	if status == nil {
+		return 0, fmt.Errorf("执行命令失败: %v", err)
+	}
+	return *status, nil
+}
+
+// hostScriptError 主机上的命令以非零状态退出时的错误，附带标准错误的最后一行
+func hostScriptError(action string, status int, lastError string) error {
+	if lastError != "" {
+		return fmt.Errorf("%s（退出码 %d）: %s", action, status, lastError)
+	}
+	return fmt.Errorf("%s（退出码 %d）", action, status)
+}
+
+// compressViaSftp 经由 SFTP 读取目录，在平台上打包并写回主机
+func compressViaSftp(ctx context.Context, lease *SSHLease, dir, temp, output, format string, progress *TaskProgress) error {
+	client, err := lease.SFTP()
+	if err != nil {
+		return fmt.Errorf("SFTP连接失败: %v", err)
+	}
+
+	var total int64
+	for walker := client.Walk(dir); walker.Step(); {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if walker.Err() == nil {
+			total++
+		}
+	}
+	progress.SetTotal(total)
+	progress.Add(1) // 目录自身
+
+	file, err := client.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("创建压缩文件失败: %v", err)
+	}
+	writer := bufio.NewWriterSize(file, 256*1024)
+	err = WriteSftpArchive(ctx, client, dir, format, writer, func() { progress.Add(1) })
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := client.PosixRename(temp, output); err != nil {
+		client.Remove(output)
+		if err := client.Rename(temp, output); err != nil {
+			return fmt.Errorf("重命名压缩文件失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// scanLines 逐行读取 reader，读取结束后才返回
+func scanLines(reader io.Reader, fn func(line string)) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+	// 行过长等读取错误时丢弃剩余内容，避免远程进程因输出阻塞
+	io.Copy(io.Discard, reader)
+}
+
+// shellQuote 将参数用单引号括起，用于拼接远程 shell 命令
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,524 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"devops/models"
+)
+
+// ArchiveTar 未压缩的 tar 文件，只用于解压
+const ArchiveTar = "tar"
+
+// 解压时目标文件已存在的处理方式
+const (
+	ExtractSkip      = "skip"      // 保留已有文件
+	ExtractOverwrite = "overwrite" // 覆盖已有文件
+)
+
+// ExtractFormat 根据文件名判断压缩文件格式，支持 .zip、.tar、.tar.gz 与 .tgz
+func ExtractFormat(name string) (string, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return ArchiveTar, nil
+	default:
+		return "", fmt.Errorf("不支持的压缩文件格式: %s，可选 .zip、.tar、.tar.gz、.tgz", path.Base(name))
+	}
+}
+
+// ParseExtractPolicy 解析已存在文件的处理方式，空值为 skip
+func ParseExtractPolicy(policy string) (string, error) {
+	switch policy {
+	case "", ExtractSkip:
+		return ExtractSkip, nil
+	case ExtractOverwrite:
+		return ExtractOverwrite, nil
+	default:
+		return "", fmt.Errorf("不支持的处理方式: %s，可选 skip、overwrite", policy)
+	}
+}
+
+// archiveEntryPath 校验压缩文件中的条目路径，拒绝绝对路径以及包含 .. 而会写到解压目录之外的路径（zip-slip）
+func archiveEntryPath(name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("压缩文件包含不安全的路径: %s", name)
+	}
+	return clean, nil
+}
+
+// StartExtractTask 在后台把压缩文件解压到所在目录，policy 指定目标文件已存在时跳过还是覆盖。
+// 优先在主机上执行 unzip/tar，主机缺少对应命令时经由平台读取压缩文件并逐个写回条目。
+// 写入任何文件前先检查全部条目路径，包含不安全路径的压缩文件整体拒绝
+func StartExtractTask(host *models.Host, userID uint, username, archive, format, policy string) Task {
+	task := Task{
+		Type:     TaskExtract,
+		HostID:   host.ID,
+		UserID:   userID,
+		Username: username,
+		Target:   archive,
+	}
+	return StartTask(task, func(ctx context.Context, progress *TaskProgress) (string, error) {
+		lease, err := AcquireSSH(ctx, host)
+		if err != nil {
+			return "", fmt.Errorf("SSH连接失败: %v", err)
+		}
+		defer lease.Release()
+
+		dest := path.Dir(path.Clean(archive))
+		progress.SetMessage("在主机上执行解压")
+		err = extractOnHost(ctx, lease.Client(), archive, dest, format, policy, progress)
+		if errors.Is(err, errRemoteToolMissing) {
+			progress.SetMessage("主机缺少解压命令，经由平台解压")
+			err = extractViaSftp(ctx, lease, archive, dest, format, policy, progress)
+		}
+		if err != nil {
+			return "", err
+		}
+		return dest, nil
+	})
+}
+
+// unzipEnv 执行 unzip 时的环境变量。unzip 按当前 locale 转换文件名，C locale 下非 ASCII 的名称会被转义为 #Uxxxx
+const unzipEnv = "LC_ALL=C.UTF-8 "
+
+// extractTools 解压所需的命令
+func extractTools(format string) string {
+	switch format {
+	case ArchiveZip:
+		return "unzip"
+	case ArchiveTarGz:
+		return "tar gzip"
+	default:
+		return "tar"
+	}
+}
+
+// extractListScript 检查解压命令并列出压缩文件中的条目，缺少命令时以 127 退出
+func extractListScript(archive, format string) string {
+	list := unzipEnv + "unzip -Z1 " + shellQuote(archive)
+	switch format {
+	case ArchiveTarGz:
+		list = "tar -tzf " + shellQuote(archive)
+	case ArchiveTar:
+		list = "tar -tf " + shellQuote(archive)
+	}
+	return fmt.Sprintf(`for tool in %s; do command -v $tool >/dev/null 2>&1 || exit 127; done
+%s`, extractTools(format), list)
+}
+
+// extractScript 在主机上解压的脚本，以 verbose 模式执行，每解压一个条目输出一行
+func extractScript(archive, dest, format, policy string) string {
+	var command string
+	switch format {
+	case ArchiveZip:
+		option := "-n"
+		if policy == ExtractOverwrite {
+			option = "-o"
+		}
+		command = fmt.Sprintf("%sunzip %s %s", unzipEnv, option, shellQuote(archive))
+	default:
+		option := "-xvf"
+		if format == ArchiveTarGz {
+			option = "-xzvf"
+		}
+		command = fmt.Sprintf("tar %s %s --no-same-owner", option, shellQuote(archive))
+		if policy == ExtractSkip {
+			command += " --skip-old-files"
+		}
+	}
+	return fmt.Sprintf("cd %s || exit 2\n%s", shellQuote(dest), command)
+}
+
+// extractOnHost 通过 SSH 在主机上列出并检查条目后解压，按输出的行数更新进度；主机缺少命令时返回 errRemoteToolMissing
+func extractOnHost(ctx context.Context, client *ssh.Client, archive, dest, format, policy string, progress *TaskProgress) error {
+	var total int64
+	var unsafe error
+	var lastError string
+	status, err := runHostScript(ctx, client, extractListScript(archive, format),
+		func(line string) {
+			total++
+			if _, err := archiveEntryPath(line); err != nil && unsafe == nil {
+				unsafe = err
+			}
+		},
+		func(line string) { lastError = line })
+	switch {
+	case err != nil:
+		return err
+	case status == 127:
+		return errRemoteToolMissing
+	case status != 0:
+		return hostScriptError("读取压缩文件失败", status, lastError)
+	case unsafe != nil:
+		return unsafe
+	}
+	progress.SetTotal(total)
+
+	lastError = ""
+	status, err = runHostScript(ctx, client, extractScript(archive, dest, format, policy),
+		func(line string) {
+			// unzip 输出的首行为压缩文件名
+			if !strings.HasPrefix(line, "Archive:") {
+				progress.Add(1)
+			}
+		},
+		func(line string) { lastError = line })
+	switch {
+	case err != nil:
+		return err
+	// unzip 的退出码 1 表示有警告但已全部处理
+	case status == 0, format == ArchiveZip && status == 1:
+		return nil
+	default:
+		return hostScriptError("解压失败", status, lastError)
+	}
+}
+
+// archiveEntry 压缩文件中的一个条目
+type archiveEntry struct {
+	name     string
+	mode     os.FileMode
+	modTime  time.Time
+	linkname string // 符号链接的目标，或硬链接指向的条目
+	hardlink bool
+	open     func() (io.Reader, error)
+}
+
+// extractViaSftp 经由 SFTP 读取压缩文件，在平台上解压并把条目逐个写回主机
+func extractViaSftp(ctx context.Context, lease *SSHLease, archive, dest, format, policy string, progress *TaskProgress) error {
+	client, err := lease.SFTP()
+	if err != nil {
+		return fmt.Errorf("SFTP连接失败: %v", err)
+	}
+	file, err := client.Open(archive)
+	if err != nil {
+		return fmt.Errorf("打开压缩文件失败: %v", err)
+	}
+	defer file.Close()
+
+	extractor := &sftpExtractor{
+		ctx:       ctx,
+		client:    client,
+		dest:      dest,
+		overwrite: policy == ExtractOverwrite,
+		checked:   make(map[string]bool),
+		buffer:    make([]byte, archiveBufferSize),
+	}
+
+	var each func(fn func(entry archiveEntry) error) error
+	if format == ArchiveZip {
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("读取压缩文件失败: %v", err)
+		}
+		reader, err := zip.NewReader(file, info.Size())
+		if err != nil {
+			return fmt.Errorf("读取压缩文件失败: %v", err)
+		}
+		each = func(fn func(entry archiveEntry) error) error { return eachZipEntry(reader, fn) }
+	} else {
+		each = func(fn func(entry archiveEntry) error) error { return eachTarEntry(file, format, fn) }
+	}
+
+	// 先检查全部条目，tar 文件没有目录区，需要完整读取一遍
+	var total int64
+	err = each(func(entry archiveEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		total++
+		if _, err := archiveEntryPath(entry.name); err != nil {
+			return err
+		}
+		if entry.hardlink {
+			_, err := archiveEntryPath(entry.linkname)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	progress.SetTotal(total)
+
+	err = each(func(entry archiveEntry) error {
+		if err := extractor.extract(entry); err != nil {
+			return fmt.Errorf("解压 %s 失败: %w", entry.name, err)
+		}
+		progress.Add(1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return extractor.finish()
+}
+
+// eachZipEntry 依次处理 zip 文件中的条目
+func eachZipEntry(reader *zip.Reader, fn func(entry archiveEntry) error) error {
+	for _, f := range reader.File {
+		entry := archiveEntry{
+			name:    f.Name,
+			mode:    f.Mode(),
+			modTime: f.Modified,
+			open:    func() (io.Reader, error) { return f.Open() },
+		}
+		if entry.mode&os.ModeSymlink != 0 {
+			// zip 中符号链接的内容为链接目标
+			content, err := f.Open()
+			if err != nil {
+				return err
+			}
+			target, err := io.ReadAll(io.LimitReader(content, 4096))
+			content.Close()
+			if err != nil {
+				return err
+			}
+			entry.linkname = string(target)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachTarEntry 从头读取 tar 文件并依次处理其中的条目
+func eachTarEntry(file *sftp.File, format string, fn func(entry archiveEntry) error) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var reader io.Reader = bufio.NewReaderSize(file, 256*1024)
+	if format == ArchiveTarGz {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("读取压缩文件失败: %v", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取压缩文件失败: %v", err)
+		}
+		entry := archiveEntry{
+			name:     header.Name,
+			mode:     header.FileInfo().Mode(),
+			modTime:  header.ModTime,
+			linkname: header.Linkname,
+			hardlink: header.Typeflag == tar.TypeLink,
+			open:     func() (io.Reader, error) { return tr, nil },
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// sftpExtractor 把条目写入主机上的解压目录。写入前逐级检查目标路径的上级目录，
+// 不经过符号链接写入，避免压缩文件先创建指向目录外的链接再通过它写文件
+type sftpExtractor struct {
+	ctx       context.Context
+	client    *sftp.Client
+	dest      string
+	overwrite bool
+	checked   map[string]bool // 已确认为目录（非符号链接）的路径
+	dirs      []archiveEntry  // 目录权限在全部条目写入后设置，避免只读目录阻止写入其中的文件
+	buffer    []byte
+}
+
+// extract 写入一个条目，目标已存在且策略为跳过时不做修改
+func (e *sftpExtractor) extract(entry archiveEntry) error {
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+	name, err := archiveEntryPath(entry.name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return nil
+	}
+	target := path.Join(e.dest, name)
+	if err := e.mkdirParents(path.Dir(target)); err != nil {
+		return err
+	}
+
+	existing, err := e.client.Lstat(target)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	exists := err == nil
+
+	if entry.mode.IsDir() {
+		switch {
+		case exists && existing.IsDir():
+		case exists && !e.overwrite:
+			return nil
+		default:
+			if exists {
+				if err := e.client.Remove(target); err != nil {
+					return err
+				}
+			}
+			if err := e.client.Mkdir(target); err != nil {
+				return err
+			}
+		}
+		e.checked[target] = true
+		e.dirs = append(e.dirs, archiveEntry{name: target, mode: entry.mode, modTime: entry.modTime})
+		return nil
+	}
+
+	if exists {
+		if !e.overwrite {
+			return nil
+		}
+		// 先删除再创建，不写入已有的符号链接或硬链接指向的文件
+		if err := e.client.Remove(target); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case entry.mode&os.ModeSymlink != 0:
+		return e.client.Symlink(entry.linkname, target)
+	case entry.hardlink:
+		source, err := e.linkSource(entry.linkname)
+		if err != nil {
+			return err
+		}
+		return e.client.Link(source, target)
+	case entry.mode.IsRegular():
+		return e.writeFile(target, entry)
+	default:
+		// 设备文件与管道无法通过 SFTP 创建
+		return nil
+	}
+}
+
+// writeFile 写入普通文件并设置权限与修改时间
+func (e *sftpExtractor) writeFile(target string, entry archiveEntry) error {
+	content, err := entry.open()
+	if err != nil {
+		return err
+	}
+	if closer, ok := content.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	file, err := e.client.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriterSize(file, 256*1024)
+	_, err = io.CopyBuffer(writer, &contextReader{ctx: e.ctx, reader: content}, e.buffer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if perm := entry.mode.Perm(); perm != 0 {
+		if err := e.client.Chmod(target, perm); err != nil {
+			return err
+		}
+	}
+	if !entry.modTime.IsZero() {
+		return e.client.Chtimes(target, entry.modTime, entry.modTime)
+	}
+	return nil
+}
+
+// linkSource 硬链接指向的文件，须为解压目录下已有的普通文件且不经过符号链接
+func (e *sftpExtractor) linkSource(linkname string) (string, error) {
+	name, err := archiveEntryPath(linkname)
+	if err != nil {
+		return "", err
+	}
+	source := path.Join(e.dest, name)
+	if err := e.mkdirParents(path.Dir(source)); err != nil {
+		return "", err
+	}
+	info, err := e.client.Lstat(source)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("硬链接指向的 %s 不是普通文件", linkname)
+	}
+	return source, nil
+}
+
+// mkdirParents 逐级确认 dir 位于解压目录下的各级路径均为目录，不存在时创建，遇到符号链接时拒绝
+func (e *sftpExtractor) mkdirParents(dir string) error {
+	if dir == e.dest || e.checked[dir] {
+		return nil
+	}
+	if err := e.mkdirParents(path.Dir(dir)); err != nil {
+		return err
+	}
+
+	info, err := e.client.Lstat(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := e.client.Mkdir(dir); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink != 0:
+		return fmt.Errorf("路径 %s 是符号链接，拒绝经由它写入", dir)
+	case !info.IsDir():
+		return fmt.Errorf("路径 %s 不是目录", dir)
+	}
+	e.checked[dir] = true
+	return nil
+}
+
+// finish 设置目录的权限与修改时间，从最深的目录开始，避免写入子目录时改变上级目录的修改时间
+func (e *sftpExtractor) finish() error {
+	for i := len(e.dirs) - 1; i >= 0; i-- {
+		dir := e.dirs[i]
+		if perm := dir.mode.Perm(); perm != 0 {
+			if err := e.client.Chmod(dir.name, perm); err != nil {
+				return err
+			}
+		}
+		if !dir.modTime.IsZero() {
+			if err := e.client.Chtimes(dir.name, dir.modTime, dir.modTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package services
+
+import "testing"
+
+func TestArchiveEntryPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		want    string
+		wantErr bool
+	}{
+		{name: "普通文件", entry: "a.txt", want: "a.txt"},
+		{name: "子目录", entry: "dir/sub/a.txt", want: "dir/sub/a.txt"},
+		{name: "目录条目", entry: "dir/", want: "dir"},
+		{name: "当前目录前缀", entry: "./dir/a.txt", want: "dir/a.txt"},
+		{name: "目录内的上级引用", entry: "dir/../a.txt", want: "a.txt"},
+		{name: "以 .. 开头的文件名", entry: "..a.txt", want: "..a.txt"},
+		{name: "绝对路径", entry: "/etc/passwd", wantErr: true},
+		{name: "上级目录", entry: "..", wantErr: true},
+		{name: "跳出目录", entry: "../a.txt", wantErr: true},
+		{name: "多级跳出目录", entry: "dir/../../a.txt", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := archiveEntryPath(tt.entry)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("archiveEntryPath(%q) = %q，期望返回错误", tt.entry, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("archiveEntryPath(%q) 返回错误: %v", tt.entry, err)
+			}
+			if got != tt.want {
+				t.Errorf("archiveEntryPath(%q) = %q，期望 %q", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
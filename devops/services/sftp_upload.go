@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"devops/models"
+)
+
+var (
+	// ErrUploadChunkIncomplete 分片内容未完整写入，已写入的部分仍会记录
+	ErrUploadChunkIncomplete = errors.New("分片内容不完整")
+	// ErrUploadIncomplete 完成上传时仍有未上传的区间
+	ErrUploadIncomplete = errors.New("文件尚未上传完整")
+	// ErrUploadChecksumRequired 完成上传时没有期望的校验值
+	ErrUploadChecksumRequired = errors.New("缺少文件的 SHA-256 校验值")
+	// ErrUploadChecksumMismatch 文件内容与校验值不一致，已清空上传进度
+	ErrUploadChecksumMismatch = errors.New("文件校验失败，请重新上传")
+	// ErrUploadGone 上传已完成或已取消
+	ErrUploadGone = errors.New("上传已完成或已取消")
+)
+
+// sftpUploadLocks 每个上传记录的读写锁：写入分片时持有读锁，可以并发写入；
+// 记录进度、完成与取消时持有写锁，校验与重命名期间不会有分片写入临时文件。
+// 锁按引用计数在最后一个使用者释放后删除，不会删除仍有协程等待的锁
+var sftpUploadLocks = struct {
+	sync.Mutex
+	locks map[uint]*sftpUploadLock
+}{locks: make(map[uint]*sftpUploadLock)}
+
+type sftpUploadLock struct {
+	sync.RWMutex
+	refs int // 持有或等待该锁的协程数，由 sftpUploadLocks 保护
+}
+
+// lockSftpUpload 获取上传的写锁，返回释放函数
+func lockSftpUpload(id uint) func() {
+	lock := refSftpUploadLock(id)
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		unrefSftpUploadLock(id, lock)
+	}
+}
+
+// rlockSftpUpload 获取上传的读锁，返回释放函数
+func rlockSftpUpload(id uint) func() {
+	lock := refSftpUploadLock(id)
+	lock.RLock()
+	return func() {
+		lock.RUnlock()
+		unrefSftpUploadLock(id, lock)
+	}
+}
+
+func refSftpUploadLock(id uint) *sftpUploadLock {
+	sftpUploadLocks.Lock()
+	defer sftpUploadLocks.Unlock()
+	lock, ok := sftpUploadLocks.locks[id]
+	if !ok {
+		lock = &sftpUploadLock{}
+		sftpUploadLocks.locks[id] = lock
+	}
+	lock.refs++
+	return lock
+}
+
+func unrefSftpUploadLock(id uint, lock *sftpUploadLock) {
+	sftpUploadLocks.Lock()
+	defer sftpUploadLocks.Unlock()
+	lock.refs--
+	if lock.refs == 0 {
+		delete(sftpUploadLocks.locks, id)
+	}
+}
+
+// SftpUploadService 分片上传服务
+type SftpUploadService struct {
+	DB *gorm.DB
+}
+
+// NewSftpUploadService 创建分片上传服务实例
+func NewSftpUploadService(db *gorm.DB) *SftpUploadService {
+	return &SftpUploadService{DB: db}
+}
+
+// Init 开始上传 dir 下的 filename。同一用户已有上传到同一路径、大小与校验值相同的未完成上传时返回该上传以便续传，
+// 否则在目标目录创建临时文件并登记新的上传
+func (s *SftpUploadService) Init(lease *SSHLease, hostID, userID uint, dir, filename string, size int64, checksum string) (*models.SftpUpload, error) {
+	client, err := lease.SFTP()
+	if err != nil {
+		return nil, fmt.Errorf("SFTP连接失败: %v", err)
+	}
+	target := path.Join(dir, filename)
+
+	upload, err := models.FindSftpUpload(s.DB, hostID, userID, target, size, checksum)
+	if err == nil {
+		upload, err = s.resume(lease, upload)
+		if !errors.Is(err, ErrUploadGone) {
+			return upload, err
+		}
+		// 查找之后上传已完成或已取消，重新开始
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	upload = &models.SftpUpload{
+		HostID:   hostID,
+		UserID:   userID,
+		Path:     target,
+		TempPath: path.Join(dir, fmt.Sprintf(".%s.%s.part", filename, hex.EncodeToString(suffix))),
+		Size:     size,
+		SHA256:   checksum,
+		Received: []models.ByteRange{},
+	}
+	if err := createEmptyFile(lease, upload.TempPath); err != nil {
+		return nil, err
+	}
+	if err := models.CreateSftpUpload(s.DB, upload); err != nil {
+		client.Remove(upload.TempPath)
+		return nil, err
+	}
+	return upload, nil
+}
+
+// getSftpUpload 重新读取上传记录，记录已删除时返回 ErrUploadGone
+func (s *SftpUploadService) getSftpUpload(upload *models.SftpUpload) (*models.SftpUpload, error) {
+	current, err := models.GetSftpUpload(s.DB, upload.HostID, upload.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUploadGone
+	}
+	if err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// resume 续传已有的上传，临时文件被删除时从头上传
+func (s *SftpUploadService) resume(lease *SSHLease, upload *models.SftpUpload) (*models.SftpUpload, error) {
+	unlock := lockSftpUpload(upload.ID)
+	defer unlock()
+
+	upload, err := s.getSftpUpload(upload)
+	if err != nil {
+		return nil, err
+	}
+	client, err := lease.SFTP()
+	if err != nil {
+		return nil, fmt.Errorf("SFTP连接失败: %v", err)
+	}
+	if _, err := client.Stat(upload.TempPath); errors.Is(err, os.ErrNotExist) {
+		if err := createEmptyFile(lease, upload.TempPath); err != nil {
+			return nil, err
+		}
+		upload.ResetRanges()
+		if err := models.SaveSftpUploadProgress(s.DB, upload); err != nil {
+			return nil, err
+		}
+	}
+	return upload, nil
+}
+
+// createEmptyFile 在主机上创建空文件
+func createEmptyFile(lease *SSHLease, name string) error {
+	client, err := lease.SFTP()
+	if err != nil {
+		return fmt.Errorf("SFTP连接失败: %v", err)
+	}
+	file, err := client.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	return file.Close()
+}
+
+// WriteChunk 把 content 的 length 字节写入临时文件的 offset 处并记录进度。
+// 连接中断等原因导致只写入一部分时，已写入的部分同样记录，续传时只需补传其余部分；
+// 上传已完成或已取消时返回 ErrUploadGone
+func (s *SftpUploadService) WriteChunk(lease *SSHLease, upload *models.SftpUpload, offset, length int64, content io.Reader) (*models.SftpUpload, error) {
+	end, writeErr, err := s.writeChunk(lease, upload, offset, length, content)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := lockSftpUpload(upload.ID)
+	defer unlock()
+	// 重新读取，合并并发写入的其他分片的进度
+	current, err := s.getSftpUpload(upload)
+	if err != nil {
+		return nil, err
+	}
+	current.AddRange(offset, end)
+	if err := models.SaveSftpUploadProgress(s.DB, current); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case writeErr != nil:
+		return current, fmt.Errorf("写入分片失败: %v", writeErr)
+	case end-offset < length:
+		return current, ErrUploadChunkIncomplete
+	}
+	return current, nil
+}
+
+// writeChunk 持有读锁写入分片，返回写入结束的偏移。写入出错时 writeErr 非空，已写入的部分仍需记录
+func (s *SftpUploadService) writeChunk(lease *SSHLease, upload *models.SftpUpload, offset, length int64, content io.Reader) (end int64, writeErr, err error) {
+	unlock := rlockSftpUpload(upload.ID)
+	defer unlock()
+	// 确认上传仍未完成，避免写入已重命名为最终文件的临时文件
+	if _, err := s.getSftpUpload(upload); err != nil {
+		return 0, nil, err
+	}
+
+	client, err := lease.SFTP()
+	if err != nil {
+		return 0, nil, fmt.Errorf("SFTP连接失败: %v", err)
+	}
+	file, err := client.OpenFile(upload.TempPath, os.O_WRONLY)
+	if err != nil {
+		return 0, nil, fmt.Errorf("打开临时文件失败: %v", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return 0, nil, err
+	}
+	// 连接池的 SFTP 客户端按顺序写入，出错时文件偏移停在最后写入成功的位置
+	_, writeErr = file.ReadFrom(io.LimitReader(content, length))
+	end, err = file.Seek(0, io.SeekCurrent)
+	if closeErr := file.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	return end, writeErr, err
+}
+
+// Complete 校验临时文件的 SHA-256 后重命名为最终文件，已存在时覆盖。checksum 为空时使用开始上传时提供的值。
+// 校验不一致时清空上传进度，需要重新上传全部内容
+func (s *SftpUploadService) Complete(ctx context.Context, lease *SSHLease, upload *models.SftpUpload, checksum string) (*models.SftpUpload, error) {
+	unlock := lockSftpUpload(upload.ID)
+	defer unlock()
+
+	upload, err := s.getSftpUpload(upload)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != "" {
+		upload.SHA256 = checksum
+	}
+	if upload.SHA256 == "" {
+		return upload, ErrUploadChecksumRequired
+	}
+	if len(upload.Missing()) > 0 {
+		return upload, ErrUploadIncomplete
+	}
+
+	client, err := lease.SFTP()
+	if err != nil {
+		return nil, fmt.Errorf("SFTP连接失败: %v", err)
+	}
+	// 临时文件只会在 [0, Size) 内写入，此处仅防止被外部追加内容
+	if err := client.Truncate(upload.TempPath, upload.Size); err != nil {
+		return nil, fmt.Errorf("截断临时文件失败: %v", err)
+	}
+	sum, err := remoteSHA256(ctx, lease, upload.TempPath)
+	if err != nil {
+		return nil, err
+	}
+	if sum != upload.SHA256 {
+		upload.ResetRanges()
+		if err := models.SaveSftpUploadProgress(s.DB, upload); err != nil {
+			return nil, err
+		}
+		return upload, ErrUploadChecksumMismatch
+	}
+
+	if err := client.PosixRename(upload.TempPath, upload.Path); err != nil {
+		client.Remove(upload.Path)
+		if err := client.Rename(upload.TempPath, upload.Path); err != nil {
+			return nil, fmt.Errorf("重命名文件失败: %v", err)
+		}
+	}
+	if err := models.DeleteSftpUpload(s.DB, upload.ID); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Abort 放弃上传，删除临时文件与上传记录
+func (s *SftpUploadService) Abort(lease *SSHLease, upload *models.SftpUpload) error {
+	unlock := lockSftpUpload(upload.ID)
+	defer unlock()
+
+	if _, err := s.getSftpUpload(upload); err != nil {
+		return err
+	}
+	client, err := lease.SFTP()
+	if err != nil {
+		return fmt.Errorf("SFTP连接失败: %v", err)
+	}
+	if err := client.Remove(upload.TempPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("删除临时文件失败: %v", err)
+	}
+	return models.DeleteSftpUpload(s.DB, upload.ID)
+}
+
+// remoteSHA256 计算主机上文件的 SHA-256。优先在主机上执行 sha256sum，主机缺少该命令时经由 SFTP 读取文件计算
+func remoteSHA256(ctx context.Context, lease *SSHLease, name string) (string, error) {
+	script := fmt.Sprintf("command -v sha256sum >/dev/null 2>&1 || exit 127\nsha256sum < %s", shellQuote(name))
+	var sum, lastError string
+	status, err := runHostScript(ctx, lease.Client(), script,
+		func(line string) {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				sum = fields[0]
+			}
+		},
+		func(line string) { lastError = line })
+	switch {
+	case err != nil:
+		return "", err
+	case status == 0:
+		return sum, nil
+	case status != 127:
+		return "", hostScriptError("计算校验值失败", status, lastError)
+	}
+
+	client, err := lease.SFTP()
+	if err != nil {
+		return "", fmt.Errorf("SFTP连接失败: %v", err)
+	}
+	file, err := client.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("打开临时文件失败: %v", err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := file.WriteTo(&contextWriter{ctx: ctx, writer: hash}); err != nil {
+		return "", fmt.Errorf("计算校验值失败: %v", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// contextWriter 在每次写入前检查 ctx
+type contextWriter struct {
+	ctx    context.Context
+	writer io.Writer
+}
+
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.writer.Write(p)
+}
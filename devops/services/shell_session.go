@@ -0,0 +1,262 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+
+	"devops/config"
+	"devops/models"
+)
+
+// replayPollInterval 回放进行中的会话时检查新输出的间隔
+const replayPollInterval = 500 * time.Millisecond
+
+// ShellSessionService WebShell 会话记录与录像服务
+type ShellSessionService struct {
+	DB *gorm.DB
+}
+
+// NewShellSessionService 创建会话服务实例
+func NewShellSessionService(db *gorm.DB) *ShellSessionService {
+	return &ShellSessionService{DB: db}
+}
+
+// ShellUser 在会话中输入的用户，共享会话中用于区分各用户的输入并记录命令的执行者
+type ShellUser struct {
+	ID   uint
+	Name string
+}
+
+// ShellRecording 进行中的会话录像，同时从输入输出中还原命令记录审计
+type ShellRecording struct {
+	*Recorder
+	Session *models.ShellSession
+	db      *gorm.DB
+
+	mu       sync.Mutex
+	commands *CommandAssembler
+}
+
+// Start 创建会话记录并开始录像，录像无法创建时会话记录标记为已结束并返回错误
+func (s *ShellSessionService) Start(session *models.ShellSession, width, height int) (*ShellRecording, error) {
+	session.ID = 0
+	session.Status = models.ShellSessionActive
+	session.StartedAt = time.Now()
+	if err := models.CreateShellSession(s.DB, session); err != nil {
+		return nil, err
+	}
+
+	rel := filepath.Join(session.StartedAt.Format("2006/01/02"), fmt.Sprintf("%d.cast", session.ID))
+	title := fmt.Sprintf("%s@%s", session.Username, session.HostName)
+	recorder, err := NewRecorder(filepath.Join(config.Conf.SSH.RecordingDir, rel), width, height, title)
+	if err != nil {
+		models.CloseShellSession(s.DB, session.ID, nil, 0)
+		return nil, fmt.Errorf("创建会话录像失败: %v", err)
+	}
+	if err := models.SetShellSessionRecording(s.DB, session.ID, rel); err != nil {
+		recorder.Close()
+		models.CloseShellSession(s.DB, session.ID, nil, 0)
+		return nil, err
+	}
+	session.RecordingPath = rel
+
+	return &ShellRecording{
+		Recorder: recorder,
+		Session:  session,
+		db:       s.DB,
+		commands: NewCommandAssembler(regexp.MustCompile(config.Conf.SSH.PromptPattern)),
+	}, nil
+}
+
+// Input 记录用户输入，用于还原命令
+func (r *ShellRecording) Input(user ShellUser, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands.Input(user, data)
+}
+
+// Output 写入录像，并为输出中确认执行的命令记录审计
+func (r *ShellRecording) Output(data []byte) error {
+	err := r.Recorder.Output(data)
+
+	r.mu.Lock()
+	commands := r.commands.Output(data)
+	r.mu.Unlock()
+
+	for _, command := range commands {
+		if _, err := r.Audit(command.User, command.Command, models.CommandExecuted, nil); err != nil {
+			log.Printf("记录会话 %d 命令审计失败: %v", r.Session.ID, err)
+		}
+	}
+	return err
+}
+
+// CurrentLine 返回用户尚未回车的当前命令行，见 CommandAssembler.Current
+func (r *ShellRecording) CurrentLine(user ShellUser) (typed, screen string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.commands.Current(user)
+}
+
+// Audit 记录会话中用户的命令审计事件，rule 为命中的命令规则
+func (r *ShellRecording) Audit(user ShellUser, command, status string, rule *models.CommandRule) (*models.CommandAudit, error) {
+	audit := &models.CommandAudit{
+		SessionID:  r.Session.ID,
+		UserID:     user.ID,
+		Username:   user.Name,
+		HostID:     r.Session.HostID,
+		HostName:   r.Session.HostName,
+		Command:    command,
+		Elapsed:    r.Elapsed(),
+		ExecutedAt: time.Now(),
+		Status:     status,
+	}
+	if rule != nil {
+		audit.RuleID = &rule.ID
+		audit.RuleName = rule.Name
+	}
+	return audit, models.CreateCommandAudit(r.db, audit)
+}
+
+// Finish 关闭录像并记录会话结束时间与退出码
+func (r *ShellRecording) Finish(exitStatus *int) {
+	size, err := r.Recorder.Close()
+	if err != nil {
+		log.Printf("会话 %d 录像写入失败: %v", r.Session.ID, err)
+	}
+	if err := models.CloseShellSession(r.db, r.Session.ID, exitStatus, size); err != nil {
+		log.Printf("更新会话 %d 记录失败: %v", r.Session.ID, err)
+	}
+}
+
+// SessionExitStatus 从 session.Wait 的返回值中取得退出码，连接中断等情况返回 nil
+func SessionExitStatus(err error) *int {
+	if err == nil {
+		status := 0
+		return &status
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		status := exitErr.ExitStatus()
+		return &status
+	}
+	return nil
+}
+
+// CloseStale 将服务重启前未正常结束的会话标记为已结束，并取消未处理的命令审批
+func (s *ShellSessionService) CloseStale() error {
+	if err := models.CloseStaleShellSessions(s.DB); err != nil {
+		return err
+	}
+	return models.RejectPendingCommandAudits(s.DB)
+}
+
+// RecordingFile 返回会话录像文件的完整路径
+func RecordingFile(session *models.ShellSession) (string, error) {
+	if session.RecordingPath == "" {
+		return "", errors.New("会话没有录像")
+	}
+	return filepath.Join(config.Conf.SSH.RecordingDir, session.RecordingPath), nil
+}
+
+// ReplayOptions 回放参数
+type ReplayOptions struct {
+	Speed     float64       // 播放倍速
+	IdleLimit time.Duration // 事件之间的最长等待，0 表示按原始间隔
+}
+
+// Replay 按录像时间轴逐行发送 asciicast 内容（首行为文件头）。
+// 会话仍在进行时持续跟随新的输出，直到会话结束或 ctx 取消。
+func (s *ShellSessionService) Replay(ctx context.Context, session *models.ShellSession, opts ReplayOptions, send func(line []byte) error) error {
+	path, err := RecordingFile(session)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开会话录像失败: %v", err)
+	}
+	defer file.Close()
+
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+
+	reader := bufio.NewReader(file)
+	active := session.Status == models.ShellSessionActive
+	var partial []byte
+	header := true
+	start := time.Now()
+	last, skipped := 0.0, 0.0 // skipped 为 idle 限制跳过的秒数（已按倍速换算）
+
+	for {
+		chunk, err := reader.ReadBytes('\n')
+		partial = append(partial, chunk...)
+		if err == io.EOF {
+			if !active {
+				return nil
+			}
+			// 等待新的输出，会话结束后再读取一次剩余内容
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(replayPollInterval):
+			}
+			current, err := models.GetShellSession(s.DB, session.ID)
+			if err != nil {
+				return err
+			}
+			active = current.Status == models.ShellSessionActive
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line := partial
+		partial = nil
+		if header {
+			header = false
+			if err := send(line); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var event []json.RawMessage
+		var at float64
+		if json.Unmarshal(line, &event) != nil || len(event) != 3 || json.Unmarshal(event[0], &at) != nil {
+			continue
+		}
+
+		// 按开始回放的时间点排定事件，跟随进行中的会话时不会因等待新输出而累积延迟
+		if gap := (at - last) / opts.Speed; opts.IdleLimit > 0 && gap > opts.IdleLimit.Seconds() {
+			skipped += gap - opts.IdleLimit.Seconds()
+		}
+		last = at
+		due := start.Add(time.Duration((at/opts.Speed - skipped) * float64(time.Second)))
+		if delay := time.Until(due); delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		if err := send(line); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+
+	"devops/models"
+)
+
+// sshDialTimeout SSH 建立连接超时时间
+const sshDialTimeout = 10 * time.Second
+
+// SSHUser 返回登录主机使用的用户名，凭据中指定了用户名时优先使用
+func SSHUser(host *models.Host) string {
+	if host.Credential != nil && host.Credential.Username != "" {
+		return host.Credential.Username
+	}
+	return host.Username
+}
+
+// SSHAuthMethods 根据主机关联的凭据构建认证方式；未关联凭据时使用主机密码
+func SSHAuthMethods(host *models.Host) ([]ssh.AuthMethod, error) {
+	if host.CredentialID != nil && host.Credential == nil {
+		return nil, errors.New("主机凭据未加载")
+	}
+	if host.Credential == nil {
+		return passwordAuth(host.Password.String()), nil
+	}
+
+	cred := host.Credential
+	var methods []ssh.AuthMethod
+	switch cred.Type {
+	case models.CredentialPassword:
+	case models.CredentialPrivateKey, models.CredentialCertificate:
+		signers, err := CredentialSigners(cred)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signers...))
+	default:
+		return nil, fmt.Errorf("不支持的凭据类型: %s", cred.Type)
+	}
+
+	// 密钥认证失败时仍可回退到凭据中的密码
+	if cred.Password.IsSet() {
+		methods = append(methods, passwordAuth(cred.Password.String())...)
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("凭据中没有可用的认证信息")
+	}
+	return methods, nil
+}
+
+// CredentialSigners 解析凭据中的私钥与证书，证书签名器排在前面优先尝试
+func CredentialSigners(cred *models.Credential) ([]ssh.Signer, error) {
+	if !cred.PrivateKey.IsSet() {
+		return nil, errors.New("私钥不能为空")
+	}
+
+	var signer ssh.Signer
+	var err error
+	if cred.Passphrase.IsSet() {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(cred.PrivateKey.String()), []byte(cred.Passphrase.String()))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(cred.PrivateKey.String()))
+	}
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, errors.New("私钥已加密，请提供密码短语")
+		}
+		return nil, fmt.Errorf("解析私钥失败: %v", err)
+	}
+
+	if cred.Type != models.CredentialCertificate {
+		return []ssh.Signer{signer}, nil
+	}
+
+	cert, err := ParseUserCertificate(cred.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("证书与私钥不匹配: %v", err)
+	}
+	return []ssh.Signer{certSigner, signer}, nil
+}
+
+// ParseUserCertificate 解析 authorized_keys 格式的 OpenSSH 用户证书
+func ParseUserCertificate(text string) (*ssh.Certificate, error) {
+	if text == "" {
+		return nil, errors.New("证书不能为空")
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("解析证书失败: %v", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("不是 OpenSSH 证书")
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.New("不是用户证书")
+	}
+	return cert, nil
+}
+
+// ValidateCredential 校验凭据内容是否完整且可解析
+func ValidateCredential(cred *models.Credential) error {
+	if cred.Name == "" {
+		return errors.New("凭据名称不能为空")
+	}
+	switch cred.Type {
+	case models.CredentialPassword:
+		if !cred.Password.IsSet() {
+			return errors.New("密码不能为空")
+		}
+		return nil
+	case models.CredentialPrivateKey, models.CredentialCertificate:
+		_, err := CredentialSigners(cred)
+		return err
+	default:
+		return fmt.Errorf("不支持的凭据类型: %s", cred.Type)
+	}
+}
+
+// NormalizeHostAddress 校验主机地址并返回规范形式：IPv4、IPv6（可带 [] 与 %zone）或域名。
+// IPv6 去掉方括号并按标准形式压缩，域名转为小写并去掉末尾的点
+func NormalizeHostAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return "", errors.New("主机地址不能为空")
+	}
+
+	literal := address
+	if strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]") {
+		literal = address[1 : len(address)-1]
+	}
+	if ip, err := netip.ParseAddr(literal); err == nil && (literal == address || ip.Is6()) {
+		return ip.String(), nil
+	}
+	if strings.ContainsAny(address, ":[]") {
+		return "", fmt.Errorf("IP地址 %q 格式不正确", address)
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(address, "."))
+	if len(name) > 253 {
+		return "", errors.New("域名不能超过253个字符")
+	}
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if !hostnameLabelPattern.MatchString(label) {
+			return "", fmt.Errorf("主机地址 %q 既不是有效的IP地址也不是有效的域名", address)
+		}
+	}
+	// 最后一段全为数字时更可能是写错的 IPv4 地址，例如 10.0.0.256
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", fmt.Errorf("IP地址 %q 格式不正确", address)
+	}
+	return name, nil
+}
+
+// hostnameLabelPattern 域名中的一段：字母、数字与连字符，不以连字符开头或结尾，最长 63 个字符
+var hostnameLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// SSHService 主机 SSH 连接服务
+type SSHService struct {
+	DB *gorm.DB
+}
+
+// NewSSHService 创建 SSH 连接服务实例
+func NewSSHService(db *gorm.DB) *SSHService {
+	return &SSHService{DB: db}
+}
+
+// clientConfig 构建连接主机的 SSH 客户端配置及其主机密钥校验器
+func (s *SSHService) clientConfig(host *models.Host) (*ssh.ClientConfig, *hostKeyVerifier, error) {
+	auth, err := SSHAuthMethods(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	verifier, err := newHostKeyVerifier(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ssh.ClientConfig{
+		User:              SSHUser(host),
+		Auth:              auth,
+		HostKeyCallback:   verifier.Callback,
+		HostKeyAlgorithms: verifier.Algorithms(),
+		Timeout:           sshDialTimeout,
+	}, verifier, nil
+}
+
+// maxJumpHops 跳板机链的最大长度
+const maxJumpHops = 8
+
+// Route 解析连接主机需要依次经过的跳板机。
+// 第一个跳板机自身配置的跳板机链同样生效，后续跳板机均经由前一跳连接。
+func (s *SSHService) Route(host *models.Host) ([]*models.Host, error) {
+	return s.route(host, map[uint]bool{})
+}
+
+func (s *SSHService) route(host *models.Host, visiting map[uint]bool) ([]*models.Host, error) {
+	if len(host.JumpHostIDs) == 0 {
+		return nil, nil
+	}
+	if host.ID != 0 {
+		visiting[host.ID] = true
+	}
+
+	hops := make([]*models.Host, 0, len(host.JumpHostIDs))
+	for _, id := range host.JumpHostIDs {
+		if id == host.ID || visiting[id] {
+			return nil, fmt.Errorf("跳板机链存在循环: 主机ID %d", id)
+		}
+		hop, err := models.GetHostByID(s.DB, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("跳板机 %d 不存在", id)
+			}
+			return nil, err
+		}
+		hops = append(hops, hop)
+	}
+
+	prefix, err := s.route(hops[0], visiting)
+	if err != nil {
+		return nil, err
+	}
+	route := append(prefix, hops...)
+
+	seen := map[uint]bool{host.ID: true}
+	for _, hop := range route {
+		if seen[hop.ID] {
+			return nil, fmt.Errorf("跳板机链存在循环: 主机 %s", hop.Name)
+		}
+		seen[hop.ID] = true
+	}
+	if len(route) > maxJumpHops {
+		return nil, fmt.Errorf("跳板机链过长，最多 %d 跳", maxJumpHops)
+	}
+	return route, nil
+}
+
+// Dial 使用主机凭据建立 SSH 连接并校验主机密钥，配置了跳板机时依次经由跳板机连接
+func (s *SSHService) Dial(host *models.Host) (*ssh.Client, error) {
+	route, err := s.Route(host)
+	if err != nil {
+		return nil, err
+	}
+	return s.DialRoute(route, host)
+}
+
+// DialRoute 经由给定的跳板机依次连接主机。每一跳使用各自的凭据并校验各自的主机密钥，
+// 返回的连接关闭时一并关闭途经的跳板机连接。
+func (s *SSHService) DialRoute(route []*models.Host, host *models.Host) (*ssh.Client, error) {
+	var chain []*ssh.Client
+	closeChain := func() {
+		for i := len(chain) - 1; i >= 0; i-- {
+			chain[i].Close()
+		}
+	}
+
+	var via *ssh.Client
+	for _, hop := range append(route, host) {
+		client, err := s.dialHop(via, hop)
+		if err != nil {
+			closeChain()
+			if via != nil {
+				return nil, fmt.Errorf("经跳板机连接主机 %s 失败: %w", hop.Name, err)
+			}
+			return nil, err
+		}
+		chain = append(chain, client)
+		via = client
+	}
+
+	if len(chain) > 1 {
+		go func() {
+			chain[len(chain)-1].Wait()
+			closeChain()
+		}()
+	}
+	return via, nil
+}
+
+// dialHop 连接单台主机，via 不为空时通过该连接转发。
+// 首次连接（tofu 策略）成功后记录主机密钥；密钥不一致时拒绝连接，并将新密钥记录为待确认。
+func (s *SSHService) dialHop(via *ssh.Client, host *models.Host) (*ssh.Client, error) {
+	config, verifier, err := s.clientConfig(host)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(host.IP, strconv.Itoa(host.Port))
+	var client *ssh.Client
+	if via == nil {
+		client, err = ssh.Dial("tcp", addr, config)
+	} else {
+		client, err = dialThrough(via, addr, config)
+	}
+	if verifier.err != nil {
+		if saveErr := models.SavePendingHostKey(s.DB, host.ID, MarshalHostKey(verifier.rejected), verifier.err.Actual); saveErr != nil {
+			log.Printf("记录主机 %s 的待确认密钥失败: %v", host.Name, saveErr)
+		}
+		log.Printf("拒绝连接主机 %s: %v", host.Name, verifier.err)
+		return nil, verifier.err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if verifier.accepted != nil {
+		hostKey, fingerprint := MarshalHostKey(verifier.accepted), ssh.FingerprintSHA256(verifier.accepted)
+		if err := models.SaveHostKey(s.DB, host.ID, hostKey, fingerprint); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("记录主机密钥失败: %v", err)
+		}
+		host.HostKey, host.HostKeyFingerprint = hostKey, fingerprint
+		log.Printf("首次连接主机 %s，已记录主机密钥 %s", host.Name, fingerprint)
+	}
+	return client, nil
+}
+
+// dialThrough 通过已有连接的 direct-tcpip 通道与目标主机握手
+func dialThrough(via *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// 转发通道不支持读写超时，握手超时时直接关闭通道
+	timer := time.AfterFunc(config.Timeout, func() { conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !timer.Stop() && err != nil {
+		return nil, fmt.Errorf("握手超时: %v", err)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// passwordAuth 密码认证，同时支持只开放 keyboard-interactive 的服务器
+func passwordAuth(password string) []ssh.AuthMethod {
+	return []ssh.AuthMethod{
+		ssh.Password(password),
+		ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range questions {
+				answers[i] = password
+			}
+			return answers, nil
+		}),
+	}
+}
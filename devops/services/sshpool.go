@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+
+	"devops/config"
+	"devops/models"
+)
+
+// SSHPool 按主机ID复用 SSH 连接与 SFTP 客户端。
+// 每台主机只保持一条连接，并发操作通过租约共享，短时操作与交互式终端分别限制连接上的会话数；空闲超时后关闭，
+// 连接期间定期发送 keepalive，主机信息变更时调用 Invalidate 使旧连接不再被复用。
+type SSHPool struct {
+	ssh         *SSHService
+	idleTimeout time.Duration
+	keepalive   time.Duration
+	maxSessions int
+	maxShells   int
+
+	mu      sync.Mutex
+	entries map[uint]*poolEntry
+}
+
+// poolEntry 一台主机的池化连接
+type poolEntry struct {
+	pool   *SSHPool
+	hostID uint
+	name   string
+	via    []uint // 途经的跳板机，由 pool.mu 保护
+
+	ready  chan struct{} // 连接建立完成（成功或失败）后关闭
+	err    error
+	client *ssh.Client
+	slots  chan struct{} // 短时操作的并发数信号量
+	shells chan struct{} // 交互式终端数的信号量
+
+	mu       sync.Mutex
+	sftp     *sftp.Client
+	refs     int
+	lastUsed time.Time
+	stale    bool // 已失效，不再分配新租约，最后一个租约释放后关闭
+	closed   bool
+}
+
+// SSHLease 一次连接借用，使用完毕必须调用 Release
+type SSHLease struct {
+	entry *poolEntry
+	slots chan struct{} // 占用名额的信号量，max_sessions 或 max_shells
+	once  sync.Once
+}
+
+// sshAcquireTimeout 等待连接建立或空闲会话的最长时间
+const sshAcquireTimeout = 30 * time.Second
+
+var sshPool *SSHPool
+
+// InitSSHPool 初始化全局 SSH 连接池
+func InitSSHPool(db *gorm.DB, conf config.SSHConfig) {
+	sshPool = NewSSHPool(NewSSHService(db), conf)
+}
+
+// AcquireSSH 从全局连接池借用主机连接
+func AcquireSSH(ctx context.Context, host *models.Host) (*SSHLease, error) {
+	return sshPool.Acquire(ctx, host)
+}
+
+// AcquireSSHShell 从全局连接池为交互式终端借用主机连接，见 SSHPool.AcquireShell
+func AcquireSSHShell(ctx context.Context, host *models.Host) (*SSHLease, error) {
+	return sshPool.AcquireShell(ctx, host)
+}
+
+// InvalidateSSH 使全局连接池中主机的连接失效
+func InvalidateSSH(hostID uint) {
+	if sshPool != nil {
+		sshPool.Invalidate(hostID)
+	}
+}
+
+// NewSSHPool 创建连接池并启动空闲回收
+func NewSSHPool(svc *SSHService, conf config.SSHConfig) *SSHPool {
+	p := &SSHPool{
+		ssh:         svc,
+		idleTimeout: conf.IdleTimeout,
+		keepalive:   conf.KeepaliveInterval,
+		maxSessions: conf.MaxSessions,
+		maxShells:   conf.MaxShells,
+		entries:     make(map[uint]*poolEntry),
+	}
+	go p.reap()
+	return p
+}
+
+// Acquire 借用主机连接，需要时建立新连接；并发操作数达到上限时等待直到 ctx 结束
+func (p *SSHPool) Acquire(ctx context.Context, host *models.Host) (*SSHLease, error) {
+	return p.acquire(ctx, host, false)
+}
+
+// AcquireShell 为 WebShell 等交互式终端借用主机连接。终端可能在整个会话期间（包括断线等待重连期间）空闲，
+// 因此占用单独的 max_shells 名额而不是 max_sessions，避免打开的终端阻塞文件传输、批量执行等短时操作；
+// 终端数已达上限时不等待，直接返回错误
+func (p *SSHPool) AcquireShell(ctx context.Context, host *models.Host) (*SSHLease, error) {
+	return p.acquire(ctx, host, true)
+}
+
+// acquire 借用主机连接，shell 为 true 时占用终端数的名额，否则占用并发操作数的名额
+func (p *SSHPool) acquire(ctx context.Context, host *models.Host, shell bool) (*SSHLease, error) {
+	ctx, cancel := context.WithTimeout(ctx, sshAcquireTimeout)
+	defer cancel()
+
+	entry := p.entry(host)
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+
+	slots := entry.slots
+	if shell {
+		slots = entry.shells
+		select {
+		case slots <- struct{}{}:
+		default:
+			return nil, fmt.Errorf("主机 %s 打开的终端数已达上限 (%d)，请关闭其他终端后重试", host.Name, p.maxShells)
+		}
+	} else {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("主机 %s 的并发会话数已达上限 (%d)", host.Name, p.maxSessions)
+		}
+	}
+
+	entry.mu.Lock()
+	if entry.closed {
+		entry.mu.Unlock()
+		<-slots
+		// 等待期间连接已断开，重新获取
+		return p.acquire(ctx, host, shell)
+	}
+	entry.refs++
+	entry.lastUsed = time.Now()
+	entry.mu.Unlock()
+
+	return &SSHLease{entry: entry, slots: slots}, nil
+}
+
+// entry 返回主机的可用连接项，不存在时创建并在后台拨号
+func (p *SSHPool) entry(host *models.Host) *poolEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry, ok := p.entries[host.ID]; ok {
+		return entry
+	}
+
+	entry := &poolEntry{
+		pool:   p,
+		hostID: host.ID,
+		name:   host.Name,
+		ready:  make(chan struct{}),
+		slots:  make(chan struct{}, p.maxSessions),
+		shells: make(chan struct{}, p.maxShells),
+	}
+	p.entries[host.ID] = entry
+
+	dialHost := *host
+	go entry.dial(&dialHost)
+	return entry
+}
+
+// dial 建立连接；失败时从池中移除，使后续请求重新拨号
+func (e *poolEntry) dial(host *models.Host) {
+	defer close(e.ready)
+
+	route, err := e.pool.ssh.Route(host)
+	if err != nil {
+		e.err = err
+		e.pool.remove(e)
+		return
+	}
+	e.pool.mu.Lock()
+	for _, hop := range route {
+		e.via = append(e.via, hop.ID)
+	}
+	e.pool.mu.Unlock()
+
+	client, err := e.pool.ssh.DialRoute(route, host)
+	if err != nil {
+		e.err = err
+		e.pool.remove(e)
+		return
+	}
+	e.client = client
+	e.lastUsed = time.Now()
+
+	go e.keepalive()
+	go func() {
+		client.Wait()
+		e.pool.remove(e)
+		e.close()
+	}()
+}
+
+// keepalive 定期发送 keepalive 请求，超时未响应时关闭连接
+func (e *poolEntry) keepalive() {
+	if e.pool.keepalive <= 0 {
+		return
+	}
+	ticker := time.NewTicker(e.pool.keepalive)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		e.mu.Lock()
+		closed := e.closed
+		e.mu.Unlock()
+		if closed {
+			return
+		}
+
+		result := make(chan error, 1)
+		go func() {
+			_, _, err := e.client.SendRequest("keepalive@openssh.com", true, nil)
+			result <- err
+		}()
+		select {
+		case err := <-result:
+			if err == nil {
+				continue
+			}
+			log.Printf("主机 %s 连接 keepalive 失败: %v", e.name, err)
+		case <-time.After(e.pool.keepalive):
+			log.Printf("主机 %s 连接 keepalive 超时", e.name)
+		}
+		e.client.Close()
+		return
+	}
+}
+
+// reap 定期关闭空闲超时的连接
+func (p *SSHPool) reap() {
+	interval := p.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.mu.Lock()
+		var idle []*poolEntry
+		for id, entry := range p.entries {
+			select {
+			case <-entry.ready:
+			default:
+				continue
+			}
+			entry.mu.Lock()
+			if entry.refs == 0 && time.Since(entry.lastUsed) > p.idleTimeout {
+				delete(p.entries, id)
+				idle = append(idle, entry)
+			}
+			entry.mu.Unlock()
+		}
+		p.mu.Unlock()
+
+		for _, entry := range idle {
+			entry.close()
+		}
+	}
+}
+
+// Invalidate 使主机以及经由该主机跳转的池化连接失效：新的请求重新建立连接，正在使用的租约释放后关闭旧连接
+func (p *SSHPool) Invalidate(hostID uint) {
+	p.mu.Lock()
+	var invalid []*poolEntry
+	for id, entry := range p.entries {
+		if id == hostID || containsID(entry.via, hostID) {
+			delete(p.entries, id)
+			invalid = append(invalid, entry)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, entry := range invalid {
+		go func(entry *poolEntry) {
+			<-entry.ready
+			entry.mu.Lock()
+			entry.stale = true
+			idle := entry.refs == 0
+			entry.mu.Unlock()
+			if idle {
+				entry.close()
+			}
+		}(entry)
+	}
+}
+
+// containsID 判断ID是否在列表中
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// remove 从池中移除连接项（仍是同一项时）
+func (p *SSHPool) remove(entry *poolEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.entries[entry.hostID] == entry {
+		delete(p.entries, entry.hostID)
+	}
+}
+
+// close 关闭连接及其 SFTP 客户端
+func (e *poolEntry) close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	sftpClient := e.sftp
+	e.sftp = nil
+	e.mu.Unlock()
+
+	if sftpClient != nil {
+		sftpClient.Close()
+	}
+	if e.client != nil {
+		e.client.Close()
+	}
+}
+
+// Client 返回底层 SSH 连接，可用于创建会话
+func (l *SSHLease) Client() *ssh.Client {
+	return l.entry.client
+}
+
+// SFTP 返回该主机共享的 SFTP 客户端，首次使用或原客户端断开时新建
+func (l *SSHLease) SFTP() (*sftp.Client, error) {
+	e := l.entry
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil, fmt.Errorf("主机 %s 的连接已关闭", e.name)
+	}
+	if e.sftp != nil {
+		return e.sftp, nil
+	}
+
+	client, err := sftp.NewClient(e.client)
+	if err != nil {
+		return nil, err
+	}
+	e.sftp = client
+	go func() {
+		client.Wait()
+		e.mu.Lock()
+		if e.sftp == client {
+			e.sftp = nil
+		}
+		e.mu.Unlock()
+	}()
+	return client, nil
+}
+
+// Release 归还租约，可重复调用
+func (l *SSHLease) Release() {
+	l.once.Do(func() {
+		e := l.entry
+		<-l.slots
+
+		e.mu.Lock()
+		e.refs--
+		e.lastUsed = time.Now()
+		closeNow := e.stale && e.refs == 0
+		e.mu.Unlock()
+
+		if closeNow {
+			e.close()
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 后台任务类型
+const (
+	TaskCompress = "compress" // 压缩主机上的目录
+	TaskExtract  = "extract"  // 解压主机上的压缩文件
+)
+
+// 后台任务状态
+const (
+	TaskRunning   = "running"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+	TaskCanceled  = "canceled"
+)
+
+// taskRetention 结束的任务在内存中保留的时间
+const taskRetention = time.Hour
+
+// Task 在后台执行的主机文件操作。任务只保存在内存中，服务重启后丢失
+type Task struct {
+	ID         uint       `json:"id"`
+	Type       string     `json:"type"`
+	HostID     uint       `json:"hostId"`
+	UserID     uint       `json:"userId"`
+	Username   string     `json:"username"`
+	Target     string     `json:"target"` // 操作的路径
+	Status     string     `json:"status"`
+	Current    int64      `json:"current"` // 已处理的数量
+	Total      int64      `json:"total"`   // 需处理的总数，0 表示未知
+	Message    string     `json:"message"` // 当前进度说明，如使用的压缩方式
+	Result     string     `json:"result"`  // 成功时的结果，如生成的文件路径
+	Error      string     `json:"error"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// TaskProgress 任务执行过程中更新进度
+type TaskProgress struct {
+	entry *taskEntry
+}
+
+// TaskID 任务 ID
+func (p *TaskProgress) TaskID() uint {
+	return p.entry.task.ID
+}
+
+// SetTotal 设置需处理的总数
+func (p *TaskProgress) SetTotal(total int64) {
+	p.update(func(t *Task) { t.Total = total })
+}
+
+// Add 增加已处理的数量，不超过已知的总数
+func (p *TaskProgress) Add(n int64) {
+	p.update(func(t *Task) {
+		t.Current += n
+		if t.Total > 0 && t.Current > t.Total {
+			t.Current = t.Total
+		}
+	})
+}
+
+// SetMessage 设置进度说明
+func (p *TaskProgress) SetMessage(message string) {
+	p.update(func(t *Task) { t.Message = message })
+}
+
+func (p *TaskProgress) update(fn func(t *Task)) {
+	tasks.Lock()
+	defer tasks.Unlock()
+	fn(&p.entry.task)
+}
+
+type taskEntry struct {
+	task   Task
+	cancel context.CancelFunc
+}
+
+// tasks 进行中及最近结束的任务
+var tasks = struct {
+	sync.Mutex
+	nextID  uint
+	entries map[uint]*taskEntry
+}{entries: make(map[uint]*taskEntry)}
+
+// StartTask 登记任务并在后台执行 run，run 返回的字符串为任务结果。返回登记时的任务快照
+func StartTask(task Task, run func(ctx context.Context, progress *TaskProgress) (string, error)) Task {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	tasks.Lock()
+	pruneTasks()
+	tasks.nextID++
+	task.ID = tasks.nextID
+	task.Status = TaskRunning
+	task.StartedAt = time.Now()
+	entry := &taskEntry{task: task, cancel: cancel}
+	tasks.entries[task.ID] = entry
+	tasks.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := run(ctx, &TaskProgress{entry: entry})
+
+		tasks.Lock()
+		defer tasks.Unlock()
+		now := time.Now()
+		entry.task.FinishedAt = &now
+		switch {
+		case err == nil:
+			entry.task.Status = TaskSucceeded
+			entry.task.Result = result
+			if entry.task.Total > 0 {
+				entry.task.Current = entry.task.Total
+			}
+		case errors.Is(err, context.Canceled) || ctx.Err() != nil:
+			entry.task.Status = TaskCanceled
+		default:
+			entry.task.Status = TaskFailed
+			entry.task.Error = err.Error()
+		}
+	}()
+	return task
+}
+
+// GetTask 获取任务快照
+func GetTask(id uint) (Task, bool) {
+	tasks.Lock()
+	defer tasks.Unlock()
+	entry, ok := tasks.entries[id]
+	if !ok {
+		return Task{}, false
+	}
+	return entry.task, true
+}
+
+// ListTasks 获取用户的任务，userID 为 0 时获取全部任务，按开始时间倒序
+func ListTasks(userID uint) []Task {
+	tasks.Lock()
+	defer tasks.Unlock()
+	pruneTasks()
+
+	list := []Task{}
+	for _, entry := range tasks.entries {
+		if userID == 0 || entry.task.UserID == userID {
+			list = append(list, entry.task)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	return list
+}
+
+// CancelTask 取消进行中的任务，任务不存在或已结束时返回 false
+func CancelTask(id uint) bool {
+	tasks.Lock()
+	defer tasks.Unlock()
+	entry, ok := tasks.entries[id]
+	if !ok || entry.task.Status != TaskRunning {
+		return false
+	}
+	entry.cancel()
+	return true
+}
+
+// pruneTasks 清理结束超过 taskRetention 的任务，调用方需持有锁
+func pruneTasks() {
+	for id, entry := range tasks.entries {
+		if entry.task.FinishedAt != nil && time.Since(*entry.task.FinishedAt) > taskRetention {
+			delete(tasks.entries, id)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+
+	"gorm.io/gorm"
+
+	"devops/models"
+)
+
+// EnsureAdminUser 当系统中没有任何用户时创建初始管理员账号
+func EnsureAdminUser(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.User{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	password := base64.RawURLEncoding.EncodeToString(buf)
+
+	admin := &models.User{
+		Username: "admin",
+		Nickname: "管理员",
+		Status:   "active",
+	}
+	if err := admin.SetPassword(password); err != nil {
+		return err
+	}
+	if err := models.CreateUser(db, admin); err != nil {
+		return err
+	}
+
+	log.Printf("已创建初始管理员账号 admin, 初始密码: %s (请登录后立即修改)", password)
+	return nil
+}
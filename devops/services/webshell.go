@@ -0,0 +1,574 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+
+	"devops/config"
+	"devops/models"
+)
+
+// shellClientBuffer 每个共享连接最多缓存的未发送事件数，超出后断开该连接
+const shellClientBuffer = 256
+
+var (
+	// ErrShellEnded 会话已结束
+	ErrShellEnded = errors.New("会话已结束")
+	// ErrShellShareInvalid 共享令牌无效或已撤销
+	ErrShellShareInvalid = errors.New("共享令牌无效或已撤销")
+	// ErrShellShareRevoked 连接所用的共享已被撤销
+	ErrShellShareRevoked = errors.New("共享已被撤销")
+	// ErrShellClientLagging 客户端接收输出过慢
+	ErrShellClientLagging = errors.New("接收终端输出过慢，连接已断开")
+	// ErrShellResumed 会话已被创建者的新连接接管
+	ErrShellResumed = errors.New("会话已在其他连接中恢复")
+	// ErrShellUserRevoked 用户被删除、禁用或修改了密码
+	ErrShellUserRevoked = errors.New("用户登录状态已失效，连接已断开")
+)
+
+// ShellEventKind 终端事件类型
+type ShellEventKind int
+
+// 终端事件类型
+const (
+	ShellOutput ShellEventKind = iota // 终端输出
+	ShellResize                       // 终端尺寸变化，只发送给共享连接
+)
+
+// ShellEvent 推送给终端客户端的事件
+type ShellEvent struct {
+	Kind ShellEventKind
+	Data []byte
+	Cols int
+	Rows int
+}
+
+// ShellShare 会话共享，持有令牌的用户可以连接到同一个终端
+type ShellShare struct {
+	ID        uint          `json:"id"`
+	Token     string        `json:"token"`
+	Writable  bool          `json:"writable"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Viewers   []ShellViewer `json:"viewers"`
+}
+
+// ShellViewer 经共享连接到会话的用户
+type ShellViewer struct {
+	UserID     uint      `json:"userId"`
+	Username   string    `json:"username"`
+	Writable   bool      `json:"writable"`
+	AttachedAt time.Time `json:"attachedAt"`
+}
+
+// ShellClient 连接到终端的客户端，会话创建者为 Owner，其余经共享连接
+type ShellClient struct {
+	UserID     uint
+	Username   string
+	Owner      bool
+	Writable   bool
+	AttachedAt time.Time
+
+	shareID uint
+	events  chan ShellEvent
+	done    chan struct{} // 关闭表示已断开
+	err     error         // 被服务端断开的原因
+}
+
+// User 返回客户端的用户，用于区分共享会话中各用户的输入
+func (c *ShellClient) User() ShellUser {
+	return ShellUser{ID: c.UserID, Name: c.Username}
+}
+
+// Next 返回下一个事件，客户端断开后返回 false
+func (c *ShellClient) Next() (ShellEvent, bool) {
+	select {
+	case event := <-c.events:
+		return event, true
+	case <-c.done:
+		// 断开前已排队的事件仍然发送
+		select {
+		case event := <-c.events:
+			return event, true
+		default:
+			return ShellEvent{}, false
+		}
+	}
+}
+
+// Err 返回客户端被服务端断开的原因，会话结束或客户端自行断开时为 nil
+func (c *ShellClient) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// LiveShell 运行中的 WebShell 会话。SSH 终端独立于 WebSocket 连接，
+// 输出分发给创建者及经共享连接的所有客户端；创建者断开后会话保留
+// ssh.reconnect_grace 等待重连，重连时回放最近的输出。
+type LiveShell struct {
+	Session *models.ShellSession
+	Host    *models.Host
+
+	lease     *SSHLease
+	session   *ssh.Session
+	recording *ShellRecording
+	guard     *CommandGuard
+
+	mu          sync.Mutex
+	cols, rows  int
+	owner       *ShellClient // 创建者当前的连接，断开等待重连期间为 nil
+	grace       *time.Timer  // 等待重连的计时
+	scrollback  *ringBuffer
+	clients     map[*ShellClient]struct{}
+	shares      map[uint]*ShellShare
+	nextShareID uint
+	exited      bool
+	exitStatus  *int
+}
+
+// liveShells 本进程中运行中的会话，按会话ID索引
+var liveShells = struct {
+	sync.Mutex
+	shells map[uint]*LiveShell
+}{shells: make(map[uint]*LiveShell)}
+
+// GetLiveShell 返回运行中的会话，会话不存在或已结束时返回 nil
+func GetLiveShell(sessionID uint) *LiveShell {
+	liveShells.Lock()
+	defer liveShells.Unlock()
+	return liveShells.shells[sessionID]
+}
+
+// CloseUserShells 结束用户创建的会话，并断开用户经共享建立的连接。用户被删除、禁用或修改密码后调用
+func CloseUserShells(userID uint) {
+	liveShells.Lock()
+	shells := make([]*LiveShell, 0, len(liveShells.shells))
+	for _, s := range liveShells.shells {
+		shells = append(shells, s)
+	}
+	liveShells.Unlock()
+
+	for _, s := range shells {
+		if s.Session.UserID == userID {
+			log.Printf("用户 %d 登录状态已失效，结束会话 %d", userID, s.Session.ID)
+			s.Close()
+			continue
+		}
+		s.mu.Lock()
+		for client := range s.clients {
+			if client.UserID == userID {
+				s.detach(client, ErrShellUserRevoked)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// StartLiveShell 连接主机并打开终端，开始录像与命令审计。
+// 返回的客户端代表会话创建者，其连接断开时调用 Detach 等待重连，主动结束时调用 Close。
+func StartLiveShell(ctx context.Context, db *gorm.DB, host *models.Host, session *models.ShellSession, cols, rows int) (*LiveShell, *ShellClient, error) {
+	// 从连接池借用SSH连接，会话结束前一直占用 max_shells 的名额，不计入并发操作数
+	lease, err := AcquireSSHShell(ctx, host)
+	if err != nil {
+		return nil, nil, fmt.Errorf("SSH连接失败: %v", err)
+	}
+	s := &LiveShell{
+		Host:       host,
+		lease:      lease,
+		cols:       cols,
+		rows:       rows,
+		scrollback: newRingBuffer(config.Conf.SSH.ScrollbackSize),
+		clients:    make(map[*ShellClient]struct{}),
+		shares:     make(map[uint]*ShellShare),
+	}
+	stdout, stderr, err := s.open(db, session)
+	if err != nil {
+		if s.session != nil {
+			s.session.Close()
+		}
+		lease.Release()
+		return nil, nil, err
+	}
+	s.Session = s.recording.Session
+
+	// 在输出开始转发之前加入创建者，避免丢失最初的提示符
+	s.owner = s.attach(session.UserID, session.Username, true, true, 0)
+
+	liveShells.Lock()
+	liveShells.shells[s.Session.ID] = s
+	liveShells.Unlock()
+
+	go s.run(stdout, stderr)
+	return s, s.owner, nil
+}
+
+// open 创建 SSH 会话、请求伪终端并启动 shell
+func (s *LiveShell) open(db *gorm.DB, session *models.ShellSession) (stdout, stderr io.Reader, err error) {
+	if s.session, err = s.lease.Client().NewSession(); err != nil {
+		return nil, nil, fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := s.session.RequestPty("xterm", s.rows, s.cols, modes); err != nil {
+		return nil, nil, fmt.Errorf("请求伪终端失败: %v", err)
+	}
+
+	stdin, err := s.session.StdinPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取标准输入失败: %v", err)
+	}
+	if stdout, err = s.session.StdoutPipe(); err != nil {
+		return nil, nil, fmt.Errorf("获取标准输出失败: %v", err)
+	}
+	if stderr, err = s.session.StderrPipe(); err != nil {
+		return nil, nil, fmt.Errorf("获取标准错误失败: %v", err)
+	}
+
+	// 开始会话录像与命令审计，无法录像时不允许打开终端
+	session.HostID = s.Host.ID
+	session.HostName = s.Host.Name
+	session.HostAddr = net.JoinHostPort(s.Host.IP, strconv.Itoa(s.Host.Port))
+	if s.recording, err = NewShellSessionService(db).Start(session, s.cols, s.rows); err != nil {
+		return nil, nil, fmt.Errorf("开始会话录像失败: %v", err)
+	}
+
+	if err := s.session.Shell(); err != nil {
+		s.recording.Finish(nil)
+		return nil, nil, fmt.Errorf("启动shell失败: %v", err)
+	}
+
+	// 用户提交的命令行先经过命令策略检查再发送到服务器，提示信息同时写入录像
+	s.guard = NewCommandGuard(db, s.recording, s.Host, stdin, func(text string) {
+		s.recording.Recorder.Output([]byte(text))
+		s.broadcast([]byte(text))
+	})
+	return stdout, stderr, nil
+}
+
+// run 转发终端输出，会话结束后记录退出码并断开所有客户端
+func (s *LiveShell) run(stdout, stderr io.Reader) {
+	var output sync.WaitGroup
+	output.Add(2)
+	go s.forward(stdout, "标准输出", &output)
+	go s.forward(stderr, "标准错误", &output)
+
+	exitStatus := SessionExitStatus(s.session.Wait())
+	s.session.Close()
+	output.Wait()
+	s.guard.Close()
+	s.recording.Finish(exitStatus)
+	s.lease.Release()
+
+	liveShells.Lock()
+	delete(liveShells.shells, s.Session.ID)
+	liveShells.Unlock()
+
+	s.mu.Lock()
+	s.exited = true
+	s.exitStatus = exitStatus
+	if s.grace != nil {
+		s.grace.Stop()
+	}
+	for client := range s.clients {
+		s.detach(client, nil)
+	}
+	s.shares = make(map[uint]*ShellShare)
+	s.mu.Unlock()
+}
+
+// forward 读取终端输出，写入录像并分发给客户端
+func (s *LiveShell) forward(r io.Reader, name string, wg *sync.WaitGroup) {
+	defer wg.Done()
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buffer)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("读取%s失败: %v", name, err)
+			}
+			return
+		}
+		s.recording.Output(buffer[:n])
+		s.broadcast(append([]byte(nil), buffer[:n]...))
+	}
+}
+
+// broadcast 分发终端输出并保存到回放缓冲区。创建者的连接按其接收速度限流，
+// 共享连接缓存已满时断开，避免拖慢终端。
+func (s *LiveShell) broadcast(data []byte) {
+	event := ShellEvent{Kind: ShellOutput, Data: data}
+
+	s.mu.Lock()
+	s.scrollback.Write(data)
+	owner := s.owner
+	for client := range s.clients {
+		if client == owner {
+			continue
+		}
+		select {
+		case client.events <- event:
+		default:
+			s.detach(client, ErrShellClientLagging)
+		}
+	}
+	s.mu.Unlock()
+
+	if owner != nil {
+		select {
+		case owner.events <- event:
+		case <-owner.done:
+		}
+	}
+}
+
+// ExitStatus 返回会话是否已结束及退出码，连接中断等情况退出码为 nil
+func (s *LiveShell) ExitStatus() (*int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitStatus, s.exited
+}
+
+// Close 结束会话
+func (s *LiveShell) Close() {
+	s.session.Close()
+}
+
+// Input 处理客户端输入，命令记录在该客户端的用户名下；只读或已断开连接的输入被忽略
+func (s *LiveShell) Input(client *ShellClient, data []byte) error {
+	s.mu.Lock()
+	_, attached := s.clients[client]
+	s.mu.Unlock()
+	if !attached || !client.Writable {
+		return nil
+	}
+	return s.guard.Input(client.User(), data)
+}
+
+// Resize 调整终端尺寸，只有创建者当前的连接可以调整，新尺寸会通知共享连接
+func (s *LiveShell) Resize(client *ShellClient, cols, rows int) error {
+	s.mu.Lock()
+	if client != s.owner {
+		s.mu.Unlock()
+		return nil
+	}
+	s.cols, s.rows = cols, rows
+	event := ShellEvent{Kind: ShellResize, Cols: cols, Rows: rows}
+	for c := range s.clients {
+		if c == client {
+			continue
+		}
+		select {
+		case c.events <- event:
+		default:
+			s.detach(c, ErrShellClientLagging)
+		}
+	}
+	s.mu.Unlock()
+
+	s.recording.Resize(cols, rows)
+	return s.session.WindowChange(rows, cols)
+}
+
+// Share 创建共享令牌，writable 为 true 时持有者可以输入
+func (s *LiveShell) Share(writable bool) (*ShellShare, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exited {
+		return nil, ErrShellEnded
+	}
+	s.nextShareID++
+	share := &ShellShare{
+		ID:        s.nextShareID,
+		Token:     base64.RawURLEncoding.EncodeToString(buf),
+		Writable:  writable,
+		CreatedAt: time.Now(),
+	}
+	s.shares[share.ID] = share
+	copied := *share
+	copied.Viewers = []ShellViewer{}
+	return &copied, nil
+}
+
+// Shares 返回会话的共享及经各共享连接的用户
+func (s *LiveShell) Shares() []ShellShare {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shares := make([]ShellShare, 0, len(s.shares))
+	for id := uint(1); id <= s.nextShareID; id++ {
+		share, ok := s.shares[id]
+		if !ok {
+			continue
+		}
+		copied := *share
+		copied.Viewers = []ShellViewer{}
+		for client := range s.clients {
+			if client.shareID == id {
+				copied.Viewers = append(copied.Viewers, ShellViewer{
+					UserID:     client.UserID,
+					Username:   client.Username,
+					Writable:   client.Writable,
+					AttachedAt: client.AttachedAt,
+				})
+			}
+		}
+		shares = append(shares, copied)
+	}
+	return shares
+}
+
+// RevokeShare 撤销共享并断开经该共享连接的客户端，共享不存在时返回 false
+func (s *LiveShell) RevokeShare(shareID uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.shares[shareID]; !ok {
+		return false
+	}
+	delete(s.shares, shareID)
+	for client := range s.clients {
+		if client.shareID == shareID {
+			s.detach(client, ErrShellShareRevoked)
+		}
+	}
+	return true
+}
+
+// FindShare 按令牌查找共享
+func (s *LiveShell) FindShare(token string) (*ShellShare, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, share := range s.shares {
+		if subtle.ConstantTimeCompare([]byte(share.Token), []byte(token)) == 1 {
+			copied := *share
+			return &copied, nil
+		}
+	}
+	return nil, ErrShellShareInvalid
+}
+
+// AttachShared 经共享连接到会话，连接后首先收到当前终端尺寸与最近的输出
+func (s *LiveShell) AttachShared(token string, userID uint, username string) (*ShellClient, error) {
+	share, err := s.FindShare(token)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exited {
+		return nil, ErrShellEnded
+	}
+	if _, ok := s.shares[share.ID]; !ok {
+		return nil, ErrShellShareInvalid
+	}
+	client := s.attach(userID, username, false, share.Writable, share.ID)
+	client.events <- ShellEvent{Kind: ShellResize, Cols: s.cols, Rows: s.rows}
+	client.events <- ShellEvent{Kind: ShellOutput, Data: s.scrollback.Bytes()}
+	return client, nil
+}
+
+// Resume 创建者重新连接到会话，先收到最近的输出。已有的创建者连接会被断开
+func (s *LiveShell) Resume() (*ShellClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exited {
+		return nil, ErrShellEnded
+	}
+	if s.grace != nil {
+		s.grace.Stop()
+		s.grace = nil
+	}
+	if s.owner != nil {
+		s.detach(s.owner, ErrShellResumed)
+	}
+	s.owner = s.attach(s.Session.UserID, s.Session.Username, true, true, 0)
+	s.owner.events <- ShellEvent{Kind: ShellOutput, Data: s.scrollback.Bytes()}
+	return s.owner, nil
+}
+
+// Detach 断开客户端。创建者断开后会话保留 ssh.reconnect_grace 等待重连，超时后结束
+func (s *LiveShell) Detach(client *ShellClient) {
+	grace := config.Conf.SSH.ReconnectGrace
+
+	s.mu.Lock()
+	owner := client == s.owner
+	s.detach(client, nil)
+	if owner && grace > 0 && !s.exited {
+		var timer *time.Timer
+		timer = time.AfterFunc(grace, func() { s.expire(timer) })
+		s.grace = timer
+	}
+	s.mu.Unlock()
+
+	if owner && grace <= 0 {
+		s.Close()
+	}
+}
+
+// expire 等待重连超时后结束会话，计时已被重连取消时忽略
+func (s *LiveShell) expire(timer *time.Timer) {
+	s.mu.Lock()
+	waiting := s.grace == timer && s.owner == nil && !s.exited
+	s.mu.Unlock()
+
+	if waiting {
+		log.Printf("会话 %d 等待重连超时，已结束", s.Session.ID)
+		s.Close()
+	}
+}
+
+// attach 登记客户端，调用方需持有锁或尚未开始转发输出
+func (s *LiveShell) attach(userID uint, username string, owner, writable bool, shareID uint) *ShellClient {
+	client := &ShellClient{
+		UserID:     userID,
+		Username:   username,
+		Owner:      owner,
+		Writable:   writable,
+		AttachedAt: time.Now(),
+		shareID:    shareID,
+		events:     make(chan ShellEvent, shellClientBuffer),
+		done:       make(chan struct{}),
+	}
+	s.clients[client] = struct{}{}
+	return client
+}
+
+// detach 移除客户端并记录原因，调用方需持有锁
+func (s *LiveShell) detach(client *ShellClient, reason error) {
+	if _, ok := s.clients[client]; !ok {
+		return
+	}
+	delete(s.clients, client)
+	if client == s.owner {
+		s.owner = nil
+	}
+	client.err = reason
+	close(client.done)
+}
@@ -71,6 +71,15 @@ export function uploadSftpFile(hostId, formData) {
   });
 }
 
+// 读取SFTP文件末尾的 bytes 字节，用于预览大日志
+export function fetchSftpFileTail(hostId, path, bytes = 64 * 1024) {
+  return axios.get(`/api/host/${hostId}/sftp/download`, {
+    params: { path },
+    headers: { Range: `bytes=-${bytes}` },
+    responseType: 'text',
+  });
+}
+
 // 从SFTP下载文件
 export function downloadSftpFile(hostId, path) {
   return axios.get(`/api/host/${hostId}/sftp/download`, {
@@ -93,17 +102,24 @@ export function renameSftpFile(hostId, oldPath, newPath) {
   });
 }
 
-// 下载SFTP目录（压缩）
-export function downloadSftpDir(hostId, path) {
+// 下载SFTP目录（压缩），format 可选 zip、tar.gz
+export function downloadSftpDir(hostId, path, format = 'zip') {
   return axios.get(`/api/host/${hostId}/sftp/download-dir`, {
-    params: { path },
+    params: { path, format },
     responseType: 'blob',
   });
 }
 
-// 压缩SFTP目录
-export function compressSftpDir(hostId, path) {
+// 压缩SFTP目录，在后台执行，返回的任务通过 /api/tasks 查询进度；format 可选 zip、tar.gz
+export function compressSftpDir(hostId, path, format = 'zip') {
   return axios.post(`/api/host/${hostId}/sftp/compress`, null, {
-    params: { path }
+    params: { path, format }
+  });
+}
+
+// 解压SFTP压缩文件到所在目录，在后台执行；policy 可选 skip（保留已存在的文件）、overwrite
+export function extractSftpFile(hostId, path, policy = 'skip') {
+  return axios.post(`/api/host/${hostId}/sftp/extract`, null, {
+    params: { path, policy }
   });
 }
@@ -0,0 +1,16 @@
+import axios from 'axios';
+
+// 获取后台任务列表
+export function queryTaskList(params) {
+  return axios.get('/api/tasks', { params });
+}
+
+// 获取后台任务进度
+export function getTask(id) {
+  return axios.get(`/api/tasks/${id}`);
+}
+
+// 取消后台任务
+export function cancelTask(id) {
+  return axios.post(`/api/tasks/${id}/cancel`);
+}
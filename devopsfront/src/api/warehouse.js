@@ -1,11 +1,14 @@
 import axios from 'axios';
 import { Message } from '@arco-design/web-vue';
+import { withAuthorization } from '@/utils/auth';
 
 const request = axios.create({
   baseURL: import.meta.env.VITE_HOST,
   timeout: 10000,
 });
 
+request.interceptors.request.use(withAuthorization);
+
 // 获取仓库列表
 export async function getRepositories() {
   const response = await request.get('/api/repositories');
@@ -1,4 +1,5 @@
 import { createApp } from 'vue'
+import axios from 'axios'
 import ArcoVue from '@arco-design/web-vue'
 import ArcoVueIcon from '@arco-design/web-vue/es/icon'
 import '@arco-design/web-vue/dist/arco.css'
@@ -8,6 +9,9 @@ import store from '@/store'
 import router from '@/router'
 import '@/permission'
 import '@/styles/index.css'
+import { withAuthorization } from '@/utils/auth'
+
+axios.interceptors.request.use(withAuthorization)
 
 createApp(App)
   .use(ArcoVue)
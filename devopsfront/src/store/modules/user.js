@@ -15,12 +15,9 @@ const state = getDefaultState()
 const actions = {
   async login({ commit }, loginForm) {
     try {
-      // const res =
-      // const res = await login(loginForm)
-      //   commit('SET_TOKEN', res.data.token)
-      // setToken(res.data.token)
-      commit('SET_TOKEN', "1234565")
-      setToken("1234565")
+      const res = await login(loginForm)
+      commit('SET_TOKEN', res.data.token)
+      setToken(res.data.token)
       return true
     } catch (e) {
       return false
@@ -13,3 +13,13 @@ export function setToken(token) {
 export function removeToken() {
   return Cookies.remove(tokenKey)
 }
+
+// 为请求附加 Authorization 头
+export function withAuthorization(config) {
+  const token = getToken()
+  if (token) {
+    config.headers = config.headers || {}
+    config.headers.Authorization = `Bearer ${token}`
+  }
+  return config
+}
@@ -1,10 +1,13 @@
 import axios from 'axios'
 import { Message } from '@arco-design/web-vue'
+import { withAuthorization } from '@/utils/auth'
 
 const service = axios.create({
   baseURL: import.meta.env.VITE_HOST
 })
 
+service.interceptors.request.use(withAuthorization)
+
 // service.interceptors.response.use(
 //   response => {
 //     const res = response.data